@@ -0,0 +1,23 @@
+package cancelreader
+
+import "os"
+
+// envScreenReader overrides the detection of ScreenReaderActive when set to
+// 1 or 0.
+const envScreenReader = "CANCELREADER_SCREEN_READER"
+
+// ScreenReaderActive reports whether input should be set up for a screen
+// reader: raw modes keep Ctrl+C processing and mouse capture is avoided, so
+// the screen reader keeps working while reads stay cancelable. The
+// environment variable CANCELREADER_SCREEN_READER set to 1 or 0 selects the
+// mode explicitly. Otherwise a running screen reader is detected on Windows.
+func ScreenReaderActive() bool {
+	switch os.Getenv(envScreenReader) {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+
+	return screenReaderRunning()
+}
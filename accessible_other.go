@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package cancelreader
+
+// screenReaderRunning reports false since terminals don't tell.
+func screenReaderRunning() bool {
+	return false
+}
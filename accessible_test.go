@@ -0,0 +1,24 @@
+package cancelreader
+
+import (
+	"os"
+	"testing"
+)
+
+func TestScreenReaderActive(t *testing.T) {
+	old, ok := os.LookupEnv(envScreenReader)
+	defer func() {
+		if ok {
+			os.Setenv(envScreenReader, old)
+		} else {
+			os.Unsetenv(envScreenReader)
+		}
+	}()
+
+	for value, expected := range map[string]bool{"1": true, "0": false} {
+		os.Setenv(envScreenReader, value)
+		if active := ScreenReaderActive(); active != expected {
+			t.Errorf("expected %v for %s=%s, got %v", expected, envScreenReader, value, active)
+		}
+	}
+}
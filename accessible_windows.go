@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const spiGetScreenReader = 0x0046
+
+var procSystemParametersInfoW = moduser32.NewProc("SystemParametersInfoW")
+
+// screenReaderRunning asks the system whether a screen reader is running.
+func screenReaderRunning() bool {
+	var running int32
+
+	r, _, _ := syscall.Syscall6(procSystemParametersInfoW.Addr(), 4,
+		spiGetScreenReader, 0, uintptr(unsafe.Pointer(&running)), 0, 0, 0)
+
+	return r != 0 && running != 0
+}
@@ -0,0 +1,185 @@
+package cancelreader
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchReader batches input while the system is under load, see
+// WithAdaptivePolling, or always at multiples of the interval, see
+// WithKeystrokeQuantization.
+type batchReader struct {
+	CancelReader
+
+	interval time.Duration
+	loaded   func() bool
+	clock    Clock
+	quantize bool
+	start    time.Time
+
+	lock     sync.Mutex
+	canceled chan struct{} // closed and replaced by Cancel
+}
+
+func newBatchReader(cr CancelReader, interval time.Duration, loaded func() bool, clock Clock) *batchReader {
+	return &batchReader{CancelReader: cr, interval: interval, loaded: loaded, clock: clock, canceled: make(chan struct{})}
+}
+
+func (r *batchReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+// readContext returns as soon as input arrives unless the system is loaded.
+// Then it lets more input arrive for the interval and returns all of it at
+// once, so that a burst of keystrokes causes a single wakeup.
+func (r *batchReader) readContext(ctx context.Context, data []byte) (int, error) {
+	r.lock.Lock()
+	canceled := r.canceled
+	r.lock.Unlock()
+
+	n, err := readContext(ctx, r.CancelReader, data)
+	if err != nil || n == len(data) || !r.loaded() {
+		return n, err
+	}
+
+	p, ok := r.CancelReader.(poller)
+	if !ok {
+		return n, nil
+	}
+
+	wait := r.interval
+	if r.quantize {
+		// the next multiple of the interval since the reader was created
+		wait -= r.clock.Now().Sub(r.start) % r.interval
+	}
+
+	expired := make(chan struct{})
+	timer := r.clock.AfterFunc(wait, func() { close(expired) })
+	defer timer.Stop()
+
+	select {
+	case <-expired:
+	case <-canceled:
+		return n, nil
+	case <-ctx.Done():
+		return n, nil
+	}
+
+	for n < len(data) {
+		ready, err := p.poll(0)
+		if !ready || err != nil {
+			break
+		}
+
+		m, err := r.CancelReader.Read(data[n:])
+		n += m
+
+		if err != nil {
+			// returned by the next Read
+			break
+		}
+	}
+
+	return n, nil
+}
+
+// newQuantizingReader returns a batchReader that returns input only at
+// multiples of quantum.
+func newQuantizingReader(cr CancelReader, quantum time.Duration, clock Clock) *batchReader {
+	r := newBatchReader(cr, quantum, func() bool { return true }, clock)
+	r.quantize = true
+	r.start = clock.Now()
+
+	return r
+}
+
+func (r *batchReader) poll(timeout time.Duration) (bool, error) {
+	return pollReader(r.CancelReader, timeout)
+}
+
+// Cancel ends the batching of the pending Read. Later Reads batch again once
+// the reader is reset, so the channel is replaced.
+func (r *batchReader) Cancel() bool {
+	r.lock.Lock()
+	close(r.canceled)
+	r.canceled = make(chan struct{})
+	r.lock.Unlock()
+
+	return r.CancelReader.Cancel()
+}
+
+// pressureFiles report the CPU pressure stall information of the cgroup and
+// of the system on Linux.
+var pressureFiles = []string{"/sys/fs/cgroup/cpu.pressure", "/proc/pressure/cpu"}
+
+// pressureCacheTime limits how often CPUPressureAbove reads the pressure.
+const pressureCacheTime = time.Second
+
+// CPUPressureAbove returns a load signal for WithAdaptivePolling that reports
+// whether the share of time in which tasks waited for a CPU over the last ten
+// seconds exceeds percent. It uses the pressure stall information of the
+// cgroup of the process, e.g. a container, or else of the system, and
+// reports false where it is not available.
+func CPUPressureAbove(percent float64) func() bool {
+	var (
+		lock    sync.Mutex
+		checked time.Time
+		loaded  bool
+	)
+
+	return func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if time.Since(checked) < pressureCacheTime {
+			return loaded
+		}
+		checked = time.Now()
+
+		avg10, ok := cpuPressure()
+		loaded = ok && avg10 > percent
+
+		return loaded
+	}
+}
+
+// cpuPressure returns the avg10 value of the "some" line of the first
+// readable pressure file.
+func cpuPressure() (float64, bool) {
+	for _, name := range pressureFiles {
+		avg10, ok := readPressure(name)
+		if ok {
+			return avg10, true
+		}
+	}
+
+	return 0, false
+}
+
+func readPressure(name string) (float64, bool) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close() // nolint: errcheck
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+
+		value := strings.TrimPrefix(fields[1], "avg10=")
+		avg10, err := strconv.ParseFloat(value, 64)
+
+		return avg10, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package cancelreader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pollingCancelReader is a pollingChunkReader that can't be canceled.
+type pollingCancelReader struct {
+	pollingChunkReader
+}
+
+func (r *pollingCancelReader) Cancel() bool { return false }
+func (r *pollingCancelReader) Close() error { return nil }
+
+func TestBatchReader(t *testing.T) {
+	for _, tc := range []struct {
+		loaded   bool
+		expected string
+	}{
+		{false, "a"},
+		{true, "abc"},
+	} {
+		loaded := tc.loaded
+		cr := &pollingCancelReader{pollingChunkReader{chunkReader{"a", "b", "c"}}}
+		r := newBatchReader(cr, time.Millisecond, func() bool { return loaded }, systemClock{})
+
+		p := make([]byte, 10)
+		n, err := r.Read(p)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if string(p[:n]) != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, string(p[:n]))
+		}
+	}
+}
+
+func TestReadPressure(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "cpu.pressure")
+	content := "some avg10=12.50 avg60=3.00 avg300=1.00 total=100\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n"
+
+	err := os.WriteFile(name, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	avg10, ok := readPressure(name)
+	if !ok || avg10 != 12.5 {
+		t.Errorf("expected 12.5, got %v, %v", avg10, ok)
+	}
+}
+
+func TestQuantizingReader(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	cr := &pollingCancelReader{pollingChunkReader{chunkReader{"a", "b", "c"}}}
+	r := newQuantizingReader(cr, 20*time.Millisecond, clock)
+
+	clock.Advance(7 * time.Millisecond)
+
+	done := make(chan string, 1)
+	go func() {
+		p := make([]byte, 10)
+		n, _ := r.Read(p)
+		done <- string(p[:n])
+	}()
+
+	for {
+		clock.lock.Lock()
+		waiting := len(clock.timers)
+		clock.lock.Unlock()
+		if waiting > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(12 * time.Millisecond)
+	select {
+	case data := <-done:
+		t.Fatalf("expected no input before the next quantum, but got %q", data)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Millisecond)
+	if data := <-done; data != "abc" {
+		t.Errorf("expected %q, got %q", "abc", data)
+	}
+}
@@ -1,13 +1,32 @@
 package cancelreader
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"io"
 	"sync"
+	"time"
 )
 
-// ErrCanceled gets returned when trying to read from a canceled reader.
-var ErrCanceled = fmt.Errorf("read canceled")
+// ErrCanceled gets returned when trying to read from a canceled reader. It
+// implements net.Error and matches context.Canceled with errors.Is, so that
+// stacks branching on those treat a cancelation as such.
+var ErrCanceled error = &cancelError{}
+
+type cancelError struct{}
+
+func (*cancelError) Error() string { return "read canceled" }
+
+// Timeout implements net.Error. A canceled read did not time out.
+func (*cancelError) Timeout() bool { return false }
+
+// Temporary implements net.Error. Reset makes the reader usable again.
+func (*cancelError) Temporary() bool { return true }
+
+func (*cancelError) Is(target error) bool { return target == context.Canceled }
 
 // CancelReader is a io.Reader whose Read() calls can be canceled without data
 // being consumed. The cancelReader has to be closed.
@@ -18,6 +37,363 @@ type CancelReader interface {
 	Cancel() bool
 }
 
+// NewReader returns a CancelReader for reader. If reader is a File or a
+// syscall.Conn, ongoing reads are canceled with the mechanism of the
+// platform, see Backends.
+// Otherwise Cancel only affects future reads and returns false. On Windows,
+// only ongoing reads from os.Stdin can be canceled.
+func NewReader(reader io.Reader, opts ...Option) (CancelReader, error) {
+	cfg := newConfig(opts)
+
+	err := checkMacros(cfg.macros)
+	if err != nil {
+		return nil, err
+	}
+
+	owned, _ := reader.(io.Closer)
+
+	reader, err = asFile(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// connections are always waited on with a duplicate, see connFile
+	var dup io.Closer
+	if cf, ok := reader.(*connFile); ok {
+		dup = cf
+	} else if f, ok := reader.(File); ok && cfg.dupFd {
+		df, err := dupFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if df != nil {
+			reader, dup = df, df
+		}
+	}
+
+	var probes []ProbeResult
+	if cfg.probeBackends {
+		cfg.backend, probes = probeBackends(reader, cfg)
+	}
+
+	var handles int
+
+	cr, err := openReader(reader, cfg)
+	if err == nil {
+		cr, handles, err = withinBudget(reader, cfg, cr)
+	}
+	if err != nil {
+		if dup != nil {
+			_ = dup.Close()
+		}
+
+		return nil, err
+	}
+
+	r := &infoReader{
+		CancelReader: cr,
+		base:         cr,
+		kind:         inputKind(reader),
+		clock:        cfg.clock,
+		created:      cfg.clock.Now(),
+		onFirstRead:  cfg.onFirstRead,
+		probes:       probes,
+		backend:      backendOf(cr),
+		handles:      handles,
+
+		cancelOnClose: cfg.cancelOnClose,
+		eofOnCancel:   cfg.eofOnCancel,
+	}
+	r.stats.Handles = handles
+	if f, ok := reader.(File); ok {
+		r.input = f
+	}
+	if cfg.ownership {
+		r.owned = owned
+	}
+	r.dup = dup
+	r.startStateEvents(cfg.idleTimeout, cfg.stateEvents)
+	if cfg.detectResume {
+		r.healthEvents = cfg.healthEvents
+		r.stopResume = watchResume(r.resumed)
+	}
+	if cfg.traceHash {
+		r.hash = fnv.New64a()
+	}
+	cfg.wrap(r)
+
+	return r, nil
+}
+
+// copyBufferSize is the size of the buffer used by WriteTo, as of io.Copy.
+const copyBufferSize = 32 * 1024
+
+// infoReader is the CancelReader returned by NewReader. It wraps the backend
+// and the readers of options implemented on top of it and keeps what was
+// found out about the input.
+type infoReader struct {
+	CancelReader
+
+	// base is the reader of the backend.
+	base CancelReader
+
+	input     File // nil if the input is not a File, see Export
+	kind      Kind
+	backend   string
+	probes    []ProbeResult // see WithBackendProbe
+	bom       *bomReader
+	readAhead *readAheadReader // see WithReadAhead
+	credit    *creditReader    // see WithReadCredit
+
+	clock       Clock
+	created     time.Time
+	onFirstRead func(FirstRead)
+	lock        sync.Mutex
+	first       *FirstRead
+
+	// generation counts the completed Reads, see CancelToken.
+	generation uint64
+	canceled   bool  // canceled by Cancel
+	cause      error // see CancelWithError
+	closed     bool
+	done       chan struct{} // see Done
+
+	unread      []byte    // see Unread
+	eofOnCancel bool      // see WithEOFOnCancel
+	owned       io.Closer // closed by Close, see WithOwnership
+	dup         io.Closer // see WithDupFd and connFile
+
+	// pending Reads, see WithCancelOnClose
+	cancelOnClose bool
+	reading       int
+	idle          chan struct{} // closed once reading drops to 0
+	tokenCanceled bool          // canceled by a CancelToken during the current Read
+
+	stopResume   func() // see WithResumeDetection
+	healthEvents chan<- Health
+
+	stats   ReadStats
+	handles int         // released by Close, see SetHandleLimit
+	hash    hash.Hash64 // see WithTraceHash
+
+	// idle detection, see WithStateEvents
+	idleTimeout time.Duration
+	stateEvents chan<- StateEvent
+	idleTimer   Timer
+	state       State
+	lastInput   time.Time
+}
+
+// FirstRead describes how long it took until a reader returned data for the
+// first time.
+type FirstRead struct {
+	// SinceCreated is the time from NewReader to the first data.
+	SinceCreated time.Duration
+
+	// SinceRead is the time from the start of the Read call that returned
+	// the first data.
+	SinceRead time.Duration
+}
+
+func (r *infoReader) Read(data []byte) (int, error) {
+	return r.ReadContext(context.Background(), data)
+}
+
+// ReadContext implements ContextReader.
+func (r *infoReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	r.lock.Lock()
+	done := r.first != nil
+	if len(r.unread) > 0 {
+		n := copy(data, r.unread)
+		r.unread = r.unread[n:]
+		r.lock.Unlock()
+
+		return n, nil
+	}
+	r.lock.Unlock()
+
+	var start time.Time
+	if !done {
+		start = r.clock.Now()
+	}
+
+	r.beginRead()
+	n, err := readContext(ctx, r.CancelReader, data)
+	r.finishRead()
+	if n > 0 {
+		if !done {
+			r.recordFirstRead(start)
+		}
+		r.markActive()
+		r.count(data[:n])
+	}
+	r.endRead(err)
+
+	if r.eofOnCancel && errors.Is(err, ErrCanceled) {
+		return n, io.EOF
+	}
+
+	return n, r.withCause(err)
+}
+
+// ReadByte implements io.ByteReader. It reads a single byte from the backend
+// instead of buffering, so input not consumed yet stays available to Poll,
+// Pending and other readers of the input, and Cancel still interrupts it.
+func (r *infoReader) ReadByte() (byte, error) {
+	var b [1]byte
+
+	for {
+		n, err := r.Read(b[:])
+		if n > 0 {
+			return b[0], nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// WriteTo implements io.WriterTo, so that io.Copy streams from the reader
+// until it is canceled or reaches the end of the input. It returns
+// ErrCanceled once canceled.
+func (r *infoReader) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, copyBufferSize)
+
+	var written int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			written += int64(m)
+
+			if werr != nil {
+				return written, werr // nolint: wrapcheck
+			}
+			if m < n {
+				return written, io.ErrShortWrite
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (r *infoReader) Cancel() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.canceled = true
+	r.markDone()
+
+	return r.CancelReader.Cancel()
+}
+
+func (r *infoReader) recordFirstRead(start time.Time) {
+	now := r.clock.Now()
+
+	r.lock.Lock()
+	if r.first != nil {
+		r.lock.Unlock()
+		return
+	}
+	r.first = &FirstRead{SinceCreated: now.Sub(r.created), SinceRead: now.Sub(start)}
+	first := *r.first
+	r.lock.Unlock()
+
+	if r.onFirstRead != nil {
+		r.onFirstRead(first)
+	}
+}
+
+func (r *infoReader) poll(timeout time.Duration) (bool, error) {
+	return pollReader(r.CancelReader, timeout)
+}
+
+func (r *infoReader) reset() error {
+	if r.readAhead != nil {
+		return r.readAhead.reset()
+	}
+
+	if r.credit != nil {
+		return r.credit.reset()
+	}
+
+	rs, ok := r.base.(resetter)
+	if !ok {
+		return fmt.Errorf("backend %s cannot be reset", r.backend)
+	}
+
+	return rs.reset()
+}
+
+// Reset makes r usable again after Cancel, e.g. after input was suspended
+// while a subprocess ran. It clears the canceled state and drains pending
+// cancel signals without recreating the backend. Reset must not be called
+// while a Read is pending. It fails if the backend of r can't be reset.
+func Reset(r CancelReader) error {
+	info, ok := infoOf(r)
+	if !ok {
+		rs, ok := r.(resetter)
+		if !ok {
+			return fmt.Errorf("%T cannot be reset", r)
+		}
+
+		return rs.reset()
+	}
+
+	err := info.reset()
+	if err != nil {
+		return err
+	}
+
+	info.lock.Lock()
+	defer info.lock.Unlock()
+
+	info.canceled = false
+	info.cause = nil
+	if !info.closed {
+		info.done = nil
+	}
+
+	return nil
+}
+
+// FirstReadLatency returns how long it took until a CancelReader returned by
+// NewReader returned data for the first time. It returns false if no data was
+// read yet.
+func FirstReadLatency(r CancelReader) (FirstRead, bool) {
+	info, ok := infoOf(r)
+	if !ok {
+		return FirstRead{}, false
+	}
+
+	info.lock.Lock()
+	defer info.lock.Unlock()
+
+	if info.first == nil {
+		return FirstRead{}, false
+	}
+
+	return *info.first, true
+}
+
+// Backend returns the name of the implementation behind a CancelReader
+// returned by NewReader, see Backends. It returns an empty string for other
+// readers.
+func Backend(r CancelReader) string {
+	if info, ok := infoOf(r); ok {
+		return info.backend
+	}
+
+	return ""
+}
+
 // File represents an input/output resource with a file descriptor.
 type File interface {
 	io.ReadWriteCloser
@@ -29,6 +405,31 @@ type File interface {
 	Name() string
 }
 
+// resetter is implemented by readers that can be used again after a
+// cancelation. Cancel signals of earlier generations that are still pending
+// must not cancel reads after reset.
+type resetter interface {
+	reset() error
+}
+
+// poller is implemented by readers that can wait for input with a timeout
+// using the mechanism of their backend.
+type poller interface {
+	// poll reports whether input is available within timeout.
+	poll(timeout time.Duration) (bool, error)
+}
+
+// pollReader polls r and fails if it can't wait for input with a timeout,
+// instead of reporting that no input is available.
+func pollReader(r CancelReader, timeout time.Duration) (bool, error) {
+	p, ok := r.(poller)
+	if !ok {
+		return false, fmt.Errorf("%T cannot poll for input", r)
+	}
+
+	return p.poll(timeout)
+}
+
 // fallbackCancelReader implements cancelReader but does not actually support
 // cancelation during an ongoing Read() call. Thus, Cancel() always returns
 // false. However, after calling Cancel(), new Read() calls immediately return
@@ -63,11 +464,20 @@ func (r *fallbackCancelReader) Read(data []byte) (int, error) {
 	return n, err // nolint: wrapcheck
 }
 
+func (r *fallbackCancelReader) backendName() string {
+	return backendFallback
+}
+
 func (r *fallbackCancelReader) Cancel() bool {
 	r.setCanceled()
 	return false
 }
 
+func (r *fallbackCancelReader) reset() error {
+	r.resetCanceled()
+	return nil
+}
+
 func (r *fallbackCancelReader) Close() error {
 	return nil
 }
@@ -76,6 +486,10 @@ func (r *fallbackCancelReader) Close() error {
 type cancelMixin struct {
 	unsafeCanceled bool
 	lock           sync.Mutex
+
+	// generation is incremented by resetCanceled, so cancel signals of
+	// earlier generations can be told apart from current ones.
+	generation uint64
 }
 
 func (c *cancelMixin) isCanceled() bool {
@@ -85,9 +499,40 @@ func (c *cancelMixin) isCanceled() bool {
 	return c.unsafeCanceled
 }
 
-func (c *cancelMixin) setCanceled() {
+// setCanceled marks the reader as canceled and returns the current
+// generation.
+func (c *cancelMixin) setCanceled() uint64 {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	c.unsafeCanceled = true
+
+	return c.generation
+}
+
+// resetCanceled clears the cancelation and starts a new generation.
+func (c *cancelMixin) resetCanceled() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.unsafeCanceled = false
+	c.generation++
+}
+
+// wakeSignal tags signals that only wake up a waiting Read, e.g. when the
+// context of ReadContext is done, and remoteSignal those of other processes,
+// see ExportCancel. Cancel signals are tagged with the low seven bits of
+// their generation.
+const (
+	wakeSignal   = 0x80
+	remoteSignal = 0x81
+)
+
+// isCurrent reports whether a cancel signal tagged with the low bits of a
+// generation belongs to the current generation and the reader is canceled.
+func (c *cancelMixin) isCurrent(tag byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.unsafeCanceled && tag == byte(c.generation)&^wakeSignal
 }
@@ -0,0 +1,120 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+func init() {
+	// kqueue and select may refuse the terminal of a sandboxed iOS app
+	if runtime.GOOS == "ios" {
+		backends = []string{backendKqueue, backendSelect, backendNetpoll, backendBridge, backendFallback}
+	}
+}
+
+// newSandboxedReader returns the first backend that can wait for file in the
+// sandbox of an iOS app, or a bridge if none can.
+func newSandboxedReader(file File) (CancelReader, error) {
+	for _, newBackend := range []func(File) (CancelReader, error){
+		newKqueueCancelReader,
+		func(file File) (CancelReader, error) { return newSelectCancelReader(file) },
+	} {
+		cr, err := newBackend(file)
+		if err != nil {
+			continue
+		}
+
+		if _, err = cr.(poller).poll(0); err == nil {
+			return cr, nil
+		}
+
+		_ = cr.Close()
+	}
+
+	return newBridgeReader(file)
+}
+
+// newBridgeReader returns a reader that copies file into a pipe in the
+// background and reads from the pipe, which the Go runtime can wait for
+// even where the file itself can't be polled. Cancel sets the read deadline
+// of the pipe. Close stops the copying once the next input arrives.
+func newBridgeReader(file File) (CancelReader, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create bridge pipe: %w", err)
+	}
+
+	r := &bridgeReader{pipe: pr}
+
+	go r.copy(file, pw)
+
+	return r, nil
+}
+
+type bridgeReader struct {
+	pipe *os.File
+	cancelMixin
+
+	lock sync.Mutex
+	err  error // error reading the file
+}
+
+// copy copies file to w until either fails.
+func (r *bridgeReader) copy(file File, w *os.File) {
+	_, err := io.Copy(w, file)
+
+	r.lock.Lock()
+	r.err = err
+	r.lock.Unlock()
+
+	_ = w.Close()
+}
+
+func (r *bridgeReader) Read(data []byte) (int, error) {
+	if r.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	n, err := r.pipe.Read(data)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded) && r.isCanceled():
+		return 0, ErrCanceled
+	case errors.Is(err, io.EOF):
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		if r.err != nil {
+			return n, r.err
+		}
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+func (r *bridgeReader) reset() error {
+	r.resetCanceled()
+
+	return r.pipe.SetReadDeadline(time.Time{}) // nolint: wrapcheck
+}
+
+func (r *bridgeReader) backendName() string {
+	return backendBridge
+}
+
+func (r *bridgeReader) Cancel() bool {
+	r.setCanceled()
+
+	return r.pipe.SetReadDeadline(time.Now()) == nil
+}
+
+func (r *bridgeReader) Close() error {
+	return r.pipe.Close() // nolint: wrapcheck
+}
@@ -4,34 +4,70 @@
 package cancelreader
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
 
-// NewReader returns a reader and a cancel function. If the input reader is a
+// newReader returns a reader and a cancel function. If the input reader is a
 // File, the cancel function can be used to interrupt a blocking read call.
 // In this case, the cancel function returns true if the call was canceled
 // successfully. If the input reader is not a File, the cancel function
 // does nothing and always returns false. The BSD and macOS implementation is
 // based on the kqueue mechanism.
-func NewReader(reader io.Reader) (CancelReader, error) {
+func newReader(reader io.Reader, cfg *config) (CancelReader, error) {
+
 	file, ok := reader.(File)
-	if !ok {
+	switch {
+	case cfg.backend == backendFallback:
+		return newFallbackCancelReader(reader)
+	case !ok && cfg.backend != "":
+		return nil, errUnsupportedInput(cfg.backend, reader)
+	case !ok:
 		return newFallbackCancelReader(reader)
 	}
 
-	// kqueue returns instantly when polling /dev/tty so fallback to select
-	if file.Name() == "/dev/tty" {
-		return newSelectCancelReader(reader)
+	switch cfg.backend {
+	case "":
+		// kqueue returns instantly when polling /dev/tty so fallback to select
+		if file.Name() == "/dev/tty" {
+			return newSelectCancelReader(file)
+		}
+
+		if runtime.GOOS == "ios" {
+			return newSandboxedReader(file)
+		}
+
+		return newKqueueCancelReader(file)
+	case backendKqueue:
+		return newKqueueCancelReader(file)
+	case backendSelect:
+		return newSelectCancelReader(file)
+	case backendNetpoll:
+		return newNetpollCancelReader(file)
+	case backendBridge:
+		if runtime.GOOS != "ios" {
+			return nil, errUnknownBackend(cfg.backend)
+		}
+
+		return newBridgeReader(file)
+	default:
+		return nil, errUnknownBackend(cfg.backend)
 	}
+}
 
+var backends = []string{backendKqueue, backendSelect, backendNetpoll, backendFallback}
+
+func newKqueueCancelReader(file File) (CancelReader, error) {
 	kQueue, err := unix.Kqueue()
 	if err != nil {
-		return nil, fmt.Errorf("create kqueue: %w", err)
+		return nil, syscallError(backendKqueue, "kqueue", file.Fd(), err)
 	}
 
 	r := &kqueueCancelReader{
@@ -61,33 +97,93 @@ type kqueueCancelReader struct {
 }
 
 func (r *kqueueCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *kqueueCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
 	if r.isCanceled() {
 		return 0, ErrCanceled
 	}
 
-	err := r.wait()
-	if err != nil {
+	stop := wakeOnDone(ctx, r.cancelSignalWriter)
+	defer stop()
+
+	for {
+		err := contextErr(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		var ts *unix.Timespec
+		if timeout, ok := timeUntil(ctx); ok {
+			t := unix.NsecToTimespec(timeout.Nanoseconds())
+			ts = &t
+		}
+
+		err = r.wait(ts)
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
+
+		switch {
+		case errors.Is(err, errStaleSignal), errors.Is(err, ErrTimeout):
+			continue // checks ctx again
+		case err != nil:
+			return 0, err
+		}
+
+		return r.file.Read(data)
+	}
+}
+
+func (r *kqueueCancelReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	for {
+		ts := unix.NsecToTimespec(timeout.Nanoseconds())
+
+		err := r.wait(&ts)
 		if errors.Is(err, ErrCanceled) {
-			// remove signal from pipe
-			var b [1]byte
-			_, errRead := r.cancelSignalReader.Read(b[:])
-			if errRead != nil {
-				return 0, fmt.Errorf("reading cancel signal: %w", errRead)
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+			if errors.Is(err, errStaleSignal) {
+				continue
 			}
 		}
 
-		return 0, err
+		if errors.Is(err, ErrTimeout) {
+			return false, nil
+		}
+
+		return err == nil, err
 	}
+}
 
-	return r.file.Read(data)
+func (r *kqueueCancelReader) reset() error {
+	r.resetCanceled()
+	return drainCancelSignals(r.cancelSignalReader)
+}
+
+func (r *kqueueCancelReader) cancelSignal() File {
+	return r.cancelSignalWriter
+}
+
+// cancelSignalSource returns the end of the cancel signal pipe that becomes
+// readable on Cancel, see Select.
+func (r *kqueueCancelReader) cancelSignalSource() File {
+	return r.cancelSignalReader
+}
+
+func (r *kqueueCancelReader) backendName() string {
+	return backendKqueue
 }
 
 func (r *kqueueCancelReader) Cancel() bool {
-	r.setCanceled()
+	generation := r.setCanceled()
 
 	// send cancel signal
-	_, err := r.cancelSignalWriter.Write([]byte{'c'})
-	return err == nil
+	return sendCancelSignal(r.cancelSignalWriter, generation)
 }
 
 func (r *kqueueCancelReader) Close() error {
@@ -95,7 +191,7 @@ func (r *kqueueCancelReader) Close() error {
 	// close kqueue
 	err := unix.Close(r.kQueue)
 	if err != nil {
-		e1 = fmt.Errorf("closing kqueue: %w", err)
+		e1 = fmt.Errorf("closing kqueue: %w", syscallError(backendKqueue, "close", uintptr(r.kQueue), err))
 	}
 
 	// close pipe
@@ -112,17 +208,22 @@ func (r *kqueueCancelReader) Close() error {
 	return errors.Join(e1, e2, e3)
 }
 
-func (r *kqueueCancelReader) wait() error {
+// wait waits until timeout or forever if timeout is nil.
+func (r *kqueueCancelReader) wait(timeout *unix.Timespec) error {
 	events := make([]unix.Kevent_t, 1)
 
 	for {
-		_, err := unix.Kevent(r.kQueue, r.kQueueEvents[:], events, nil)
+		n, err := unix.Kevent(r.kQueue, r.kQueueEvents[:], events, timeout)
 		if errors.Is(err, unix.EINTR) {
 			continue // try again if the syscall was interrupted
 		}
 
 		if err != nil {
-			return fmt.Errorf("kevent: %w", err)
+			return syscallError(backendKqueue, "kevent", uintptr(r.kQueue), err)
+		}
+
+		if n == 0 {
+			return ErrTimeout
 		}
 
 		break
@@ -136,5 +237,5 @@ func (r *kqueueCancelReader) wait() error {
 		return ErrCanceled
 	}
 
-	return fmt.Errorf("unknown error")
+	return fmt.Errorf("kevent returned unknown identifier %d", ident)
 }
@@ -1,12 +1,21 @@
-//go:build !darwin && !windows && !linux && !solaris && !freebsd && !netbsd && !openbsd && !dragonfly
-// +build !darwin,!windows,!linux,!solaris,!freebsd,!netbsd,!openbsd,!dragonfly
+//go:build !darwin && !windows && !linux && !solaris && !freebsd && !netbsd && !openbsd && !dragonfly && !zos
+// +build !darwin,!windows,!linux,!solaris,!freebsd,!netbsd,!openbsd,!dragonfly,!zos
 
 package cancelreader
 
 import "io"
 
-// NewReader returns a fallbackCancelReader that satisfies the CancelReader but
-// does not actually support cancellation.
-func NewReader(reader io.Reader) (CancelReader, error) {
+// newReader returns a fallbackCancelReader that satisfies the CancelReader but
+// does not actually support cancellation. This generic fallback is built for
+// every platform without a native backend, e.g. Fuchsia, Plan 9 or WASI, and
+// CanCancel reports false for its readers. RegisterBackend adds native
+// support without changing the package.
+func newReader(reader io.Reader, cfg *config) (CancelReader, error) {
+	if cfg.backend != "" && cfg.backend != backendFallback {
+		return nil, errUnknownBackend(cfg.backend)
+	}
+
 	return newFallbackCancelReader(reader)
 }
+
+var backends = []string{backendFallback}
@@ -4,7 +4,11 @@
 package cancelreader
 
 import (
+	"context"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,3 +75,390 @@ func TestReader(t *testing.T) {
 		t.Errorf("expected to read %q but got %q", msg[:n], string(p[:n]))
 	}
 }
+
+func TestReaderBackends(t *testing.T) {
+	for _, backend := range Backends() {
+		if backend == backendFallback {
+			continue
+		}
+
+		t.Run(backend, func(t *testing.T) {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer pw.Close()
+			defer pr.Close()
+
+			cr, err := NewReader(pr, WithBackend(backend))
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer cr.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := cr.Read(make([]byte, 1))
+				done <- err
+			}()
+
+			if !cr.Cancel() {
+				t.Errorf("expected cancellation to be success")
+			}
+
+			select {
+			case err = <-done:
+				if err != ErrCanceled {
+					t.Errorf("expected cancel error but got %s", err)
+				}
+			case <-time.After(100 * time.Millisecond):
+				t.Errorf("expected cancellation to unblock reader")
+			}
+		})
+	}
+}
+
+func TestRunChild(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+
+	cr, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	var out strings.Builder
+	cmd := exec.Command("cat")
+	cmd.Stdout = &out
+
+	_, _ = pw.Write([]byte("hello"))
+	pw.Close()
+
+	err = RunChild(cr, cmd)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if out.String() != "hello" {
+		t.Errorf("expected %q but got %q", "hello", out.String())
+	}
+}
+
+func TestRunChildCancelsInput(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	cr, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunChild(cr, exec.Command("true"))
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("expected no error, but got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the exit of the child to cancel the input")
+	}
+}
+
+func TestStdinReader(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	s := &StdinReader{file: pr}
+	defer s.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if !s.Cancel() {
+		t.Errorf("expected cancellation to be success")
+	}
+
+	select {
+	case err = <-done:
+		if err != ErrCanceled {
+			t.Errorf("expected cancel error but got %s", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("expected cancellation to unblock reader")
+	}
+
+	// the next Read uses a new reader
+	_, _ = pw.Write([]byte("x"))
+	p := make([]byte, 1)
+	n, err := s.Read(p)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(p[:n]) != "x" {
+		t.Errorf("expected to read %q but got %q", "x", p[:n])
+	}
+}
+
+func TestStdinReaderBorrow(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	s := &StdinReader{file: pr}
+	defer s.Close()
+
+	consumer, owner := &StdinRef{r: s}, &StdinRef{r: s}
+
+	type result struct {
+		data string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		p := make([]byte, 1)
+		n, err := consumer.Read(p)
+		done <- result{string(p[:n]), err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err = s.borrow(context.Background(), owner); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	_, _ = pw.Write([]byte("a"))
+	p := make([]byte, 1)
+	n, err := owner.Read(p)
+	if err != nil || string(p[:n]) != "a" {
+		t.Errorf("expected the borrower to read %q but got %q, %v", "a", p[:n], err)
+	}
+
+	select {
+	case r := <-done:
+		t.Fatalf("expected the consumer to be paused, but got %q, %v", r.data, r.err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.giveBack(owner)
+	_, _ = pw.Write([]byte("b"))
+
+	select {
+	case r := <-done:
+		if r.err != nil || r.data != "b" {
+			t.Errorf("expected the consumer to read %q but got %q, %v", "b", r.data, r.err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("expected the consumer to resume after the borrow")
+	}
+}
+
+func TestReaderPoll(t *testing.T) {
+	for _, backend := range Backends() {
+		if backend == backendFallback {
+			continue
+		}
+
+		t.Run(backend, func(t *testing.T) {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer pw.Close()
+			defer pr.Close()
+
+			cr, err := NewReader(pr, WithBackend(backend))
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer cr.Close()
+
+			p, ok := cr.(poller)
+			if !ok {
+				t.Fatalf("expected %T to implement poll", cr)
+			}
+
+			ready, err := p.poll(10 * time.Millisecond)
+			if ready || err != nil {
+				t.Errorf("expected no input, but got %v, %v", ready, err)
+			}
+
+			_, _ = pw.Write([]byte("x"))
+			ready, err = p.poll(10 * time.Millisecond)
+			if !ready || err != nil {
+				t.Errorf("expected input, but got %v, %v", ready, err)
+			}
+		})
+	}
+}
+
+func TestReaderResetStaleSignal(t *testing.T) {
+	for _, backend := range Backends() {
+		if backend == backendFallback {
+			continue
+		}
+
+		t.Run(backend, func(t *testing.T) {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer pw.Close()
+			defer pr.Close()
+
+			cr, err := NewReader(pr, WithBackend(backend))
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer cr.Close()
+
+			cr.Cancel()
+			err = cr.(resetter).reset()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+
+			// a cancel signal racing with the reset stays in the pipe
+			cr.Cancel()
+			cr.(*infoReader).CancelReader.(interface{ resetCanceled() }).resetCanceled()
+
+			_, _ = pw.Write([]byte("x"))
+			p := make([]byte, 1)
+			n, err := cr.Read(p)
+			if err != nil {
+				t.Errorf("expected no error, but got %s", err)
+			}
+			if string(p[:n]) != "x" {
+				t.Errorf("expected to read %q but got %q", "x", string(p[:n]))
+			}
+		})
+	}
+}
+
+func TestReaderReadContext(t *testing.T) {
+	for _, backend := range Backends() {
+		if backend == backendFallback {
+			continue
+		}
+
+		t.Run(backend, func(t *testing.T) {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer pw.Close()
+			defer pr.Close()
+
+			cr, err := NewReader(pr, WithBackend(backend))
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer cr.Close()
+
+			r := cr.(ContextReader)
+			p := make([]byte, 1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := r.ReadContext(ctx, p); err != context.DeadlineExceeded {
+				t.Errorf("expected deadline error but got %v", err)
+			}
+
+			ctx, cancel = context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			if _, err := r.ReadContext(ctx, p); err != context.Canceled {
+				t.Errorf("expected canceled context error but got %v", err)
+			}
+
+			_, _ = pw.Write([]byte("x"))
+			n, err := r.ReadContext(context.Background(), p)
+			if err != nil {
+				t.Errorf("expected no error, but got %s", err)
+			}
+			if string(p[:n]) != "x" {
+				t.Errorf("expected to read %q but got %q", "x", string(p[:n]))
+			}
+		})
+	}
+}
+
+func TestInputKind(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	f, err := os.CreateTemp(t.TempDir(), "input")
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer f.Close()
+
+	for _, tc := range []struct {
+		reader   io.Reader
+		expected Kind
+	}{
+		{pr, KindPipe},
+		{f, KindFile},
+		{strings.NewReader(""), KindUnknown},
+	} {
+		cr, err := NewReader(tc.reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+
+		if k := InputKind(cr); k != tc.expected {
+			t.Errorf("expected %s for %T, but got %s", tc.expected, tc.reader, k)
+		}
+		cr.Close()
+	}
+}
+
+func TestReadConfirmCountdown(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	time.AfterFunc(1500*time.Millisecond, func() { _, _ = pw.Write([]byte("n")) })
+
+	var w strings.Builder
+	yes, err := ReadConfirm(cr, &w, "delete?", true, 3*time.Second)
+	if err != nil || yes {
+		t.Errorf("expected no, but got %v, %v", yes, err)
+	}
+	if !strings.Contains(w.String(), "[Y/n] 3s") || !strings.Contains(w.String(), "[Y/n] 2s") {
+		t.Errorf("expected the countdown to be redrawn, but got %q", w.String())
+	}
+}
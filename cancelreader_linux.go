@@ -4,29 +4,59 @@
 package cancelreader
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
 
-// NewReader returns a reader and a cancel function. If the input reader is a
+// newReader returns a reader and a cancel function. If the input reader is a
 // File, the cancel function can be used to interrupt a blocking read call.
 // In this case, the cancel function returns true if the call was canceled
 // successfully. If the input reader is not a File, the cancel function
 // does nothing and always returns false. The Linux implementation is based on
 // the epoll mechanism.
-func NewReader(reader io.Reader) (CancelReader, error) {
+func newReader(reader io.Reader, cfg *config) (CancelReader, error) {
+
 	file, ok := reader.(File)
-	if !ok {
+	switch {
+	case cfg.backend == backendFallback:
+		return newFallbackCancelReader(reader)
+	case !ok && cfg.backend != "":
+		return nil, errUnsupportedInput(cfg.backend, reader)
+	case !ok:
 		return newFallbackCancelReader(reader)
 	}
 
+	switch cfg.backend {
+	case "":
+		// epoll does not support regular files, which never block anyway
+		if fileKind(file) == KindFile {
+			return newFallbackCancelReader(file)
+		}
+
+		return newEpollCancelReader(file)
+	case backendEpoll:
+		return newEpollCancelReader(file)
+	case backendSelect:
+		return newSelectCancelReader(file)
+	case backendNetpoll:
+		return newNetpollCancelReader(file)
+	default:
+		return nil, errUnknownBackend(cfg.backend)
+	}
+}
+
+var backends = []string{backendEpoll, backendSelect, backendNetpoll, backendFallback}
+
+func newEpollCancelReader(file File) (CancelReader, error) {
 	epoll, err := unix.EpollCreate1(0)
 	if err != nil {
-		return nil, fmt.Errorf("create epoll: %w", err)
+		return nil, syscallError(backendEpoll, "epoll_create1", file.Fd(), err)
 	}
 
 	r := &epollCancelReader{
@@ -45,8 +75,8 @@ func NewReader(reader io.Reader) (CancelReader, error) {
 		Fd:     int32(file.Fd()),
 	})
 	if err != nil {
-		_ = unix.Close(epoll)
-		return nil, fmt.Errorf("add reader to epoll interest list")
+		_ = r.Close()
+		return nil, syscallError(backendEpoll, "epoll_ctl", file.Fd(), err)
 	}
 
 	err = unix.EpollCtl(epoll, unix.EPOLL_CTL_ADD, int(r.cancelSignalReader.Fd()), &unix.EpollEvent{
@@ -54,8 +84,10 @@ func NewReader(reader io.Reader) (CancelReader, error) {
 		Fd:     int32(r.cancelSignalReader.Fd()),
 	})
 	if err != nil {
-		_ = unix.Close(epoll)
-		return nil, fmt.Errorf("add reader to epoll interest list")
+		err = syscallError(backendEpoll, "epoll_ctl", r.cancelSignalReader.Fd(), err)
+		_ = r.Close()
+
+		return nil, err
 	}
 
 	return r, nil
@@ -66,46 +98,135 @@ type epollCancelReader struct {
 	cancelSignalReader File
 	cancelSignalWriter File
 	cancelMixin
-	epoll int
+	epoll  int
+	resume resumeFlag
 }
 
 func (r *epollCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *epollCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
 	if r.isCanceled() {
 		return 0, ErrCanceled
 	}
 
-	err := r.wait()
-	if err != nil {
+	stop := wakeOnDone(ctx, r.cancelSignalWriter)
+	defer stop()
+
+	for {
+		err := contextErr(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		if r.resume.take() {
+			err = r.revalidate()
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		msec := -1
+		if timeout, ok := timeUntil(ctx); ok {
+			msec = int(timeout.Milliseconds())
+		}
+
+		err = r.wait(msec)
 		if errors.Is(err, ErrCanceled) {
-			// remove signal from pipe
-			var b [1]byte
-			_, readErr := r.cancelSignalReader.Read(b[:])
-			if readErr != nil {
-				return 0, fmt.Errorf("reading cancel signal: %w", readErr)
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
+
+		switch {
+		case errors.Is(err, errStaleSignal), errors.Is(err, ErrTimeout):
+			continue // checks ctx again
+		case err != nil:
+			return 0, err
+		}
+
+		return r.file.Read(data)
+	}
+}
+
+func (r *epollCancelReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	for {
+		err := r.wait(int(timeout.Milliseconds()))
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+			if errors.Is(err, errStaleSignal) {
+				continue
 			}
 		}
 
-		return 0, err
+		if errors.Is(err, ErrTimeout) {
+			return false, nil
+		}
+
+		return err == nil, err
 	}
+}
+
+func (r *epollCancelReader) reset() error {
+	r.resetCanceled()
+	return drainCancelSignals(r.cancelSignalReader)
+}
 
-	return r.file.Read(data)
+func (r *epollCancelReader) resumed() {
+	r.resume.mark()
+	_, _ = r.cancelSignalWriter.Write([]byte{wakeSignal})
+}
+
+// revalidate registers the file and the cancel signal again in case the
+// registrations were lost while the system was suspended.
+func (r *epollCancelReader) revalidate() error {
+	for _, fd := range []int{int(r.file.Fd()), int(r.cancelSignalReader.Fd())} {
+		event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+
+		err := unix.EpollCtl(r.epoll, unix.EPOLL_CTL_MOD, fd, &event)
+		if errors.Is(err, unix.ENOENT) {
+			err = unix.EpollCtl(r.epoll, unix.EPOLL_CTL_ADD, fd, &event)
+		}
+
+		if err != nil {
+			return fmt.Errorf("revalidate epoll registration: %w", syscallError(backendEpoll, "epoll_ctl", uintptr(fd), err))
+		}
+	}
+
+	return nil
+}
+
+func (r *epollCancelReader) cancelSignal() File {
+	return r.cancelSignalWriter
+}
+
+// cancelSignalSource returns the end of the cancel signal pipe that becomes
+// readable on Cancel, see Select.
+func (r *epollCancelReader) cancelSignalSource() File {
+	return r.cancelSignalReader
+}
+
+func (r *epollCancelReader) backendName() string {
+	return backendEpoll
 }
 
 func (r *epollCancelReader) Cancel() bool {
-	r.setCanceled()
+	generation := r.setCanceled()
 
 	// send cancel signal
-	_, err := r.cancelSignalWriter.Write([]byte{'c'})
-	return err == nil
+	return sendCancelSignal(r.cancelSignalWriter, generation)
 }
 
 func (r *epollCancelReader) Close() error {
 	var e1, e2, e3 error
 
-	// close kqueue
+	// close epoll
 	err := unix.Close(r.epoll)
 	if err != nil {
-		e1 = fmt.Errorf("closing epoll: %w", err)
+		e1 = fmt.Errorf("closing epoll: %w", syscallError(backendEpoll, "close", uintptr(r.epoll), err))
 	}
 
 	// close pipe
@@ -123,17 +244,22 @@ func (r *epollCancelReader) Close() error {
 
 }
 
-func (r *epollCancelReader) wait() error {
+// wait waits up to msec milliseconds or forever if msec is -1.
+func (r *epollCancelReader) wait(msec int) error {
 	events := make([]unix.EpollEvent, 1)
 
 	for {
-		_, err := unix.EpollWait(r.epoll, events, -1)
+		n, err := unix.EpollWait(r.epoll, events, msec)
 		if errors.Is(err, unix.EINTR) {
 			continue // try again if the syscall was interrupted
 		}
 
 		if err != nil {
-			return fmt.Errorf("kevent: %w", err)
+			return syscallError(backendEpoll, "epoll_wait", uintptr(r.epoll), err)
+		}
+
+		if n == 0 {
+			return ErrTimeout
 		}
 
 		break
@@ -146,5 +272,5 @@ func (r *epollCancelReader) wait() error {
 		return ErrCanceled
 	}
 
-	return fmt.Errorf("unknown error")
+	return fmt.Errorf("epoll_wait returned unknown file descriptor %d", events[0].Fd)
 }
@@ -0,0 +1,72 @@
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestEpollResumeRevalidate(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	cr, err := NewReader(pr, WithBackend(backendEpoll), WithResumeDetection())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	// lose the registration of the input like after a suspension
+	r := cr.(*infoReader).CancelReader.(*epollCancelReader)
+	err = unix.EpollCtl(r.epoll, unix.EPOLL_CTL_DEL, int(pr.Fd()), nil)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	notifyResumed(HealthResumed)
+
+	_, _ = pw.Write([]byte("x"))
+	p := make([]byte, 1)
+	n, err := cr.Read(p)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(p[:n]) != "x" {
+		t.Errorf("expected to read %q but got %q", "x", string(p[:n]))
+	}
+}
+
+func TestEpollSyscallError(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "input")
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer f.Close()
+
+	fds, _ := os.ReadDir("/proc/self/fd")
+
+	// epoll does not support regular files
+	_, err = NewReader(f, WithBackend(backendEpoll))
+
+	// the epoll instance and cancel pipe are closed again
+	if after, _ := os.ReadDir("/proc/self/fd"); len(after) != len(fds) {
+		t.Errorf("expected %d open files, but got %d", len(fds), len(after))
+	}
+
+	var serr *SyscallError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a SyscallError, but got %v", err)
+	}
+	if serr.Backend != backendEpoll || serr.Op != "epoll_ctl" || serr.Fd != f.Fd() {
+		t.Errorf("expected epoll_ctl on %d, but got %s", f.Fd(), serr)
+	}
+	if !errors.Is(err, unix.EPERM) {
+		t.Errorf("expected EPERM, but got %s", err)
+	}
+}
@@ -0,0 +1,198 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// newNetpollCancelReader returns a reader that registers a duplicate of the
+// file descriptor with the poller of the Go runtime instead of creating its
+// own epoll or kqueue instance and cancel pipe, so a blocked Read parks its
+// goroutine like a network read and Cancel sets the read deadline.
+//
+// The backend is experimental: the file descriptor is put into non-blocking
+// mode, which is shared with every other user of the input, until the reader
+// is closed. Regular files are not supported.
+func newNetpollCancelReader(file File) (CancelReader, error) {
+	fd, err := unix.Dup(int(file.Fd()))
+	if err != nil {
+		return nil, syscallError(backendNetpoll, "dup", file.Fd(), err)
+	}
+
+	err = unix.SetNonblock(fd, true)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, syscallError(backendNetpoll, "fcntl", file.Fd(), err)
+	}
+
+	f := os.NewFile(uintptr(fd), file.Name())
+
+	// deadlines are only supported if the runtime poller accepted the file
+	err = f.SetReadDeadline(time.Time{})
+	if err != nil {
+		_ = unix.SetNonblock(fd, false)
+		_ = f.Close()
+
+		return nil, fmt.Errorf("%s backend can't poll %s: %w", backendNetpoll, file.Name(), err)
+	}
+
+	return &netpollCancelReader{file: f}, nil
+}
+
+type netpollCancelReader struct {
+	file *os.File
+	cancelMixin
+}
+
+func (r *netpollCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *netpollCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
+	var n int
+
+	err := r.await(ctx, func() (err error) {
+		n, err = r.file.Read(data)
+		return err
+	})
+
+	return n, err
+}
+
+func (r *netpollCancelReader) poll(timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	raw, err := r.file.SyscallConn()
+	if err != nil {
+		return false, fmt.Errorf("poll: %w", err)
+	}
+
+	err = r.await(ctx, func() error {
+		waited := false
+
+		// the runtime waits for input whenever the function returns false,
+		// so check for input that is already there first
+		return raw.Read(func(fd uintptr) bool {
+			if waited {
+				return true
+			}
+			waited = true
+
+			fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+			n, err := unix.Poll(fds, 0)
+
+			return n > 0 || err != nil
+		})
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// await runs the blocking call with the read deadline set by ctx and
+// Cancel. It returns ErrCanceled and ctx.Err() for deadlines set by them.
+func (r *netpollCancelReader) await(ctx context.Context, call func() error) error {
+	if r.isCanceled() {
+		return ErrCanceled
+	}
+
+	err := contextErr(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Fd of an *os.File for the same input makes it blocking again
+	err = r.setNonblock(true)
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if ok {
+		_ = r.file.SetReadDeadline(deadline)
+	}
+
+	fired := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = r.file.SetReadDeadline(time.Now())
+		close(fired)
+	})
+
+	err = call()
+	for errors.Is(err, os.ErrDeadlineExceeded) && !r.isCanceled() && contextErr(ctx) == nil {
+		// deadline left over from a cancelation before a reset
+		_ = r.file.SetReadDeadline(deadline)
+		err = call()
+	}
+
+	stopped := stop()
+	if !stopped {
+		<-fired
+	}
+
+	if (ok || !stopped) && !r.isCanceled() {
+		_ = r.file.SetReadDeadline(time.Time{})
+	}
+
+	switch {
+	case r.isCanceled():
+		return ErrCanceled
+	case errors.Is(err, os.ErrDeadlineExceeded) && contextErr(ctx) != nil:
+		return contextErr(ctx)
+	}
+
+	return err // nolint: wrapcheck
+}
+
+func (r *netpollCancelReader) reset() error {
+	r.resetCanceled()
+
+	return r.file.SetReadDeadline(time.Time{}) // nolint: wrapcheck
+}
+
+func (r *netpollCancelReader) backendName() string {
+	return backendNetpoll
+}
+
+func (r *netpollCancelReader) Cancel() bool {
+	r.setCanceled()
+
+	return r.file.SetReadDeadline(time.Now()) == nil
+}
+
+// Close restores the blocking mode of the input and closes the duplicate.
+func (r *netpollCancelReader) Close() error {
+	return errors.Join(r.setNonblock(false), r.file.Close())
+}
+
+func (r *netpollCancelReader) setNonblock(nonblocking bool) error {
+	raw, err := r.file.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("set non-blocking mode: %w", err)
+	}
+
+	var serr error
+
+	err = raw.Control(func(fd uintptr) {
+		serr = unix.SetNonblock(int(fd), nonblocking)
+		if serr != nil {
+			serr = syscallError(backendNetpoll, "fcntl", fd, serr)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("set non-blocking mode: %w", err)
+	}
+
+	return serr
+}
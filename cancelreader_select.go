@@ -1,13 +1,15 @@
-//go:build solaris || darwin || freebsd || netbsd || openbsd || dragonfly
-// +build solaris darwin freebsd netbsd openbsd dragonfly
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
 
 package cancelreader
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -44,26 +46,40 @@ type selectCancelReader struct {
 }
 
 func (r *selectCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *selectCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
 	if r.isCanceled() {
 		return 0, ErrCanceled
 	}
 
+	stop := wakeOnDone(ctx, r.cancelSignalWriter)
+	defer stop()
+
 	for {
-		err := waitForRead(r.file, r.cancelSignalReader)
+		err := contextErr(ctx)
 		if err != nil {
-			if errors.Is(err, unix.EINTR) {
-				continue // try again if the syscall was interrupted
-			}
-
-			if errors.Is(err, ErrCanceled) {
-				// remove signal from pipe
-				var b [1]byte
-				_, readErr := r.cancelSignalReader.Read(b[:])
-				if readErr != nil {
-					return 0, fmt.Errorf("reading cancel signal: %w", readErr)
-				}
-			}
+			return 0, err
+		}
+
+		var tv *unix.Timeval
+		if timeout, ok := timeUntil(ctx); ok {
+			t := unix.NsecToTimeval(timeout.Nanoseconds())
+			tv = &t
+		}
+
+		err = waitForRead(r.file, r.cancelSignalReader, tv)
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
 
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue // try again if the syscall was interrupted
+		case errors.Is(err, errStaleSignal), errors.Is(err, ErrTimeout):
+			continue // checks ctx again
+		case err != nil:
 			return 0, err
 		}
 
@@ -71,12 +87,54 @@ func (r *selectCancelReader) Read(data []byte) (int, error) {
 	}
 }
 
+func (r *selectCancelReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	for {
+		tv := unix.NsecToTimeval(timeout.Nanoseconds())
+
+		err := waitForRead(r.file, r.cancelSignalReader, &tv)
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
+
+		switch {
+		case errors.Is(err, unix.EINTR), errors.Is(err, errStaleSignal):
+			continue
+		case errors.Is(err, ErrTimeout):
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+}
+
+func (r *selectCancelReader) reset() error {
+	r.resetCanceled()
+	return drainCancelSignals(r.cancelSignalReader)
+}
+
+func (r *selectCancelReader) cancelSignal() File {
+	return r.cancelSignalWriter
+}
+
+// cancelSignalSource returns the end of the cancel signal pipe that becomes
+// readable on Cancel, see Select.
+func (r *selectCancelReader) cancelSignalSource() File {
+	return r.cancelSignalReader
+}
+
+func (r *selectCancelReader) backendName() string {
+	return backendSelect
+}
+
 func (r *selectCancelReader) Cancel() bool {
-	r.setCanceled()
+	generation := r.setCanceled()
 
 	// send cancel signal
-	_, err := r.cancelSignalWriter.Write([]byte{'c'})
-	return err == nil
+	return sendCancelSignal(r.cancelSignalWriter, generation)
 }
 
 func (r *selectCancelReader) Close() error {
@@ -96,7 +154,9 @@ func (r *selectCancelReader) Close() error {
 	return errors.Join(e1, e2)
 }
 
-func waitForRead(reader, abort File) error {
+// waitForRead waits until reader or abort is readable, until timeout or
+// forever if timeout is nil.
+func waitForRead(reader, abort File, timeout *unix.Timeval) error {
 	readerFd := int(reader.Fd())
 	abortFd := int(abort.Fd())
 
@@ -114,9 +174,13 @@ func waitForRead(reader, abort File) error {
 	fdSet.Set(int(reader.Fd()))
 	fdSet.Set(int(abort.Fd()))
 
-	_, err := unix.Select(maxFd+1, fdSet, nil, nil, nil)
+	n, err := unix.Select(maxFd+1, fdSet, nil, nil, timeout)
 	if err != nil {
-		return fmt.Errorf("select: %w", err)
+		return syscallError(backendSelect, "select", reader.Fd(), err)
+	}
+
+	if n == 0 {
+		return ErrTimeout
 	}
 
 	if fdSet.IsSet(abortFd) {
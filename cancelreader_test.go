@@ -1,8 +1,14 @@
 package cancelreader
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestReaderNonFile(t *testing.T) {
@@ -15,3 +21,247 @@ func TestReaderNonFile(t *testing.T) {
 		t.Errorf("expected cancellation to be failure")
 	}
 }
+
+func TestReaderBackendOverride(t *testing.T) {
+	if _, err := NewReader(strings.NewReader(""), WithBackend("nonexistent")); err == nil {
+		t.Errorf("expected error for unknown backend")
+	}
+
+	cr, err := NewReader(strings.NewReader(""), WithBackend(backendFallback))
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	if b := Backend(cr); b != backendFallback {
+		t.Errorf("expected fallback reader, got %q", b)
+	}
+
+	if backends := Backends(); backends[len(backends)-1] != backendFallback {
+		t.Errorf("expected fallback backend to be available, got %q", backends)
+	}
+}
+
+func TestFirstReadHook(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	var calls []FirstRead
+	cr, err := NewReader(pr, WithFirstReadHook(func(first FirstRead) {
+		calls = append(calls, first)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	if _, ok := FirstReadLatency(cr); ok {
+		t.Errorf("expected no latency before reading")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = pw.Write([]byte("ab"))
+	}()
+
+	p := make([]byte, 1)
+	for i := 0; i < 2; i++ {
+		if _, err = cr.Read(p); err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+	}
+
+	first, ok := FirstReadLatency(cr)
+	if !ok || first.SinceCreated < 20*time.Millisecond || first.SinceRead > first.SinceCreated {
+		t.Errorf("expected first read after 20ms, but got %+v", first)
+	}
+
+	if len(calls) != 1 || calls[0] != first {
+		t.Errorf("expected one call of the hook with %+v, but got %+v", first, calls)
+	}
+}
+
+func TestReadByte(t *testing.T) {
+	cr, err := NewReader(strings.NewReader("ab"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	br, ok := cr.(io.ByteReader)
+	if !ok {
+		t.Fatalf("expected an io.ByteReader, but got %T", cr)
+	}
+
+	for _, want := range []byte("ab") {
+		b, err := br.ReadByte()
+		if err != nil || b != want {
+			t.Errorf("expected %q, but got %q, %v", want, b, err)
+		}
+	}
+
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+
+	cr.Cancel()
+	if _, err := br.ReadByte(); err != ErrCanceled {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
+
+// splitReader returns at most one of its chunks per Read.
+type splitReader struct {
+	chunks []string
+}
+
+func (r *splitReader) Read(data []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(data, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+
+	return n, nil
+}
+
+func TestReadRune(t *testing.T) {
+	cr, err := NewReader(&splitReader{chunks: []string{"a\xd0", "\xb6\xe2\x82", "\xac\xff", "b"}})
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	rr, ok := cr.(io.RuneReader)
+	if !ok {
+		t.Fatalf("expected an io.RuneReader, but got %T", cr)
+	}
+
+	for _, want := range []rune{'a', 'ж', '€', utf8.RuneError, 'b'} {
+		c, _, err := rr.ReadRune()
+		if err != nil || c != want {
+			t.Errorf("expected %q, but got %q, %v", want, c, err)
+		}
+	}
+
+	if _, _, err := rr.ReadRune(); err != io.EOF {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+}
+
+func TestReadRuneTruncated(t *testing.T) {
+	cr, err := NewReader(strings.NewReader("\xe2\x82"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	rr := cr.(io.RuneReader)
+	for i := 0; i < 2; i++ {
+		c, size, err := rr.ReadRune()
+		if err != nil || c != utf8.RuneError || size != 1 {
+			t.Errorf("expected %q of size 1, but got %q of size %d, %v", utf8.RuneError, c, size, err)
+		}
+	}
+
+	if _, _, err := rr.ReadRune(); err != io.EOF {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	cr, err := NewReader(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	var b strings.Builder
+	n, err := io.Copy(&b, cr)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if n != 5 || b.String() != "hello" {
+		t.Errorf("expected %q, but got %q (%d bytes)", "hello", b.String(), n)
+	}
+
+	cr.Cancel()
+	if _, err := io.Copy(&b, cr); err != ErrCanceled {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
+
+func TestUnread(t *testing.T) {
+	cr, err := NewReader(strings.NewReader("c"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	Unread(cr, []byte("b"))
+	Unread(cr, []byte("a"))
+
+	data, err := io.ReadAll(cr)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data) != "abc" {
+		t.Errorf("expected %q, but got %q", "abc", data)
+	}
+}
+
+func TestEOFOnCancel(t *testing.T) {
+	cr, err := NewReader(strings.NewReader("hello"), WithEOFOnCancel())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	cr.Cancel()
+	if _, err := cr.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+}
+
+func TestErrCanceled(t *testing.T) {
+	if !errors.Is(ErrCanceled, context.Canceled) {
+		t.Errorf("expected ErrCanceled to match context.Canceled")
+	}
+
+	var ne net.Error
+	if !errors.As(ErrCanceled, &ne) || ne.Timeout() {
+		t.Errorf("expected ErrCanceled to be a net.Error without timeout")
+	}
+
+	if contextDone(ErrCanceled) || !contextDone(context.Canceled) {
+		t.Errorf("expected ErrCanceled to be told apart from context.Canceled")
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestOwnership(t *testing.T) {
+	input := &closeRecorder{Reader: strings.NewReader("")}
+	cr, err := NewReader(input, WithOwnership())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if err := cr.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if !input.closed {
+		t.Errorf("expected the input to be closed")
+	}
+}
@@ -4,86 +4,336 @@
 package cancelreader
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 	"unicode/utf16"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
 var fileShareValidFlags uint32 = 0x00000007
 
-// NewReader returns a reader and a cancel function. If the input reader is a
+// maxReopen limits how often a single Read re-opens CONIN$ after the console
+// handle was invalidated.
+const maxReopen = 3
+
+// newReader returns a reader and a cancel function. If the input reader is a
 // File with the same file descriptor as os.Stdin, the cancel function can
 // be used to interrupt a blocking read call. In this case, the cancel function
 // returns true if the call was canceled successfully. If the input reader is
 // not a File with the same file descriptor as os.Stdin, the cancel
 // function does nothing and always returns false. The Windows implementation
 // is based on WaitForMultipleObject with overlapping reads from CONIN$.
-func NewReader(reader io.Reader) (CancelReader, error) {
-	if f, ok := reader.(File); !ok || f.Fd() != os.Stdin.Fd() {
+// Under winpty, key translation is enabled unless the console reports
+// virtual terminal input.
+func newReader(reader io.Reader, cfg *config) (CancelReader, error) {
+
+	f, ok := reader.(File)
+	isStdin := ok && (f.Fd() == os.Stdin.Fd() || cfg.tty)
+
+	switch cfg.backend {
+	case "":
+		if !isStdin {
+			return newFallbackCancelReader(reader)
+		}
+	case backendConsole:
+		if !isStdin {
+			return nil, errUnsupportedInput(cfg.backend, reader)
+		}
+	case backendFallback:
 		return newFallbackCancelReader(reader)
+	default:
+		return nil, errUnknownBackend(cfg.backend)
 	}
 
-	// it is necessary to open CONIN$ (NOT windows.STD_INPUT_HANDLE) in
-	// overlapped mode to be able to use it with WaitForMultipleObjects.
-	conin, err := windows.CreateFile(
-		&(utf16.Encode([]rune("CONIN$\x00"))[0]), windows.GENERIC_READ|windows.GENERIC_WRITE,
-		fileShareValidFlags, nil, windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+	conin, err := openConin(!cfg.noInputFlush)
 	if err != nil {
-		return nil, fmt.Errorf("open CONIN$ in overlapping mode: %w", err)
+		return nil, err
 	}
 
-	// flush input, otherwise it can contain events which trigger
-	// WaitForMultipleObjects but which ReadFile cannot read, resulting in an
-	// un-cancelable read
-	err = flushConsoleInputBuffer(conin)
+	cancelEvent, err := createCancelEvent(cfg.cancelEventName)
 	if err != nil {
-		return nil, fmt.Errorf("flush console input buffer: %w", err)
+		_ = windows.Close(conin)
+		return nil, err
 	}
 
-	cancelEvent, err := windows.CreateEvent(nil, 0, 0, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create stop event: %w", err)
+	translateKeys := cfg.translateKeys
+	if !translateKeys && IsWinpty() && !virtualTerminalInput() {
+		// special keys only arrive as input records under winpty
+		translateKeys = true
+	}
+
+	cancelTimeout := cfg.cancelTimeout
+	if cancelTimeout <= 0 {
+		cancelTimeout = defaultCancelTimeout
 	}
 
 	return &winCancelReader{
 		conin:              conin,
+		flushInput:         !cfg.noInputFlush,
+		cancelTimeout:      cancelTimeout,
 		cancelEvent:        cancelEvent,
+		cancelByName:       cfg.cancelEventName != "",
 		blockingReadSignal: make(chan struct{}, 1),
+		translateKeys:      translateKeys,
+		records:            cfg.records,
+		recordsOnly:        cfg.recordsOnly,
+		canceled:           make(chan struct{}),
+		translator: inputTranslator{
+			events: cfg.translateEvents,
+			window: stdoutWindow,
+		},
 	}, nil
 }
 
+var backends = []string{backendConsole, backendFallback}
+
+// defaultCancelTimeout is how long Cancel waits for the pending Read unless
+// WithCancelTimeout is given.
+const defaultCancelTimeout = 100 * time.Millisecond
+
 type winCancelReader struct {
 	conin       windows.Handle
 	cancelEvent windows.Handle
 	cancelMixin
 
+	flushInput    bool          // see WithoutInputFlush
+	cancelTimeout time.Duration // see WithCancelTimeout
+
+	// cancelByName is set if other processes can set the cancel event.
+	cancelByName bool
+	resume       resumeFlag
+
 	blockingReadSignal chan struct{}
+
+	translateKeys bool
+	translator    inputTranslator
+	pending       []byte
+	records       chan<- InputRecord
+	recordsOnly   bool
+
+	// canceled is closed by Cancel to abort sending input records.
+	canceled   chan struct{}
+	cancelOnce sync.Once
 }
 
 func (r *winCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *winCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
 	if r.isCanceled() {
 		return 0, ErrCanceled
 	}
 
-	err := r.wait()
-	if err != nil {
-		return 0, err
+	if len(r.pending) > 0 {
+		n := copy(data, r.pending)
+		r.pending = r.pending[n:]
+
+		return n, nil
+	}
+
+	// wake up the wait without canceling, which is then treated like a
+	// cancel event set before a reset
+	woken := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = windows.SetEvent(r.cancelEvent)
+		close(woken)
+	})
+	defer func() {
+		if !stop() {
+			// don't leave the wake up to the next Read
+			<-woken
+			_ = windows.ResetEvent(r.cancelEvent)
+		}
+	}()
+
+	reopened := 0
+
+	for {
+		n, err := r.readOnce(ctx, data)
+		if isStaleHandle(err) && reopened < maxReopen {
+			reopened++
+
+			err = r.reopen()
+			if err == nil {
+				continue
+			}
+		}
+
+		if n > 0 || err != nil || !r.translateKeys {
+			return n, err
+		}
+
+		// only events without a translation were available, e.g. key up
+	}
+}
+
+func (r *winCancelReader) readOnce(ctx context.Context, data []byte) (int, error) {
+	for {
+		err := contextErr(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		msec := uint32(windows.INFINITE)
+		if timeout, ok := timeUntil(ctx); ok {
+			msec = uint32(timeout.Milliseconds())
+		}
+
+		err = r.wait(msec)
+		switch {
+		case errors.Is(err, ErrCanceled) && !r.isCanceled():
+			if r.resume.take() {
+				err = r.revalidate()
+				if err != nil {
+					return 0, err
+				}
+
+				continue
+			}
+
+			if r.cancelByName && contextErr(ctx) == nil {
+				r.cancelExternally()
+				return 0, ErrCanceled
+			}
+
+			continue // set before a reset or when ctx is done
+		case errors.Is(err, ErrTimeout):
+			continue // checks ctx again
+		case err != nil:
+			return 0, err
+		}
+
+		break
 	}
 
 	if r.isCanceled() {
 		return 0, ErrCanceled
 	}
 
+	if r.translateKeys {
+		return r.readTranslated(data)
+	}
+
 	// windows.Read does not work on overlapping windows.Handles
 	return r.readAsync(data)
 }
 
+func (r *winCancelReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for len(r.pending) == 0 {
+		left := time.Until(deadline)
+		if left < 0 {
+			left = 0
+		}
+
+		err := r.wait(uint32(left.Milliseconds()))
+		switch {
+		case errors.Is(err, ErrCanceled) && !r.isCanceled():
+			if r.cancelByName {
+				r.cancelExternally()
+				return false, ErrCanceled
+			}
+
+			continue // set before a reset
+		case errors.Is(err, ErrTimeout):
+			return false, nil
+		case err != nil:
+			return false, err
+		case !r.translateKeys:
+			return true, nil
+		}
+
+		// only count records with a translation, not e.g. key releases
+		_, err = r.readTranslated(nil)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// reopen replaces an invalidated CONIN$ handle, which happens when conhost is
+// restarted or a remote desktop session reconnects. The cancel event is owned
+// by this process and stays valid.
+func (r *winCancelReader) reopen() error {
+	conin, err := openConin(r.flushInput)
+	if err != nil {
+		return err
+	}
+
+	// the old handle is invalid, so closing it is expected to fail
+	_ = windows.Close(r.conin)
+	r.conin = conin
+
+	return nil
+}
+
+// readTranslated reads the available console input records, forwards them
+// to the records channel and translates them into escape sequences. Sequences
+// that do not fit into data are returned by the next Read call.
+func (r *winCancelReader) readTranslated(data []byte) (int, error) {
+	var records [16]InputRecord
+
+	count, err := readConsoleInput(r.conin, records[:])
+	if err != nil {
+		return 0, err
+	}
+
+	var buf []byte
+	for i := 0; i < count; i++ {
+		if r.records != nil {
+			select {
+			case r.records <- records[i]:
+			case <-r.canceled:
+				return 0, ErrCanceled
+			}
+		}
+
+		if !r.recordsOnly {
+			buf = r.translator.translate(buf, &records[i])
+		}
+	}
+
+	n := copy(data, buf)
+	r.pending = buf[n:]
+
+	return n, nil
+}
+
+// reset makes the reader usable again after a cancelation. It must not be
+// called concurrently with Read.
+func (r *winCancelReader) reset() error {
+	err := windows.ResetEvent(r.cancelEvent)
+	if err != nil {
+		return syscallError(backendConsole, "ResetEvent", uintptr(r.cancelEvent), err)
+	}
+
+	r.resetCanceled()
+	r.canceled = make(chan struct{})
+	r.cancelOnce = sync.Once{}
+
+	return nil
+}
+
+func (r *winCancelReader) backendName() string {
+	return backendConsole
+}
+
 // Cancel cancels ongoing and future Read() calls and returns true if the
 // cancelation of the ongoing Read() was successful. On Windows Terminal,
 // WaitForMultipleObjects sometimes immediately returns without input being
@@ -91,6 +341,7 @@ func (r *winCancelReader) Read(data []byte) (int, error) {
 // returns false.
 func (r *winCancelReader) Cancel() bool {
 	r.setCanceled()
+	r.cancelOnce.Do(func() { close(r.canceled) })
 
 	select {
 	case r.blockingReadSignal <- struct{}{}:
@@ -99,7 +350,7 @@ func (r *winCancelReader) Cancel() bool {
 			return false
 		}
 		<-r.blockingReadSignal
-	case <-time.After(100 * time.Millisecond):
+	case <-time.After(r.cancelTimeout):
 		// Read() hangs in a GetOverlappedResult which is likely due to
 		// WaitForMultipleObjects returning without input being available
 		// so we cannot cancel this ongoing read.
@@ -109,22 +360,48 @@ func (r *winCancelReader) Cancel() bool {
 	return true
 }
 
+func (r *winCancelReader) resumed() {
+	r.resume.mark()
+	_ = windows.SetEvent(r.cancelEvent)
+}
+
+// revalidate reopens CONIN$ if its handle did not survive the suspension.
+func (r *winCancelReader) revalidate() error {
+	var n uint32
+
+	ok, _, _ := syscall.Syscall(procGetNumberOfConsoleInputEvents.Addr(), 2,
+		uintptr(r.conin), uintptr(unsafe.Pointer(&n)), 0)
+	if ok != 0 {
+		return nil
+	}
+
+	return r.reopen()
+}
+
+// cancelExternally cancels the reader after another process set the named
+// cancel event.
+func (r *winCancelReader) cancelExternally() {
+	r.setCanceled()
+	r.cancelOnce.Do(func() { close(r.canceled) })
+}
+
 func (r *winCancelReader) Close() error {
 	var e1, e2 error
 
 	if err := windows.CloseHandle(r.cancelEvent); err != nil {
-		e1 = fmt.Errorf("closing cancel event handle: %w", err)
+		e1 = fmt.Errorf("closing cancel event handle: %w", syscallError(backendConsole, "CloseHandle", uintptr(r.cancelEvent), err))
 	}
 
 	if err := windows.Close(r.conin); err != nil {
-		e2 = fmt.Errorf("closing CONIN$: %w", err)
+		e2 = fmt.Errorf("closing CONIN$: %w", syscallError(backendConsole, "CloseHandle", uintptr(r.conin), err))
 	}
 
 	return errors.Join(e1, e2)
 }
 
-func (r *winCancelReader) wait() error {
-	event, err := windows.WaitForMultipleObjects([]windows.Handle{r.conin, r.cancelEvent}, false, windows.INFINITE)
+// wait waits up to msec milliseconds or forever if msec is windows.INFINITE.
+func (r *winCancelReader) wait(msec uint32) error {
+	event, err := windows.WaitForMultipleObjects([]windows.Handle{r.conin, r.cancelEvent}, false, msec)
 	switch {
 	case windows.WAIT_OBJECT_0 <= event && event < windows.WAIT_OBJECT_0+2:
 		if event == windows.WAIT_OBJECT_0+1 {
@@ -139,9 +416,9 @@ func (r *winCancelReader) wait() error {
 	case windows.WAIT_ABANDONED <= event && event < windows.WAIT_ABANDONED+2:
 		return fmt.Errorf("abandoned")
 	case event == uint32(windows.WAIT_TIMEOUT):
-		return fmt.Errorf("timeout")
+		return ErrTimeout
 	case event == windows.WAIT_FAILED:
-		return fmt.Errorf("failed")
+		return syscallError(backendConsole, "WaitForMultipleObjects", uintptr(r.conin), err)
 	default:
 		return fmt.Errorf("unexpected error: %w", error(err))
 	}
@@ -151,8 +428,9 @@ func (r *winCancelReader) wait() error {
 func (r *winCancelReader) readAsync(data []byte) (int, error) {
 	hevent, err := windows.CreateEvent(nil, 0, 0, nil)
 	if err != nil {
-		return 0, fmt.Errorf("create event: %w", err)
+		return 0, syscallError(backendConsole, "CreateEvent", uintptr(r.conin), err)
 	}
+	defer windows.CloseHandle(hevent) // nolint: errcheck
 
 	overlapped := windows.Overlapped{
 		HEvent: hevent,
@@ -162,24 +440,120 @@ func (r *winCancelReader) readAsync(data []byte) (int, error) {
 
 	err = windows.ReadFile(r.conin, data, &n, &overlapped)
 	if err != nil && err != windows.ERROR_IO_PENDING {
-		return int(n), err
+		return int(n), syscallError(backendConsole, "ReadFile", uintptr(r.conin), err)
 	}
 
 	r.blockingReadSignal <- struct{}{}
 	err = windows.GetOverlappedResult(r.conin, &overlapped, &n, true)
-	if err != nil {
-		return int(n), nil
-	}
 	<-r.blockingReadSignal
 
+	if isStaleHandle(err) {
+		return int(n), syscallError(backendConsole, "GetOverlappedResult", uintptr(r.conin), err)
+	}
+
 	return int(n), nil
 }
 
 var (
-	modkernel32                 = windows.NewLazySystemDLL("kernel32.dll")
-	procFlushConsoleInputBuffer = modkernel32.NewProc("FlushConsoleInputBuffer")
+	modkernel32                       = windows.NewLazySystemDLL("kernel32.dll")
+	procFlushConsoleInputBuffer       = modkernel32.NewProc("FlushConsoleInputBuffer")
+	procGetNumberOfConsoleInputEvents = modkernel32.NewProc("GetNumberOfConsoleInputEvents")
+	procGetConsoleCP                  = modkernel32.NewProc("GetConsoleCP")
+	procSetConsoleCP                  = modkernel32.NewProc("SetConsoleCP")
+	procGetConsoleOutputCP            = modkernel32.NewProc("GetConsoleOutputCP")
+	procSetConsoleOutputCP            = modkernel32.NewProc("SetConsoleOutputCP")
 )
 
+// createCancelEvent creates the auto-reset cancel event. With a name, an
+// existing event of that name is used, e.g. one created by a supervisor.
+func createCancelEvent(name string) (windows.Handle, error) {
+	var namep *uint16
+
+	if name != "" {
+		var err error
+
+		namep, err = windows.UTF16PtrFromString(name)
+		if err != nil {
+			return 0, fmt.Errorf("cancel event name: %w", err)
+		}
+	}
+
+	event, err := windows.CreateEvent(nil, 0, 0, namep)
+	if err != nil && !(event != 0 && errors.Is(err, windows.ERROR_ALREADY_EXISTS)) {
+		return 0, syscallError(backendConsole, "CreateEvent", 0, err)
+	}
+
+	return event, nil
+}
+
+// CancelByName cancels the pending Read of a reader in any process that was
+// created with WithCancelEventName(name).
+func CancelByName(name string) error {
+	namep, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return fmt.Errorf("cancel event name: %w", err)
+	}
+
+	event, err := windows.OpenEvent(windows.EVENT_MODIFY_STATE, false, namep)
+	if err != nil {
+		return fmt.Errorf("open cancel event %q: %w", name, syscallError(backendConsole, "OpenEvent", 0, err))
+	}
+	defer windows.CloseHandle(event) // nolint: errcheck
+
+	err = windows.SetEvent(event)
+	if err != nil {
+		return fmt.Errorf("set cancel event %q: %w", name, syscallError(backendConsole, "SetEvent", uintptr(event), err))
+	}
+
+	return nil
+}
+
+// openConin opens CONIN$ and flushes its input buffer if flush is set.
+func openConin(flush bool) (windows.Handle, error) {
+	// it is necessary to open CONIN$ (NOT windows.STD_INPUT_HANDLE) in
+	// overlapped mode to be able to use it with WaitForMultipleObjects.
+	conin, err := windows.CreateFile(
+		&(utf16.Encode([]rune("CONIN$\x00"))[0]), windows.GENERIC_READ|windows.GENERIC_WRITE,
+		fileShareValidFlags, nil, windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return 0, syscallError(backendConsole, "CreateFile CONIN$", 0, err)
+	}
+
+	if !flush {
+		return conin, nil
+	}
+
+	// flush input, otherwise it can contain events which trigger
+	// WaitForMultipleObjects but which ReadFile cannot read, resulting in an
+	// un-cancelable read
+	err = flushConsoleInputBuffer(conin)
+	if err != nil {
+		_ = windows.Close(conin)
+		return 0, syscallError(backendConsole, "FlushConsoleInputBuffer", uintptr(conin), err)
+	}
+
+	return conin, nil
+}
+
+// isStaleHandle reports whether err means that the console handle was
+// invalidated or disconnected from its console.
+func isStaleHandle(err error) bool {
+	return errors.Is(err, windows.ERROR_INVALID_HANDLE) || errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED)
+}
+
+// stdoutWindow returns the visible window of the console screen buffer of
+// os.Stdout.
+func stdoutWindow() (windows.SmallRect, error) {
+	var info windows.ConsoleScreenBufferInfo
+
+	err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info)
+	if err != nil {
+		return windows.SmallRect{}, fmt.Errorf("get console screen buffer info: %w", err)
+	}
+
+	return info.Window, nil
+}
+
 func flushConsoleInputBuffer(consoleInput windows.Handle) error {
 	r, _, e := syscall.Syscall(procFlushConsoleInputBuffer.Addr(), 1,
 		uintptr(consoleInput), 0, 0)
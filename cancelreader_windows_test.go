@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestCancelByName(t *testing.T) {
+	name := fmt.Sprintf("Local\\cancelreader-test-%d", os.Getpid())
+
+	if err := CancelByName(name); err == nil {
+		t.Errorf("expected error for missing event")
+	}
+
+	event, err := createCancelEvent(name)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer windows.CloseHandle(event) // nolint: errcheck
+
+	if err := CancelByName(name); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	state, err := windows.WaitForSingleObject(event, 0)
+	if state != windows.WAIT_OBJECT_0 {
+		t.Errorf("expected cancel event to be set, but got %d, %v", state, err)
+	}
+}
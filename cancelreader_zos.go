@@ -0,0 +1,209 @@
+//go:build zos
+// +build zos
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// newReader returns a reader and a cancel function. If the input reader is a
+// File, the cancel function can be used to interrupt a blocking read call.
+// In this case, the cancel function returns true if the call was canceled
+// successfully. If the input reader is not a File, the cancel function does
+// nothing and always returns false. The z/OS implementation is based on the
+// poll syscall.
+func newReader(reader io.Reader, cfg *config) (CancelReader, error) {
+	file, ok := reader.(File)
+	switch {
+	case cfg.backend == backendFallback:
+		return newFallbackCancelReader(reader)
+	case !ok && cfg.backend != "":
+		return nil, errUnsupportedInput(cfg.backend, reader)
+	case !ok:
+		return newFallbackCancelReader(reader)
+	}
+
+	switch cfg.backend {
+	case "", backendPoll:
+		return newPollCancelReader(file)
+	default:
+		return nil, errUnknownBackend(cfg.backend)
+	}
+}
+
+var backends = []string{backendPoll, backendFallback}
+
+func newPollCancelReader(file File) (CancelReader, error) {
+	r := &pollCancelReader{file: file}
+
+	var err error
+
+	r.cancelSignalReader, r.cancelSignalWriter, err = os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+type pollCancelReader struct {
+	file               File
+	cancelSignalReader File
+	cancelSignalWriter File
+	cancelMixin
+}
+
+func (r *pollCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *pollCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
+	if r.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	stop := wakeOnDone(ctx, r.cancelSignalWriter)
+	defer stop()
+
+	for {
+		err := contextErr(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		timeout := time.Duration(-1)
+		if t, ok := timeUntil(ctx); ok {
+			timeout = t
+		}
+
+		err = r.wait(timeout)
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
+
+		switch {
+		case errors.Is(err, errStaleSignal), errors.Is(err, ErrTimeout):
+			continue // checks ctx again
+		case err != nil:
+			return 0, err
+		}
+
+		return r.file.Read(data)
+	}
+}
+
+func (r *pollCancelReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	for {
+		err := r.wait(timeout)
+		if errors.Is(err, ErrCanceled) {
+			err = consumeCancelSignal(r.cancelSignalReader, &r.cancelMixin)
+		}
+
+		switch {
+		case errors.Is(err, errStaleSignal):
+			continue
+		case errors.Is(err, ErrTimeout):
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+}
+
+// wait waits until the file or the cancel signal pipe is readable, until
+// timeout or forever if timeout is negative.
+func (r *pollCancelReader) wait(timeout time.Duration) error {
+	ms := -1
+	if timeout >= 0 {
+		// round up so that short timeouts don't spin
+		ms = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+
+	fds := []unix.PollFd{
+		{Fd: int32(r.file.Fd()), Events: unix.POLLIN},
+		{Fd: int32(r.cancelSignalReader.Fd()), Events: unix.POLLIN},
+	}
+
+	for {
+		n, err := unix.Poll(fds, ms)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return syscallError(backendPoll, "poll", r.file.Fd(), err)
+		}
+
+		if n == 0 {
+			return ErrTimeout
+		}
+
+		break
+	}
+
+	if fds[1].Revents != 0 {
+		return ErrCanceled
+	}
+
+	if fds[0].Revents&unix.POLLNVAL != 0 {
+		return fmt.Errorf("poll: invalid file descriptor %d", r.file.Fd())
+	}
+
+	// POLLHUP and POLLERR are reported by the following read
+	return nil
+}
+
+func (r *pollCancelReader) reset() error {
+	r.resetCanceled()
+	return drainCancelSignals(r.cancelSignalReader)
+}
+
+func (r *pollCancelReader) cancelSignal() File {
+	return r.cancelSignalWriter
+}
+
+// cancelSignalSource returns the end of the cancel signal pipe that becomes
+// readable on Cancel, see Select.
+func (r *pollCancelReader) cancelSignalSource() File {
+	return r.cancelSignalReader
+}
+
+func (r *pollCancelReader) backendName() string {
+	return backendPoll
+}
+
+func (r *pollCancelReader) Cancel() bool {
+	generation := r.setCanceled()
+
+	// send cancel signal
+	return sendCancelSignal(r.cancelSignalWriter, generation)
+}
+
+func (r *pollCancelReader) Close() error {
+	var e1, e2 error
+
+	// close pipe
+	err := r.cancelSignalWriter.Close()
+	if err != nil {
+		e1 = fmt.Errorf("closing cancel signal writer: %w", err)
+	}
+
+	err = r.cancelSignalReader.Close()
+	if err != nil {
+		e2 = fmt.Errorf("closing cancel signal reader: %w", err)
+	}
+
+	return errors.Join(e1, e2)
+}
@@ -0,0 +1,82 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly || zos
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly zos
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// errStaleSignal is returned by consumeCancelSignal for a cancel signal left
+// over from a cancelation before a reset.
+var errStaleSignal = errors.New("stale cancel signal")
+
+// sendCancelSignal writes a cancel signal tagged with the generation that
+// was canceled.
+func sendCancelSignal(w File, generation uint64) bool {
+	_, err := w.Write([]byte{byte(generation) &^ wakeSignal})
+	return err == nil
+}
+
+// consumeCancelSignal removes one cancel signal from the pipe. It returns
+// ErrCanceled if the signal belongs to the current generation or comes from
+// another process and errStaleSignal otherwise.
+func consumeCancelSignal(r File, m *cancelMixin) error {
+	var b [1]byte
+
+	_, err := r.Read(b[:])
+	if err != nil {
+		return fmt.Errorf("reading cancel signal: %w", err)
+	}
+
+	if b[0] == remoteSignal {
+		m.setCanceled()
+		return ErrCanceled
+	}
+
+	if !m.isCurrent(b[0]) {
+		return errStaleSignal
+	}
+
+	return ErrCanceled
+}
+
+// wakeOnDone wakes up a waiting Read by writing a wake signal to the pipe
+// once ctx is done. The returned function stops it.
+func wakeOnDone(ctx context.Context, w File) func() bool {
+	return context.AfterFunc(ctx, func() {
+		_, _ = w.Write([]byte{wakeSignal})
+	})
+}
+
+// drainCancelSignals removes all pending cancel signals from the pipe without
+// blocking.
+func drainCancelSignals(r File) error {
+	var b [16]byte
+
+	for {
+		fds := []unix.PollFd{{Fd: int32(r.Fd()), Events: unix.POLLIN}}
+
+		n, err := unix.Poll(fds, 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("poll cancel signal: %w", err)
+		}
+
+		if n == 0 {
+			return nil
+		}
+
+		_, err = r.Read(b[:])
+		if err != nil {
+			return fmt.Errorf("reading cancel signal: %w", err)
+		}
+	}
+}
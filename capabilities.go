@@ -0,0 +1,120 @@
+package cancelreader
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// Capabilities describes what a reader created by NewReader supports, see
+// Probe and ReaderCapabilities.
+type Capabilities struct {
+	// Backend is the name of the backend NewReader would use.
+	Backend string
+
+	// Kind is the kind of the input.
+	Kind Kind
+
+	// Cancel reports whether Cancel interrupts a blocked Read.
+	Cancel bool
+
+	// Deadlines reports whether ReadContext returns once the context is
+	// done instead of waiting for input.
+	Deadlines bool
+
+	// Events reports whether key, mouse and resize events can be decoded
+	// from the input, i.e. it is a terminal or console.
+	Events bool
+
+	// ConsoleChanges are the terminal flags that raw mode, as applied by
+	// PrepareConsole on Windows, would set (+) or clear (-).
+	ConsoleChanges []string
+}
+
+// Probe reports what a reader for reader created with opts would support
+// without creating it: only the backend is set up and closed again, and the
+// terminal is left alone, including the input buffered by the Windows
+// console, as with WithoutInputFlush. This lets applications show the
+// features available before they start.
+func Probe(reader io.Reader, opts ...Option) (Capabilities, error) {
+	cfg := newConfig(opts)
+	// keys typed ahead are kept for the reader created afterwards
+	cfg.noInputFlush = true
+
+	reader, err := asFile(reader)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("probe: %w", err)
+	}
+	if cf, ok := reader.(*connFile); ok {
+		defer cf.Close()
+	}
+
+	if cfg.probeBackends {
+		cfg.backend, _ = probeBackends(reader, cfg)
+	}
+
+	cr, err := openReader(reader, cfg)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("probe: %w", err)
+	}
+
+	f, _ := reader.(File)
+	caps := capabilitiesOf(cr, inputKind(reader), f)
+
+	err = cr.Close()
+	if err != nil {
+		return caps, fmt.Errorf("probe: %w", err)
+	}
+
+	return caps, nil
+}
+
+// ReaderCapabilities reports what r supports, like Probe does before a
+// reader is created, so that applications can log the mechanism in use and
+// adapt when only the fallback backend was available. For readers not
+// returned by NewReader, only Cancel and Deadlines are set.
+func ReaderCapabilities(r CancelReader) Capabilities {
+	info, ok := infoOf(r)
+	if !ok {
+		return Capabilities{Cancel: CanCancel(r), Deadlines: nativeContext(r)}
+	}
+
+	return capabilitiesOf(info.base, info.kind, info.input)
+}
+
+// capabilitiesOf returns the capabilities of the backend reader cr for input
+// of kind. input is nil if the input is not a File.
+func capabilitiesOf(cr CancelReader, kind Kind, input File) Capabilities {
+	caps := Capabilities{
+		Backend:   backendOf(cr),
+		Kind:      kind,
+		Deadlines: nativeContext(cr),
+	}
+	caps.Cancel = caps.Backend != backendFallback
+
+	caps.Events = caps.Kind == KindTerminal
+	if runtime.GOOS == "windows" {
+		caps.Events = caps.Backend == backendConsole
+	}
+
+	if input != nil && caps.Events {
+		caps.ConsoleChanges, _ = rawModeChanges(input.Fd())
+	}
+
+	return caps
+}
+
+// nativeContext reports whether cr can stop waiting for input when a
+// context is done.
+func nativeContext(cr CancelReader) bool {
+	if r, ok := cr.(*registeredReader); ok {
+		cr = r.CancelReader
+	}
+
+	switch cr.(type) {
+	case contextReader, ContextReader:
+		return true
+	}
+
+	return false
+}
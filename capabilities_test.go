@@ -0,0 +1,48 @@
+package cancelreader
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	caps, err := Probe(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if caps.Backend != backendFallback || caps.Cancel || caps.Deadlines || caps.Events {
+		t.Errorf("expected the fallback backend without capabilities, but got %+v", caps)
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	caps, err = Probe(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if caps.Backend != Backend(r) || caps.Cancel != CanCancel(r) || caps.Kind != KindPipe {
+		t.Errorf("expected the capabilities of %s, but got %+v", Backend(r), caps)
+	}
+
+	if got := ReaderCapabilities(r); got.Backend != caps.Backend || got.Cancel != caps.Cancel ||
+		got.Deadlines != caps.Deadlines || got.Kind != caps.Kind {
+		t.Errorf("expected %+v, but got %+v", caps, got)
+	}
+
+	if _, err = Probe(pr, WithBackend("unknown")); err == nil {
+		t.Errorf("expected an error for an unknown backend")
+	}
+}
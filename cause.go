@@ -0,0 +1,51 @@
+package cancelreader
+
+import "errors"
+
+// canceledError is returned by Reads canceled for a reason, e.g. a context
+// that is done. It wraps both ErrCanceled and the reason.
+type canceledError struct {
+	err error
+}
+
+func (e *canceledError) Error() string {
+	return ErrCanceled.Error() + ": " + e.err.Error()
+}
+
+func (e *canceledError) Unwrap() []error {
+	return []error{ErrCanceled, e.err}
+}
+
+// CancelWithError cancels r like r.Cancel, but the interrupted and all
+// following Reads of a reader returned by NewReader fail with an error that
+// wraps both ErrCanceled and err. Other readers keep returning ErrCanceled.
+func CancelWithError(r CancelReader, err error) bool {
+	info, ok := infoOf(r)
+	if !ok || err == nil {
+		return r.Cancel()
+	}
+
+	info.lock.Lock()
+	if info.cause == nil {
+		info.cause = err
+	}
+	info.lock.Unlock()
+
+	return r.Cancel()
+}
+
+// withCause replaces ErrCanceled by the reason given to CancelWithError.
+func (r *infoReader) withCause(err error) error {
+	if !errors.Is(err, ErrCanceled) {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.cause == nil || !r.canceled {
+		return err
+	}
+
+	return &canceledError{err: r.cause}
+}
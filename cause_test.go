@@ -0,0 +1,36 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCancelWithError(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	reason := errors.New("shutting down: SIGTERM")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		CancelWithError(r, reason)
+	}()
+
+	_, err = r.Read(make([]byte, 1))
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, reason) {
+		t.Errorf("expected ErrCanceled and %s, but got %v", reason, err)
+	}
+}
@@ -0,0 +1,240 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+)
+
+// ChildOption configures RunChild.
+type ChildOption func(*childConfig)
+
+type childConfig struct {
+	raw    bool
+	resize func(rows, cols int)
+}
+
+// WithRawMode puts the terminal behind os.Stdin into raw mode while the child
+// runs, so that keys like Ctrl+C reach the child instead of generating
+// signals.
+func WithRawMode() ChildOption {
+	return func(c *childConfig) {
+		c.raw = true
+	}
+}
+
+// WithResizeHandler removes xterm window size reports (ESC [ 8 ; rows ;
+// columns t) from the input of the child and passes the size to fn instead.
+// On Windows, WithEventTranslation reports console resizes this way, which
+// fn can forward to the remote side.
+func WithResizeHandler(fn func(rows, cols int)) ChildOption {
+	return func(c *childConfig) {
+		c.resize = fn
+	}
+}
+
+// RunChild runs cmd, typically plink or ssh, with its stdin fed from r and
+// waits for it to exit. Stdout and stderr of the child default to os.Stdout
+// and os.Stderr. On unix, SIGWINCH is forwarded to the child so that it
+// picks up window size changes even in its own process group.
+//
+// When the child exits, r is canceled so that the pending Read does not
+// swallow the next keystroke meant for the parent. Like after any other
+// cancelation, r must not be used for further reads. If r can't be canceled,
+// RunChild returns without waiting for the pending Read.
+func RunChild(r CancelReader, cmd *exec.Cmd, opts ...ChildOption) error {
+	var cfg childConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("create stdin pipe: %w", err)
+	}
+
+	if cfg.raw {
+		restore, err := makeRaw(os.Stdin.Fd())
+		if err != nil {
+			return err
+		}
+		defer restore() // nolint: errcheck
+	}
+
+	winch := make(chan os.Signal, 1)
+	notifyResize(winch)
+	defer signal.Stop(winch)
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("start %s: %w", cmd.Path, err)
+	}
+
+	var src io.Reader = r
+	if cfg.resize != nil {
+		src = &resizeFilter{r: r, resize: cfg.resize}
+	}
+
+	fed := make(chan error, 1)
+	go func() {
+		fed <- feed(stdin, src)
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	for {
+		select {
+		case sig := <-winch:
+			_ = cmd.Process.Signal(sig)
+		case err = <-exited:
+			if r.Cancel() {
+				return errors.Join(err, <-fed)
+			}
+
+			return err
+		}
+	}
+}
+
+// feed copies r to w until r is canceled or w is closed and closes w at EOF.
+func feed(w io.WriteCloser, r io.Reader) error {
+	defer w.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				// the child exited
+				return nil
+			}
+		}
+
+		switch {
+		case errors.Is(err, ErrCanceled), errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return fmt.Errorf("reading input: %w", err)
+		}
+	}
+}
+
+// resizeFilter removes window size reports from a stream and passes them to
+// resize. A report split across reads is held back until it is complete.
+type resizeFilter struct {
+	r      io.Reader
+	resize func(rows, cols int)
+
+	pending []byte
+	ready   []byte
+	err     error
+}
+
+func (f *resizeFilter) Read(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	for len(f.ready) == 0 {
+		if f.err != nil {
+			err := f.err
+			f.err = nil
+
+			return 0, err
+		}
+
+		buf := make([]byte, len(data))
+		n, err := f.r.Read(buf)
+		f.pending = append(f.pending, buf[:n]...)
+		f.ready, f.pending = f.filter(f.pending, err != nil)
+		f.err = err
+	}
+
+	n := copy(data, f.ready)
+	f.ready = f.ready[n:]
+
+	return n, nil
+}
+
+// filter returns the data without complete size reports and the incomplete
+// report at its end, unless final is set.
+func (f *resizeFilter) filter(data []byte, final bool) (out, rest []byte) {
+	for i := 0; i < len(data); {
+		if data[i] != '\x1b' {
+			out = append(out, data[i])
+			i++
+
+			continue
+		}
+
+		rows, cols, n := parseSizeReport(data[i:])
+		switch {
+		case n > 0:
+			f.resize(rows, cols)
+			i += n
+		case n < 0 && !final:
+			return out, data[i:]
+		default:
+			out = append(out, data[i])
+			i++
+		}
+	}
+
+	return out, nil
+}
+
+// parseSizeReport parses a window size report at the start of b and returns
+// its length. The length is negative if b is an incomplete report and 0 if b
+// does not start with a report. A lone ESC or ESC [ does not count as
+// incomplete, since it is more likely a key press.
+func parseSizeReport(b []byte) (rows, cols, n int) {
+	const prefix = "\x1b[8;"
+
+	if len(b) < len(prefix) {
+		if len(b) > 2 && string(b) == prefix[:len(b)] {
+			return 0, 0, -1
+		}
+
+		return 0, 0, 0
+	}
+
+	if string(b[:len(prefix)]) != prefix {
+		return 0, 0, 0
+	}
+
+	var params [2]int
+
+	i, p, digits := len(prefix), 0, 0
+	for ; i < len(b); i++ {
+		c := b[i]
+
+		switch {
+		case c >= '0' && c <= '9' && digits < 5:
+			params[p], _ = strconv.Atoi(string(b[i-digits : i+1]))
+			digits++
+		case c == ';' && p == 0 && digits > 0:
+			p, digits = 1, 0
+		case c == 't' && p == 1 && digits > 0:
+			return params[0], params[1], i + 1
+		default:
+			return 0, 0, 0
+		}
+	}
+
+	return 0, 0, -1
+}
@@ -0,0 +1,29 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+)
+
+func TestResizeFilter(t *testing.T) {
+	var sizes [][2]int
+
+	f := &resizeFilter{
+		r:      &chunkReader{"a\x1b[8;24;80tb", "\x1b[8;2", "5;81t\x1b[Ac\x1b[8;x"},
+		resize: func(rows, cols int) { sizes = append(sizes, [2]int{rows, cols}) },
+	}
+
+	out, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	expected := "ab\x1b[Ac\x1b[8;x"
+	if string(out) != expected {
+		t.Errorf("expected %q, but got %q", expected, out)
+	}
+
+	if len(sizes) != 2 || sizes[0] != [2]int{24, 80} || sizes[1] != [2]int{25, 81} {
+		t.Errorf("expected resizes to 24x80 and 25x81, but got %v", sizes)
+	}
+}
@@ -0,0 +1,38 @@
+package cancelreader
+
+import "time"
+
+// Clock is the source of time for the time-based features of a reader, i.e.
+// first read latencies, idle detection and the batching window of
+// WithAdaptivePolling. Tests and simulations can inject their own with
+// WithClock to drive these features without sleeping. Deadlines of contexts
+// passed to ReadContext are enforced by the contexts themselves.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine after d.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by Clock.AfterFunc. A *time.Timer is a Timer.
+type Timer interface {
+	// Stop prevents the Timer from firing and returns false if it already
+	// fired or was stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d.
+	Reset(d time.Duration) bool
+}
+
+// systemClock is the Clock of the time package. Its times carry a monotonic
+// reading, so durations are not affected by changes of the wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
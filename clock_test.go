@@ -0,0 +1,114 @@
+package cancelreader
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a Clock that only advances when told to.
+type manualClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	c      *manualClock
+	at     time.Time
+	f      func()
+	active bool
+}
+
+func (c *manualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &manualTimer{c: c, at: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// Advance moves the clock forward and calls the functions of the timers that
+// expired.
+func (c *manualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+
+	var due []func()
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			due = append(due, t.f)
+		}
+	}
+	c.lock.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+func (t *manualTimer) Stop() bool {
+	t.c.lock.Lock()
+	defer t.c.lock.Unlock()
+
+	active := t.active
+	t.active = false
+
+	return active
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.c.lock.Lock()
+	defer t.c.lock.Unlock()
+
+	active := t.active
+	t.active = true
+	t.at = t.c.now.Add(d)
+
+	return active
+}
+
+func TestClock(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	clock := &manualClock{now: time.Unix(0, 0)}
+	events := make(chan StateEvent, 1)
+
+	cr, err := NewReader(pr, WithClock(clock), WithStateEvents(time.Minute, events))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	clock.Advance(time.Minute)
+	select {
+	case ev := <-events:
+		if ev.State != StateIdle {
+			t.Errorf("expected %s, got %s", StateIdle, ev.State)
+		}
+	default:
+		t.Errorf("expected %s event", StateIdle)
+	}
+
+	go func() { _, _ = pw.Write([]byte("a")) }()
+	if _, err := cr.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	first, _ := FirstReadLatency(cr)
+	if first.SinceCreated != time.Minute || first.SinceRead != 0 {
+		t.Errorf("expected latencies of 1m and 0s, got %+v", first)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupBench(fs *flag.FlagSet, opts *options) func([]string) error {
+	n := fs.Int("n", 100, "number of iterations")
+	stdin := fs.Bool("stdin", false, "measure cancel latency on stdin instead of a pipe")
+
+	return func([]string) error {
+		var reads, cancels latencies
+
+		for i := 0; i < *n; i++ {
+			if *stdin {
+				d, ok, err := benchStdinCancel(opts)
+				if err != nil {
+					return err
+				}
+				cancels.add(d, ok)
+				continue
+			}
+
+			r, c, ok, err := benchPipe(opts.readerOptions()...)
+			if err != nil {
+				return err
+			}
+			reads.add(r, true)
+			cancels.add(c, ok)
+		}
+
+		if !*stdin {
+			fmt.Println("read  ", reads)
+		}
+		fmt.Println("cancel", cancels)
+		return nil
+	}
+}
+
+// benchPipe measures how long a Read takes to return after data was written
+// to a pipe and after the reader was canceled. ok is false if the data was
+// not read correctly or the cancelation did not succeed within 100ms.
+func benchPipe(ropts ...cancelreader.Option) (read, cancel time.Duration, ok bool, err error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := cancelreader.NewReader(pr, ropts...)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer cr.Close()
+
+	done := make(chan error, 1)
+	var b [1]byte
+	readOne := func() {
+		_, err := cr.Read(b[:])
+		done <- err
+	}
+
+	go readOne()
+	time.Sleep(time.Millisecond)
+	start := time.Now()
+	if _, err = pw.Write([]byte{'x'}); err != nil {
+		return 0, 0, false, err
+	}
+	if err = <-done; err != nil {
+		return 0, 0, false, err
+	}
+	read = time.Since(start)
+	correct := b[0] == 'x'
+
+	go readOne()
+	time.Sleep(time.Millisecond)
+	start = time.Now()
+	ok = cr.Cancel()
+	select {
+	case err = <-done:
+		ok = ok && errors.Is(err, cancelreader.ErrCanceled)
+	case <-time.After(100 * time.Millisecond):
+		// unblock a reader that can't be canceled
+		_, _ = pw.Write([]byte{'x'})
+		<-done
+		ok = false
+	}
+	cancel = time.Since(start)
+
+	return read, cancel, ok && correct, nil
+}
+
+// benchStdinCancel measures how long a blocked Read on stdin takes to return
+// after the reader was canceled.
+func benchStdinCancel(opts *options) (time.Duration, bool, error) {
+	cr, cleanup, err := opts.reader()
+	if err != nil {
+		return 0, false, err
+	}
+	defer cleanup()
+
+	done := make(chan struct{})
+	go func() {
+		var b [1]byte
+		_, _ = cr.Read(b[:])
+		close(done)
+	}()
+
+	time.Sleep(time.Millisecond)
+	start := time.Now()
+	ok := cr.Cancel()
+	<-done
+
+	return time.Since(start), ok, nil
+}
+
+// latencies collects durations and how many of them belong to failed
+// operations.
+type latencies struct {
+	min, max, sum time.Duration
+	n, failed     int
+}
+
+func (l *latencies) add(d time.Duration, ok bool) {
+	if !ok {
+		l.failed++
+	}
+	if l.n == 0 || d < l.min {
+		l.min = d
+	}
+	if d > l.max {
+		l.max = d
+	}
+	l.sum += d
+	l.n++
+}
+
+func (l latencies) String() string {
+	if l.n == 0 {
+		return "no samples"
+	}
+	return fmt.Sprintf("n=%d min=%v avg=%v max=%v failed=%d", l.n, l.min, l.sum/time.Duration(l.n), l.max, l.failed)
+}
+
+func setupCompare(fs *flag.FlagSet, opts *options) func([]string) error {
+	n := fs.Int("n", 20, "number of iterations per backend")
+	stdin := fs.Bool("stdin", false, "measure cancel latency on stdin instead of a pipe")
+
+	return func([]string) error {
+		fmt.Printf("%-10s %s\n", "backend", "latencies")
+		for _, backend := range cancelreader.Backends() {
+			opts.backend = backend
+
+			var reads, cancels latencies
+			var err error
+			for i := 0; i < *n && err == nil; i++ {
+				var (
+					r, c time.Duration
+					ok   bool
+				)
+				if *stdin {
+					c, ok, err = benchStdinCancel(opts)
+				} else {
+					r, c, ok, err = benchPipe(opts.readerOptions()...)
+					reads.add(r, ok)
+				}
+				cancels.add(c, ok)
+			}
+
+			switch {
+			case err != nil:
+				fmt.Printf("%-10s %v\n", backend, err)
+			case *stdin:
+				fmt.Printf("%-10s cancel %v\n", backend, cancels)
+			default:
+				fmt.Printf("%-10s read   %v\n%-10s cancel %v\n", backend, reads, "", cancels)
+			}
+		}
+		return nil
+	}
+}
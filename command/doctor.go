@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/abakum/cancelreader"
+	"github.com/mattn/go-isatty"
+)
+
+func setupDoctor(_ *flag.FlagSet, opts *options) func([]string) error {
+	return func([]string) error {
+		fmt.Printf("os:        %s/%s %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+		for _, env := range []string{"TERM", "TERM_PROGRAM", "COLORTERM", "WT_SESSION", "ConEmuPID", "MSYSTEM", "TMUX", "SSH_TTY"} {
+			if v, ok := os.LookupEnv(env); ok {
+				fmt.Printf("env:       %s=%s\n", env, v)
+			}
+		}
+
+		for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+			fmt.Printf("%-10s terminal=%v cygwin=%v\n", f.Name()+":", isatty.IsTerminal(f.Fd()), isatty.IsCygwinTerminal(f.Fd()))
+		}
+
+		fmt.Printf("a11y:      screen reader=%v\n", cancelreader.ScreenReaderActive())
+
+		for _, line := range platformReport() {
+			fmt.Println(line)
+		}
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			fmt.Printf("backend:   error: %v\n", err)
+			fmt.Printf("\nreport for bug filing:\n%s", cancelreader.Diagnose(err))
+			return nil
+		}
+		fmt.Printf("backend:   %s\n", cancelreader.Backend(cr))
+		fmt.Printf("input:     %s\n", cancelreader.InputKind(cr))
+		cleanup()
+
+		fmt.Printf("self-test: %s\n", selfTest(opts))
+		return nil
+	}
+}
+
+// selfTest cancels a blocked read on stdin and reports whether and how fast
+// the read returned.
+func selfTest(opts *options) string {
+	cr, cleanup, err := opts.reader()
+	if err != nil {
+		return err.Error()
+	}
+	defer cleanup()
+
+	done := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := cr.Read(b[:])
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	ok := cr.Cancel()
+
+	select {
+	case err = <-done:
+	case <-time.After(time.Second):
+		return fmt.Sprintf("Cancel returned %v but the read still blocks after 1s", ok)
+	}
+
+	if !errors.Is(err, cancelreader.ErrCanceled) {
+		return fmt.Sprintf("Cancel returned %v, read returned %v instead of being canceled", ok, err)
+	}
+	return fmt.Sprintf("Cancel returned %v, read returned after %v", ok, time.Since(start).Round(time.Microsecond))
+}
@@ -3,5 +3,41 @@
 
 package main
 
+import "os"
+
 func ConsoleCP(*bool)                  {}
 func IsCygwinTerminal(fd uintptr) bool { return false }
+func IsWinpty() bool                   { return false }
+func logConsoleModes()                 {}
+
+// enableEvents turns on mouse reporting of the terminal.
+func enableEvents(mouse bool) (func(), error) {
+	if !mouse {
+		return func() {}, nil
+	}
+	os.Stdout.WriteString(enableMouse)
+	return func() { os.Stdout.WriteString(disableMouse) }, nil
+}
+
+// handleCount returns the number of open file descriptors or -1 if it is
+// unknown.
+func handleCount() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+// platformReport returns platform specific lines of the doctor report.
+func platformReport() []string {
+	return nil
+}
+
+// enableVT enables escape sequence processing of the terminal, which unix
+// terminals always do.
+func enableVT() func() {
+	return func() {}
+}
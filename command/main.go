@@ -1,116 +1,236 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
-	"runtime"
+	"os/signal"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/abakum/cancelreader"
 	"github.com/containerd/console"
 	"github.com/mattn/go-isatty"
-	"github.com/xlab/closer"
 )
 
+// command is a subcommand of the diagnostic tool. setup registers the flags
+// of the subcommand and returns the function running it.
+type command struct {
+	name  string
+	usage string
+	setup func(fs *flag.FlagSet, opts *options) func(args []string) error
+}
+
+var commands = []command{
+	{"read", "print what is read from stdin", setupRead},
+	{"events", "print input sequences including mouse and resize events", setupEvents},
+	{"raw", "dump raw input bytes in hex", setupRaw},
+	{"bench", "measure read and cancel latency", setupBench},
+	{"query", "send a terminal query and print the response", setupQuery},
+	{"repl", "edit lines with history and cancelation", setupREPL},
+	{"caps", "report terminal capabilities by querying the terminal", setupCaps},
+	{"record", "record input to a file", setupRecord},
+	{"replay", "replay recorded input through a reader", setupReplay},
+	{"proxy", "copy stdin to stdout, TCP or ssh until interrupted", setupProxy},
+	{"compare", "compare latency and correctness of all backends", setupCompare},
+	{"throughput", "measure sustained throughput of all backends", setupThroughput},
+	{"stress", "hammer read/cancel/reset cycles on concurrent readers", setupStress},
+	{"doctor", "report the terminal environment and test cancelation", setupDoctor},
+	{"shell", "hand stdin to child shells with and without pipes", setupShell},
+}
+
+// options are the flags shared by all subcommands.
+type options struct {
+	timeout   time.Duration
+	backend   string
+	raw       bool
+	translate bool
+	json      bool
+	bom       bool
+	normalize bool
+	ctrlZ     string
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.timeout, "timeout", 0, "cancel reading after this duration (0 waits for Ctrl+C)")
+	fs.StringVar(&o.backend, "backend", "", fmt.Sprintf("force one of the backends %q", cancelreader.Backends()))
+	fs.BoolVar(&o.raw, "raw", false, "put the terminal into raw mode")
+	fs.BoolVar(&o.translate, "translate", false, "translate console key, mouse and resize events on Windows")
+	fs.BoolVar(&o.json, "json", false, "write events as JSON lines with timestamps")
+	fs.BoolVar(&o.bom, "bom", false, "detect a byte order mark and transcode UTF-16 input")
+	fs.BoolVar(&o.normalize, "normalize", false, "normalize line endings and Ctrl+Z of cmd and PowerShell pipelines")
+	fs.StringVar(&o.ctrlZ, "ctrlz", "", "handle Ctrl+Z as eof or data")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [args]\n\ncommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.name, c.usage)
+	}
+	_ = w.Flush()
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
+// teardown restores the console and releases what the subcommands set up
+// when the tool exits.
+var teardown = cancelreader.NewTeardown()
+
+// exitSignals end the tool through exit.
+var exitSignals = make(chan os.Signal, 1)
+
+// exitOn makes sigs tear down and exit with code 1 instead of the signals
+// passed before.
+func exitOn(sigs ...os.Signal) {
+	signal.Stop(exitSignals)
+	signal.Notify(exitSignals, sigs...)
+}
+
+// exit tears down and exits with code.
+func exit(code int) {
+	if err := teardown.Close(); err != nil {
+		log.Println("teardown", err)
+	}
+	os.Exit(code)
+}
+
 func main() {
+	log.SetFlags(log.Lmicroseconds | log.Lshortfile)
+	log.SetPrefix("\r")
+
+	exitOn(os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-exitSignals
+		exit(1)
+	}()
+
+	if len(os.Args) < 2 {
+		usage()
+		exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		var opts options
+		fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+		opts.register(fs)
+		run := c.setup(fs, &opts)
+		_ = fs.Parse(os.Args[2:])
+
+		if err := run(fs.Args()); err != nil {
+			log.Println(err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	usage()
+	exit(2)
+}
+
+// reader creates a CancelReader on stdin according to the shared options. It
+// puts the terminal into raw mode and arms the timeout. The returned cleanup
+// function undoes both and closes the reader.
+func (o *options) reader() (cancelreader.CancelReader, func(), error) {
 	var (
 		raw   bool
-		once  bool
 		reset = func(*bool) {}
-		cmd   *exec.Cmd
-		arg0  = "bash"
-		arg1  = "-c"
-		arg2  = "echo Press any key to continue . . .;read -rn1"
 	)
 
-	defer func() {
+	if o.raw {
+		reset = setRaw(&raw, reset)
+	}
+
+	cr, err := cancelreader.NewReader(os.Stdin, o.readerOptions()...)
+	if err != nil {
 		reset(&raw)
-		closer.Close()
-	}()
-	if IsCygwinTerminal(os.Stdin.Fd()) {
-		ConsoleCP(&once)
-	} else if runtime.GOOS == "windows" {
-		arg0 = "cmd"
-		arg1 = "/c"
+		return nil, nil, err
+	}
 
-		// arg0 = "powershell"
-		// arg1 = "-command"
+	stop := func() bool { return false }
+	if o.timeout > 0 {
+		stop = time.AfterFunc(o.timeout, func() { cr.Cancel() }).Stop
+	}
+
+	return cr, func() {
+		stop()
+		if err := cr.Close(); err != nil {
+			log.Println("Close", err)
+		}
+		reset(&raw)
+	}, nil
+}
 
-		arg2 = "pause"
+// readerOptions returns the options for NewReader selected by the flags.
+func (o *options) readerOptions() []cancelreader.Option {
+	var ropts []cancelreader.Option
+
+	if o.backend != "" {
+		ropts = append(ropts, cancelreader.WithBackend(o.backend))
 	}
-	log.SetFlags(log.Lmicroseconds | log.Lshortfile)
-	log.SetPrefix("\r")
 
-	var (
-		cr  cancelreader.CancelReader
-		err error
-	)
+	if o.translate {
+		ropts = append(ropts, cancelreader.WithEventTranslation())
+	}
 
-	for i := 0; i < 8; i++ {
-		if i%4 > 1 {
-			reset(&raw)
-			cmd = exec.Command(arg0)
-		} else {
-			reset = setRaw(&raw, reset)
-			cmd = exec.Command(arg0, arg1, arg2)
-		}
-		log.Println(cmd)
-		cr, err = cancelreader.NewReader(os.Stdin)
-		if err != nil {
-			panic(err)
-		}
+	if o.bom {
+		ropts = append(ropts, cancelreader.WithBOMDetection())
+	}
 
-		if i < 4 {
-			// <Esc> <Esc> exit<Enter> exit<Enter>
-			log.Println("--without pipes", i)
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			fmt.Print("\r")
-			if i%4 > 1 {
-				fmt.Println("Type exit<Enter>\r")
-			}
-			cmd.Run()
-		} else {
-			// <Esc> <Esc> exit<Enter> exit<Enter>
-			log.Println("--with pipes", i)
-			ConsoleCP(&once)
-
-			in, err := cmd.StdinPipe()
-			if err != nil {
-				panic(err)
-			}
+	if o.normalize {
+		ropts = append(ropts, cancelreader.WithPipelineNormalization())
+	}
 
-			out, err := cmd.StdoutPipe()
-			if err != nil {
-				panic(err)
-			}
+	switch o.ctrlZ {
+	case "eof":
+		ropts = append(ropts, cancelreader.WithCtrlZ(cancelreader.CtrlZEOF))
+	case "data":
+		ropts = append(ropts, cancelreader.WithCtrlZ(cancelreader.CtrlZData))
+	}
 
-			fmt.Print("\r")
-			if i%4 > 1 {
-				fmt.Println("Type exit<Enter>\r")
-			}
-			err = cmd.Start()
-			if err != nil {
-				panic(err)
+	return ropts
+}
+
+// readLoop reads from cr and calls handle for every chunk until cr is
+// canceled, fails, reaches EOF or Ctrl+C is read in raw mode. Cancelation and
+// errors are printed to out, but only errors are returned.
+func readLoop(cr cancelreader.CancelReader, out *output, handle func([]byte)) error {
+	var buf [1024]byte
+
+	for {
+		n, err := cr.Read(buf[:])
+		if n > 0 {
+			handle(buf[:n])
+			if strings.IndexByte(string(buf[:n]), 0x03) >= 0 {
+				return nil
 			}
+		}
 
-			go func() {
-				_, err = io.Copy(os.Stdout, out)
-				log.Println("Stdout done", i, err)
-				log.Println("Cancel read stdin", i, cr.Cancel())
-			}()
+		if errors.Is(err, cancelreader.ErrCanceled) {
+			out.canceled()
+			return nil
+		}
 
-			_, err = io.Copy(in, cr)
-			log.Println("Stdin done", i, err)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 
-			log.Println("Wait", cmd.Wait())
-			log.Println("Close", cr.Close())
+		if err != nil {
+			out.error(err)
+			return err
 		}
 	}
 }
+
 func setRaw(raw *bool, old func(*bool)) (reset func(*bool)) {
 	reset = old
 	if *raw {
@@ -134,7 +254,12 @@ func setRaw(raw *bool, old func(*bool)) (reset func(*bool)) {
 				}
 				*raw = err != nil
 			}
-			log.Println("Sets the console in raw mode by go")
+			if IsWinpty() {
+				log.Println("Sets the console in raw mode through winpty")
+			} else {
+				log.Println("Sets the console in raw mode by go")
+			}
+			logConsoleModes()
 			return
 		}
 	}
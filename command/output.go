@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+// output prints what the subcommands read, either for humans or as JSON
+// lines for scripted analysis.
+type output struct {
+	json  bool
+	start time.Time
+}
+
+func (o *options) output() *output {
+	return &output{json: o.json, start: time.Now()}
+}
+
+// jsonEvent is a line of the JSON output.
+type jsonEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Data   string    `json:"data,omitempty"`
+	Key    string    `json:"key,omitempty"`
+	Rune   string    `json:"rune,omitempty"`
+	Mod    string    `json:"mod,omitempty"`
+	Button string    `json:"button,omitempty"`
+	Action string    `json:"action,omitempty"`
+	X      int       `json:"x,omitempty"`
+	Y      int       `json:"y,omitempty"`
+	Rows   int       `json:"rows,omitempty"`
+	Cols   int       `json:"cols,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+func (o *output) write(ev jsonEvent, text string) {
+	if !o.json {
+		fmt.Printf("%10s %s\r\n", time.Since(o.start).Round(time.Millisecond), text)
+		return
+	}
+
+	ev.Time = time.Now()
+	line, err := json.Marshal(ev)
+	if err != nil {
+		panic(err)
+	}
+	// the terminal may be in raw mode and JSON ignores the carriage return
+	os.Stdout.Write(append(line, '\r', '\n'))
+}
+
+// data prints a chunk of input, text is used for humans.
+func (o *output) data(b []byte, text string) {
+	o.write(jsonEvent{Type: "data", Data: string(b)}, text)
+}
+
+// event prints a decoded event.
+func (o *output) event(ev cancelreader.Event) {
+	var j jsonEvent
+
+	switch ev := ev.(type) {
+	case cancelreader.KeyEvent:
+		j = jsonEvent{Type: "key", Key: ev.Key.String(), Mod: ev.Mod.String()}
+		if ev.Key == cancelreader.KeyRune {
+			j.Rune = string(ev.Rune)
+		}
+	case cancelreader.MouseEvent:
+		j = jsonEvent{
+			Type: "mouse", Button: ev.Button.String(), Action: ev.Action.String(),
+			Mod: ev.Mod.String(), X: ev.X, Y: ev.Y,
+		}
+	case cancelreader.ResizeEvent:
+		j = jsonEvent{Type: "resize", Rows: ev.Rows, Cols: ev.Cols}
+	case cancelreader.UnknownEvent:
+		j = jsonEvent{Type: "unknown", Data: string(ev.Sequence)}
+	}
+
+	o.write(j, ev.String())
+}
+
+func (o *output) canceled() {
+	o.write(jsonEvent{Type: "cancel"}, "canceled")
+}
+
+func (o *output) error(err error) {
+	o.write(jsonEvent{Type: "error", Error: err.Error()}, "error: "+err.Error())
+}
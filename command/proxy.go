@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupProxy(fs *flag.FlagSet, opts *options) func([]string) error {
+	addr := fs.String("addr", "", "copy to this TCP address instead of stdout")
+	ssh := fs.String("ssh", "", "copy to an ssh session to this destination instead of stdout")
+	escape := fs.Bool("escape", false, "cancel on ~. at the start of a line like ssh")
+
+	return func([]string) error {
+		// cancel on Ctrl+C instead of exiting through teardown
+		exitOn(syscall.SIGTERM)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var (
+			dst  io.Writer = os.Stdout
+			wait           = func() error { return nil }
+		)
+		switch {
+		case *addr != "":
+			conn, err := net.Dial("tcp", *addr)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			go io.Copy(os.Stdout, conn)
+			dst = conn
+		case *ssh != "":
+			cmd := exec.Command("ssh", *ssh)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			in, err := cmd.StdinPipe()
+			if err != nil {
+				return err
+			}
+			if err = cmd.Start(); err != nil {
+				return err
+			}
+			wait = func() error {
+				in.Close()
+				return cmd.Wait()
+			}
+			dst = in
+		}
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+
+		done := make(chan struct{})
+		interrupted := make(chan time.Time, 1)
+		go func() {
+			select {
+			case <-ctx.Done():
+				interrupted <- time.Now()
+				fmt.Fprintf(os.Stderr, "\r\ninterrupted, cancel: %v\r\n", cr.Cancel())
+			case <-done:
+			}
+		}()
+
+		var src io.Reader = cr
+		if *escape {
+			src = cancelreader.NewFilter(cr, cancelreader.KeyEvent{Key: cancelreader.KeyRune, Rune: '~'}, func(key cancelreader.KeyEvent) {
+				if key.Rune == '.' {
+					cr.Cancel()
+				}
+			}, cancelreader.WithLineStart())
+		}
+
+		start := time.Now()
+		n, err := io.Copy(dst, src)
+		copied := time.Now()
+		close(done)
+		if errors.Is(err, cancelreader.ErrCanceled) {
+			err = nil
+		}
+
+		cleanup()
+		closed := time.Now()
+		waitErr := wait()
+
+		fmt.Fprintf(os.Stderr, "copied %d bytes in %v\r\n", n, copied.Sub(start).Round(time.Microsecond))
+		select {
+		case canceled := <-interrupted:
+			fmt.Fprintf(os.Stderr, "copy returned %v after the interrupt\r\n", copied.Sub(canceled).Round(time.Microsecond))
+		default:
+		}
+		fmt.Fprintf(os.Stderr, "closing the reader took %v, the destination %v\r\n",
+			closed.Sub(copied).Round(time.Microsecond), time.Since(closed).Round(time.Microsecond))
+
+		return errors.Join(err, waitErr)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin || linux || netbsd || openbsd
+// +build darwin linux netbsd openbsd
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/containerd/console"
+)
+
+// openPty returns the raw mode slave and the master of a new pseudo terminal.
+// Data written to the master arrives as input on the slave.
+func openPty() (*os.File, io.WriteCloser, error) {
+	master, name, err := console.NewPty()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	c, err := console.ConsoleFromFile(slave)
+	if err == nil {
+		err = c.SetRaw()
+	}
+	if err != nil {
+		slave.Close()
+		master.Close()
+		return nil, nil, err
+	}
+
+	return slave, master, nil
+}
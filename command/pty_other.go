@@ -0,0 +1,14 @@
+//go:build !darwin && !linux && !netbsd && !openbsd
+// +build !darwin,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+func openPty() (*os.File, io.WriteCloser, error) {
+	return nil, nil, errors.New("pseudo terminals are not supported on this platform")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupQuery(fs *flag.FlagSet, opts *options) func([]string) error {
+	query := fs.String("q", `\x1b[c`, "query to send, Go escapes are allowed (default is DA1)")
+	final := fs.String("final", "c", "final byte of the expected CSI response")
+
+	return func([]string) error {
+		q, err := strconv.Unquote(`"` + *query + `"`)
+		if err != nil {
+			return fmt.Errorf("unquote query: %w", err)
+		}
+		if len(*final) != 1 {
+			return fmt.Errorf("final must be a single byte")
+		}
+
+		opts.raw = true
+		if opts.timeout == 0 {
+			opts.timeout = time.Second
+		}
+		timeout := opts.timeout
+		opts.timeout = 0
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		start := time.Now()
+		response, err := cancelreader.Query(cr, os.Stdout, q, (*final)[0], timeout)
+		if err != nil {
+			fmt.Printf("%v after %v: %q\r\n", err, time.Since(start).Round(time.Millisecond), response)
+			return nil
+		}
+
+		fmt.Printf("response after %v: %q\r\n", time.Since(start).Round(time.Millisecond), response)
+		return nil
+	}
+}
+
+// probe is a terminal capability test. The query is followed by the primary
+// device attributes query, so that unanswered queries are detected without
+// waiting for the timeout.
+type probe struct {
+	name   string
+	query  string
+	answer *regexp.Regexp
+	// describe formats the submatches of answer
+	describe func(m []string) string
+}
+
+// decrqm queries the state of a private mode and reports whether it is
+// supported.
+func decrqm(name string, mode int) probe {
+	return probe{
+		name:   name,
+		query:  fmt.Sprintf("\x1b[?%d$p", mode),
+		answer: regexp.MustCompile(fmt.Sprintf(`\x1b\[\?%d;(\d)\$y`, mode)),
+		describe: func(m []string) string {
+			switch m[1] {
+			case "1", "3":
+				return "supported, set"
+			case "2", "4":
+				return "supported, reset"
+			default:
+				return "not recognized"
+			}
+		},
+	}
+}
+
+var probes = []probe{
+	{"device attributes", "", regexp.MustCompile(`\x1b\[\?([\d;]*)c`), func(m []string) string { return m[1] }},
+	{"cursor position", "\x1b[6n", regexp.MustCompile(`\x1b\[(\d+);(\d+)R`), func(m []string) string { return "row " + m[1] + " column " + m[2] }},
+	{"kitty keyboard", "\x1b[?u", regexp.MustCompile(`\x1b\[\?(\d*)u`), func(m []string) string { return "flags " + m[1] }},
+	decrqm("bracketed paste", 2004),
+	decrqm("focus events", 1004),
+	decrqm("SGR mouse", 1006),
+	decrqm("synchronized output", 2026),
+}
+
+func setupCaps(fs *flag.FlagSet, opts *options) func([]string) error {
+	return func([]string) error {
+		opts.raw = true
+		timeout := opts.timeout
+		if timeout == 0 {
+			timeout = time.Second
+		}
+		opts.timeout = 0
+
+		fmt.Printf("%-20s %-8s %s\r\n", "capability", "time", "result")
+		for _, p := range probes {
+			// a reader canceled by a timeout can't be used anymore
+			cr, cleanup, err := opts.reader()
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			response, err := cancelreader.Query(cr, os.Stdout, p.query+"\x1b[c", 'c', timeout)
+			cleanup()
+
+			result := "not supported"
+			switch m := p.answer.FindStringSubmatch(string(response)); {
+			case m != nil:
+				result = p.describe(m)
+			case errors.Is(err, cancelreader.ErrTimeout):
+				result = "terminal did not respond"
+			case err != nil:
+				result = err.Error()
+			}
+			fmt.Printf("\r\x1b[K%-20s %-8v %s\r\n", p.name, time.Since(start).Round(time.Millisecond), result)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupRead(_ *flag.FlagSet, opts *options) func([]string) error {
+	return func([]string) error {
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		out := opts.output()
+		err = readLoop(cr, out, func(b []byte) {
+			out.data(b, fmt.Sprintf("%q", b))
+		})
+		if opts.bom {
+			fmt.Fprintf(os.Stderr, "encoding: %s\n", cancelreader.DetectedEncoding(cr))
+		}
+		return err
+	}
+}
+
+func setupRaw(_ *flag.FlagSet, opts *options) func([]string) error {
+	return func([]string) error {
+		opts.raw = true
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fmt.Fprint(os.Stderr, "Press keys, Ctrl+C to quit\r\n")
+
+		out := opts.output()
+		return readLoop(cr, out, func(b []byte) {
+			out.data(b, fmt.Sprintf("% x  %q", b, b))
+		})
+	}
+}
+
+// mouse tracking with SGR reports on unix terminals
+const (
+	enableMouse  = "\x1b[?1000;1002;1006h"
+	disableMouse = "\x1b[?1000;1002;1006l"
+)
+
+func setupEvents(fs *flag.FlagSet, opts *options) func([]string) error {
+	mouse := fs.Bool("mouse", true, "enable mouse reporting")
+	escTimeout := fs.Duration("esc-timeout", 0, "wait for the rest of an escape sequence after a lone ESC")
+
+	return func([]string) error {
+		opts.raw = true
+		opts.translate = true
+
+		restore, err := enableEvents(*mouse)
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fmt.Fprint(os.Stderr, "Press keys, click, scroll or resize, Ctrl+C to quit\r\n")
+
+		out := opts.output()
+		d := cancelreader.NewDecoder(cr, cancelreader.WithEscTimeout(*escTimeout))
+		for {
+			ev, err := d.ReadEvent()
+			switch {
+			case errors.Is(err, cancelreader.ErrCanceled):
+				out.canceled()
+				return nil
+			case errors.Is(err, io.EOF):
+				return nil
+			case err != nil:
+				out.error(err)
+				return err
+			}
+
+			out.event(ev)
+			if ev == (cancelreader.KeyEvent{Key: cancelreader.KeyRune, Rune: 'c', Mod: cancelreader.ModCtrl}) {
+				return nil
+			}
+		}
+	}
+}
+
+// splitSequences quotes b, separating escape sequences by spaces.
+func splitSequences(b []byte) string {
+	var parts []string
+
+	s := string(b)
+	for len(s) > 0 {
+		i := strings.IndexByte(s[1:], 0x1b) + 1
+		if i == 0 {
+			i = len(s)
+		}
+		parts = append(parts, fmt.Sprintf("%q", s[:i]))
+		s = s[i:]
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupRecord(fs *flag.FlagSet, opts *options) func([]string) error {
+	output := fs.String("o", "trace", "file to record to")
+
+	return func([]string) error {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fmt.Fprint(os.Stderr, "Recording, Ctrl+C to stop\r\n")
+
+		out := opts.output()
+		return readLoop(cancelreader.Record(cr, f), out, func(b []byte) {
+			out.data(b, fmt.Sprintf("%q", b))
+		})
+	}
+}
+
+func setupReplay(fs *flag.FlagSet, opts *options) func([]string) error {
+	speed := fs.Float64("speed", 1, "replay speed factor, 0 replays as fast as possible")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: replay [flags] <trace>")
+		}
+
+		trace, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer trace.Close()
+
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		defer pr.Close()
+
+		go func() {
+			if err := cancelreader.Replay(pw, trace, *speed); err != nil {
+				opts.output().error(err)
+			}
+			pw.Close()
+		}()
+
+		cr, err := cancelreader.NewReader(pr)
+		if err != nil {
+			return err
+		}
+		defer cr.Close()
+
+		out := opts.output()
+		d := cancelreader.NewDecoder(cr)
+		for {
+			ev, err := d.ReadEvent()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				out.error(err)
+				return err
+			}
+			out.event(ev)
+		}
+	}
+}
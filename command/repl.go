@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupREPL(_ *flag.FlagSet, opts *options) func([]string) error {
+	return func([]string) error {
+		opts.raw = true
+		opts.translate = true
+
+		restore := enableVT()
+		defer restore()
+
+		cr, cleanup, err := opts.reader()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		fmt.Fprint(os.Stderr, "Edit with arrows, Home/End, Backspace/Delete; Up/Down for history;\r\n"+
+			"Ctrl+C or Esc discards the line, Ctrl+D on an empty line quits\r\n")
+
+		e := &lineEditor{d: cancelreader.NewDecoder(cr), w: os.Stdout, prompt: "> "}
+		for {
+			line, err := e.readLine()
+			switch {
+			case errors.Is(err, errDiscarded):
+				continue
+			case errors.Is(err, io.EOF):
+				return nil
+			case errors.Is(err, cancelreader.ErrCanceled):
+				fmt.Print("canceled\r\n")
+				return nil
+			case err != nil:
+				return err
+			}
+			fmt.Printf("you typed %q\r\n", line)
+		}
+	}
+}
+
+var errDiscarded = errors.New("line discarded")
+
+// lineEditor reads lines with editing and history from decoded terminal
+// events.
+type lineEditor struct {
+	d       *cancelreader.Decoder
+	w       io.Writer
+	prompt  string
+	history []string
+}
+
+// readLine reads a line. It returns errDiscarded if the line was discarded
+// with Ctrl+C or Esc and io.EOF for Ctrl+D on an empty line.
+func (e *lineEditor) readLine() (string, error) {
+	var (
+		line   []rune
+		pos    int
+		hist   = len(e.history)
+		edited string
+	)
+
+	e.render(line, pos)
+	for {
+		ev, err := e.d.ReadEvent()
+		if err != nil {
+			fmt.Fprint(e.w, "\r\n")
+			return "", err
+		}
+
+		key, ok := ev.(cancelreader.KeyEvent)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case key.Key == cancelreader.KeyEnter:
+			fmt.Fprint(e.w, "\r\n")
+			if len(line) > 0 {
+				e.history = append(e.history, string(line))
+			}
+			return string(line), nil
+		case key.Key == cancelreader.KeyEscape || isCtrl(key, 'c'):
+			fmt.Fprint(e.w, "^C\r\n")
+			return "", errDiscarded
+		case isCtrl(key, 'd') && len(line) == 0:
+			fmt.Fprint(e.w, "\r\n")
+			return "", io.EOF
+		case key.Key == cancelreader.KeyRune && key.Mod&^cancelreader.ModShift == 0:
+			line = append(line[:pos], append([]rune{key.Rune}, line[pos:]...)...)
+			pos++
+		case key.Key == cancelreader.KeyBackspace && pos > 0:
+			line = append(line[:pos-1], line[pos:]...)
+			pos--
+		case key.Key == cancelreader.KeyDelete && pos < len(line):
+			line = append(line[:pos], line[pos+1:]...)
+		case key.Key == cancelreader.KeyLeft && pos > 0:
+			pos--
+		case key.Key == cancelreader.KeyRight && pos < len(line):
+			pos++
+		case key.Key == cancelreader.KeyHome || isCtrl(key, 'a'):
+			pos = 0
+		case key.Key == cancelreader.KeyEnd || isCtrl(key, 'e'):
+			pos = len(line)
+		case key.Key == cancelreader.KeyUp && hist > 0:
+			if hist == len(e.history) {
+				edited = string(line)
+			}
+			hist--
+			line = []rune(e.history[hist])
+			pos = len(line)
+		case key.Key == cancelreader.KeyDown && hist < len(e.history):
+			hist++
+			if hist == len(e.history) {
+				line = []rune(edited)
+			} else {
+				line = []rune(e.history[hist])
+			}
+			pos = len(line)
+		}
+
+		e.render(line, pos)
+	}
+}
+
+// render redraws the prompt and line and places the cursor at pos.
+func (e *lineEditor) render(line []rune, pos int) {
+	var b strings.Builder
+	b.WriteString("\r\x1b[K")
+	b.WriteString(e.prompt)
+	b.WriteString(string(line))
+	b.WriteString("\r")
+	if n := len([]rune(e.prompt)) + pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", n)
+	}
+	io.WriteString(e.w, b.String())
+}
+
+func isCtrl(key cancelreader.KeyEvent, r rune) bool {
+	return key.Key == cancelreader.KeyRune && key.Rune == r && key.Mod == cancelreader.ModCtrl
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupShell(_ *flag.FlagSet, _ *options) func([]string) error {
+	return runShell
+}
+
+// runShell alternately hands stdin to child shells directly and through
+// pipes fed by a CancelReader, with and without raw mode.
+func runShell([]string) error {
+	var (
+		raw   bool
+		once  bool
+		reset = func(*bool) {}
+		cmd   *exec.Cmd
+		arg0  = "bash"
+		arg1  = "-c"
+		arg2  = "echo Press any key to continue . . .;read -rn1"
+	)
+
+	defer func() { reset(&raw) }()
+	if IsCygwinTerminal(os.Stdin.Fd()) {
+		ConsoleCP(&once)
+	} else if runtime.GOOS == "windows" {
+		arg0 = "cmd"
+		arg1 = "/c"
+
+		// arg0 = "powershell"
+		// arg1 = "-command"
+
+		arg2 = "pause"
+	}
+
+	var (
+		cr  cancelreader.CancelReader
+		err error
+	)
+
+	for i := 0; i < 8; i++ {
+		if i%4 > 1 {
+			reset(&raw)
+			cmd = exec.Command(arg0)
+		} else {
+			reset = setRaw(&raw, reset)
+			cmd = exec.Command(arg0, arg1, arg2)
+		}
+		log.Println(cmd)
+		cr, err = cancelreader.NewReader(os.Stdin)
+		if err != nil {
+			panic(err)
+		}
+
+		if i < 4 {
+			// <Esc> <Esc> exit<Enter> exit<Enter>
+			log.Println("--without pipes", i)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			fmt.Print("\r")
+			if i%4 > 1 {
+				fmt.Println("Type exit<Enter>\r")
+			}
+			cmd.Run()
+		} else {
+			// <Esc> <Esc> exit<Enter> exit<Enter>
+			log.Println("--with pipes", i)
+			ConsoleCP(&once)
+
+			in, err := cmd.StdinPipe()
+			if err != nil {
+				panic(err)
+			}
+
+			out, err := cmd.StdoutPipe()
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Print("\r")
+			if i%4 > 1 {
+				fmt.Println("Type exit<Enter>\r")
+			}
+			err = cmd.Start()
+			if err != nil {
+				panic(err)
+			}
+
+			go func() {
+				_, err = io.Copy(os.Stdout, out)
+				log.Println("Stdout done", i, err)
+				log.Println("Cancel read stdin", i, cr.Cancel())
+			}()
+
+			_, err = io.Copy(in, cr)
+			log.Println("Stdin done", i, err)
+
+			log.Println("Wait", cmd.Wait())
+			log.Println("Close", cr.Close())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupStress(fs *flag.FlagSet, _ *options) func([]string) error {
+	readers := fs.Int("readers", 16, "number of concurrent readers")
+	cycles := fs.Int("cycles", 200, "read/cancel/reset cycles per reader")
+	maxDelay := fs.Duration("max-delay", 2*time.Millisecond, "maximum random delay before canceling or writing")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random seed")
+
+	return func([]string) error {
+		goroutines, handles := runtime.NumGoroutine(), handleCount()
+		start := time.Now()
+
+		var (
+			wg       sync.WaitGroup
+			lock     sync.Mutex
+			failures []error
+		)
+		for i := 0; i < *readers; i++ {
+			wg.Add(1)
+			rnd := rand.New(rand.NewSource(*seed + int64(i)))
+			go func(i int) {
+				defer wg.Done()
+				for c := 0; c < *cycles; c++ {
+					if err := stressCycle(rnd, *maxDelay); err != nil {
+						lock.Lock()
+						failures = append(failures, fmt.Errorf("reader %d cycle %d: %w", i, c, err))
+						lock.Unlock()
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		// give exiting goroutines a moment to finish
+		time.Sleep(10 * time.Millisecond)
+
+		fmt.Printf("seed %d: %d cycles in %v, %d failures\n", *seed, *readers**cycles, time.Since(start), len(failures))
+		fmt.Printf("goroutines %d -> %d, handles %d -> %d\n", goroutines, runtime.NumGoroutine(), handles, handleCount())
+		for i, err := range failures {
+			if i == 10 {
+				fmt.Printf("... and %d more\n", len(failures)-i)
+				break
+			}
+			fmt.Println(err)
+		}
+
+		if len(failures) > 0 {
+			return fmt.Errorf("%d failures", len(failures))
+		}
+		return nil
+	}
+}
+
+// stressCycle creates a reader on a pipe, either reads data written after a
+// random delay or cancels a blocked read after a random delay, and closes
+// the reader again.
+func stressCycle(rnd *rand.Rand, maxDelay time.Duration) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := cancelreader.NewReader(pr)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := cr.Read(b[:])
+		done <- err
+	}()
+
+	delay := time.Duration(rnd.Int63n(int64(maxDelay) + 1))
+	write := rnd.Intn(2) == 0
+	time.Sleep(delay)
+
+	canceled := false
+	if write {
+		_, err = pw.Write([]byte{'x'})
+	} else {
+		canceled = cr.Cancel()
+	}
+
+	select {
+	case readErr := <-done:
+		switch {
+		case err != nil:
+		case write && readErr != nil:
+			err = fmt.Errorf("read after write: %w", readErr)
+		case !write && !canceled:
+			err = fmt.Errorf("cancel after %v returned false", delay)
+		case !write && !errors.Is(readErr, cancelreader.ErrCanceled):
+			err = fmt.Errorf("canceled read returned %v", readErr)
+		}
+	case <-time.After(time.Second):
+		// unblock the read so that the goroutine does not leak
+		_, _ = pw.Write([]byte{'x'})
+		cr.Cancel()
+		err = fmt.Errorf("read hangs after write=%v delay=%v", write, delay)
+	}
+
+	return errors.Join(err, cr.Close())
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abakum/cancelreader"
+)
+
+func setupThroughput(fs *flag.FlagSet, opts *options) func([]string) error {
+	source := fs.String("source", "pipe", "flood generator: pipe or pty")
+	size := fs.Int("size", 16<<20, "number of bytes per measurement")
+	buffers := fs.String("buffers", "1,64,1024,4096,32768", "comma separated read buffer sizes")
+
+	return func([]string) error {
+		var sizes []int
+		for _, s := range strings.Split(*buffers, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid buffer size %q", s)
+			}
+			sizes = append(sizes, n)
+		}
+
+		fmt.Printf("%-10s %8s %12s %12s\n", "backend", "buffer", "MB/s", "bytes/read")
+		for _, backend := range cancelreader.Backends() {
+			for _, buffer := range sizes {
+				opts.backend = backend
+
+				d, reads, err := measureThroughput(*source, *size, buffer, opts.readerOptions()...)
+				if err != nil {
+					fmt.Printf("%-10s %8d %v\n", backend, buffer, err)
+					continue
+				}
+
+				fmt.Printf("%-10s %8d %12.1f %12.1f\n", backend, buffer,
+					float64(*size)/d.Seconds()/1e6, float64(*size)/float64(reads))
+			}
+		}
+		return nil
+	}
+}
+
+// measureThroughput floods a pipe or pseudo terminal with size bytes and
+// returns how long it took to read them with the given buffer size and how
+// many Read calls were needed.
+func measureThroughput(source string, size, buffer int, ropts ...cancelreader.Option) (time.Duration, int, error) {
+	var (
+		r   *os.File
+		w   io.WriteCloser
+		err error
+	)
+
+	switch source {
+	case "pipe":
+		r, w, err = os.Pipe()
+	case "pty":
+		r, w, err = openPty()
+	default:
+		err = fmt.Errorf("unknown source %q", source)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer r.Close()
+	defer w.Close()
+
+	cr, err := cancelreader.NewReader(r, ropts...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cr.Close()
+
+	written := make(chan error, 1)
+	go func() {
+		chunk := bytes.Repeat([]byte{'x'}, 32<<10)
+		for left := size; left > 0; left -= len(chunk) {
+			if left < len(chunk) {
+				chunk = chunk[:left]
+			}
+			if _, err := w.Write(chunk); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	buf := make([]byte, buffer)
+	start := time.Now()
+	reads := 0
+	for total := 0; total < size; reads++ {
+		n, err := cr.Read(buf)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += n
+	}
+	d := time.Since(start)
+
+	return d, reads, <-written
+}
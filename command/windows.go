@@ -4,8 +4,12 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"unsafe"
+
+	"github.com/abakum/cancelreader"
 	"github.com/mattn/go-isatty"
-	"github.com/xlab/closer"
 	"golang.org/x/sys/windows"
 )
 
@@ -15,34 +19,82 @@ func ConsoleCP(once *bool) {
 	}
 	*once = false
 	const CP_UTF8 uint32 = 65001
-	var kernel32 = windows.NewLazyDLL("kernel32.dll")
 
-	getConsoleCP := func() uint32 {
-		result, _, _ := kernel32.NewProc("GetConsoleCP").Call()
-		return uint32(result)
+	session, err := cancelreader.NewConsoleSession()
+	if err != nil {
+		log.Println(err)
+		return
 	}
-
-	getConsoleOutputCP := func() uint32 {
-		result, _, _ := kernel32.NewProc("GetConsoleOutputCP").Call()
-		return uint32(result)
+	err = session.Update(cancelreader.ConsoleOptions{CodePage: CP_UTF8})
+	if err != nil {
+		log.Println(err)
+		return
 	}
+	teardown.Add(cancelreader.StageConsole, session.Restore)
+}
+
+func IsCygwinTerminal(fd uintptr) bool {
+	return isatty.IsCygwinTerminal(fd)
+}
 
-	setConsoleCP := func(cp uint32) {
-		kernel32.NewProc("SetConsoleCP").Call(uintptr(cp))
+func IsWinpty() bool {
+	return cancelreader.IsWinpty()
+}
+
+func logConsoleModes() {
+	modes, err := cancelreader.GetConsoleModes()
+	log.Println("Console modes", modes, err)
+}
+
+// enableEvents makes the console report mouse and window size events.
+func enableEvents(mouse bool) (func(), error) {
+	session, err := cancelreader.NewConsoleSession()
+	if err != nil {
+		return nil, err
+	}
+	err = session.Update(cancelreader.ConsoleOptions{MouseInput: mouse, WindowInput: true, ScreenReader: cancelreader.ScreenReaderActive()})
+	if err != nil {
+		return nil, err
 	}
+	return func() { session.Restore() }, nil
+}
 
-	setConsoleOutputCP := func(cp uint32) {
-		kernel32.NewProc("SetConsoleOutputCP").Call(uintptr(cp))
+var procGetProcessHandleCount = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+
+// handleCount returns the number of open handles or -1 if it is unknown.
+func handleCount() int {
+	var count uint32
+	r, _, _ := procGetProcessHandleCount.Call(uintptr(windows.CurrentProcess()), uintptr(unsafe.Pointer(&count)))
+	if r == 0 {
+		return -1
 	}
+	return int(count)
+}
 
-	inCP := getConsoleCP()
-	outCP := getConsoleOutputCP()
-	setConsoleCP(CP_UTF8)
-	setConsoleOutputCP(CP_UTF8)
-	closer.Bind(func() { setConsoleCP(inCP) })
-	closer.Bind(func() { setConsoleOutputCP(outCP) })
+var (
+	procGetConsoleCP       = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetConsoleCP")
+	procGetConsoleOutputCP = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetConsoleOutputCP")
+)
+
+// platformReport returns the console modes and code pages for the doctor
+// report.
+func platformReport() []string {
+	modes, err := cancelreader.GetConsoleModes()
+	in, _, _ := procGetConsoleCP.Call()
+	out, _, _ := procGetConsoleOutputCP.Call()
+	return []string{
+		fmt.Sprintf("console:   %v %v", modes, err),
+		fmt.Sprintf("codepages: input=%d output=%d", in, out),
+		fmt.Sprintf("winpty:    %v", cancelreader.IsWinpty()),
+	}
 }
 
-func IsCygwinTerminal(fd uintptr) bool {
-	return isatty.IsCygwinTerminal(fd)
+// enableVT enables escape sequence processing of the console.
+func enableVT() func() {
+	session, err := cancelreader.PrepareConsoleOutput()
+	if err != nil {
+		log.Println(err)
+		return func() {}
+	}
+	return func() { session.Restore() }
 }
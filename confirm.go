@@ -0,0 +1,106 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReadConfirm writes prompt to the terminal w with a countdown of the
+// seconds left and waits for the answer from r: y or n, or Enter for def.
+// Other keys are ignored. def is returned if no answer arrives within
+// timeout and ErrCanceled if r is canceled. The terminal should be in raw
+// mode, so that the answer arrives without Enter, see Attach.
+//
+// The countdown is redrawn every second if r implements ContextReader, like
+// the readers returned by NewReader. Other readers are canceled on timeout,
+// like by Query.
+func ReadConfirm(r CancelReader, w io.Writer, prompt string, def bool, timeout time.Duration) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+
+	answer := func(yes bool) (bool, error) {
+		s := "n"
+		if yes {
+			s = "y"
+		}
+		_, err := fmt.Fprintf(w, "%s\r\n", s)
+
+		return yes, err // nolint: wrapcheck
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	var src io.Reader = r
+
+	_, countdown := r.(ContextReader)
+	if !countdown {
+		timer := time.AfterFunc(timeout, func() { r.Cancel() })
+		defer timer.Stop()
+
+		// the canceled Read is reported as a timeout below
+		src = &timeoutReader{CancelReader: r, timer: timer}
+	}
+
+	var buf [16]byte
+
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return answer(def)
+		}
+
+		_, err := fmt.Fprintf(w, "\r%s [%s] %ds ", prompt, choices, (left+time.Second-1)/time.Second)
+		if err != nil {
+			return def, fmt.Errorf("write prompt: %w", err)
+		}
+
+		if left > time.Second && countdown {
+			left = time.Second
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), left)
+		n, err := readContext(ctx, src, buf[:])
+		cancel()
+
+		for _, c := range buf[:n] {
+			switch c {
+			case 'y', 'Y':
+				return answer(true)
+			case 'n', 'N':
+				return answer(false)
+			case '\r', '\n':
+				return answer(def)
+			}
+		}
+
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			continue
+		case errors.Is(err, ErrTimeout):
+			return answer(def)
+		case err != nil:
+			_, _ = io.WriteString(w, "\r\n")
+			return def, err // nolint: wrapcheck
+		}
+	}
+}
+
+// timeoutReader reports the Read canceled by its timer as ErrTimeout.
+type timeoutReader struct {
+	CancelReader
+	timer *time.Timer
+}
+
+func (r *timeoutReader) Read(data []byte) (int, error) {
+	n, err := r.CancelReader.Read(data)
+	if errors.Is(err, ErrCanceled) && !r.timer.Stop() {
+		return n, ErrTimeout
+	}
+
+	return n, err // nolint: wrapcheck
+}
@@ -0,0 +1,37 @@
+package cancelreader
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadConfirm(t *testing.T) {
+	var w bytes.Buffer
+	yes, err := ReadConfirm(newChanReader("x", "Y"), &w, "continue?", false, time.Second)
+	if err != nil || !yes {
+		t.Errorf("expected yes, but got %v, %v", yes, err)
+	}
+	if !strings.HasPrefix(w.String(), "\rcontinue? [y/N] 1s ") || !strings.HasSuffix(w.String(), "y\r\n") {
+		t.Errorf("expected the prompt and the answer, but got %q", w.String())
+	}
+
+	yes, err = ReadConfirm(newChanReader("\r"), ioutil.Discard, "continue?", true, time.Second)
+	if err != nil || !yes {
+		t.Errorf("expected the default on Enter, but got %v, %v", yes, err)
+	}
+
+	yes, err = ReadConfirm(newChanReader(), ioutil.Discard, "continue?", true, 50*time.Millisecond)
+	if err != nil || !yes {
+		t.Errorf("expected the default on timeout, but got %v, %v", yes, err)
+	}
+
+	cr := newChanReader()
+	time.AfterFunc(20*time.Millisecond, func() { cr.Cancel() })
+	if _, err := ReadConfirm(cr, ioutil.Discard, "continue?", true, time.Second); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// connFile makes a syscall.Conn, e.g. a *net.UnixConn, usable as a File. It
+// reads through the connection, so that its own buffering and deadlines
+// still apply, while the backend waits on a duplicate of its file
+// descriptor. The descriptor of the connection itself is only valid within
+// RawConn.Control, afterwards the runtime may close and reuse it.
+type connFile struct {
+	io.Reader
+	fd   uintptr // duplicate owned by connFile
+	name string
+}
+
+// asFile returns reader as a File if it is a syscall.Conn but no File.
+// Otherwise, or if descriptors can't be duplicated on this platform, it
+// returns reader unchanged.
+func asFile(reader io.Reader) (io.Reader, error) {
+	if _, ok := reader.(File); ok {
+		return reader, nil
+	}
+
+	sc, ok := reader.(syscall.Conn)
+	if !ok {
+		return reader, nil
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("get raw connection: %w", err)
+	}
+
+	var fd uintptr
+	var dupErr error
+	err = raw.Control(func(s uintptr) { fd, dupErr = dupFd(s) })
+	if errors.Is(dupErr, errors.ErrUnsupported) {
+		return reader, nil
+	}
+	if err == nil {
+		err = dupErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get file descriptor: %w", err)
+	}
+
+	return &connFile{Reader: reader, fd: fd, name: fmt.Sprintf("%T", reader)}, nil
+}
+
+func (f *connFile) Write(data []byte) (int, error) {
+	w, ok := f.Reader.(io.Writer)
+	if !ok {
+		return 0, fmt.Errorf("%s is not writable", f.name)
+	}
+
+	return w.Write(data) // nolint: wrapcheck
+}
+
+// Close closes the duplicate descriptor. The connection is closed by its
+// owner or with WithOwnership.
+func (f *connFile) Close() error {
+	return closeFd(f.fd)
+}
+
+func (f *connFile) Fd() uintptr {
+	return f.fd
+}
+
+func (f *connFile) Name() string {
+	return f.name
+}
@@ -0,0 +1,67 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSyscallConn(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "local")
+	remote := os.NewFile(uintptr(fds[1]), "remote")
+	defer remote.Close()
+
+	conn, err := net.FileConn(local)
+	_ = local.Close()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer conn.Close()
+
+	r, err := NewReader(conn, WithDupFd())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if !CanCancel(r) {
+		t.Errorf("expected a cancelable backend, but got %s", Backend(r))
+	}
+
+	if _, err := remote.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	data := make([]byte, 1)
+	if _, err := r.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		r.Cancel()
+	}()
+	if _, err := r.Read(data); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	// closing the reader closes its duplicate but not the connection
+	if err := r.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if _, err := remote.Write([]byte("b")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if _, err := conn.Read(data); err != nil || data[0] != 'b' {
+		t.Errorf("expected %q, but got %q, %v", "b", data, err)
+	}
+}
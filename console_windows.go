@@ -0,0 +1,496 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// InputMode is a console input mode as used by GetConsoleMode and
+// SetConsoleMode on a console input handle.
+type InputMode uint32
+
+// OutputMode is a console output mode as used by GetConsoleMode and
+// SetConsoleMode on a console screen buffer handle.
+type OutputMode uint32
+
+type modeFlag struct {
+	flag uint32
+	name string
+}
+
+var inputModeFlags = []modeFlag{
+	{windows.ENABLE_PROCESSED_INPUT, "ENABLE_PROCESSED_INPUT"},
+	{windows.ENABLE_LINE_INPUT, "ENABLE_LINE_INPUT"},
+	{windows.ENABLE_ECHO_INPUT, "ENABLE_ECHO_INPUT"},
+	{windows.ENABLE_WINDOW_INPUT, "ENABLE_WINDOW_INPUT"},
+	{windows.ENABLE_MOUSE_INPUT, "ENABLE_MOUSE_INPUT"},
+	{windows.ENABLE_INSERT_MODE, "ENABLE_INSERT_MODE"},
+	{windows.ENABLE_QUICK_EDIT_MODE, "ENABLE_QUICK_EDIT_MODE"},
+	{windows.ENABLE_EXTENDED_FLAGS, "ENABLE_EXTENDED_FLAGS"},
+	{windows.ENABLE_AUTO_POSITION, "ENABLE_AUTO_POSITION"},
+	{windows.ENABLE_VIRTUAL_TERMINAL_INPUT, "ENABLE_VIRTUAL_TERMINAL_INPUT"},
+}
+
+var outputModeFlags = []modeFlag{
+	{windows.ENABLE_PROCESSED_OUTPUT, "ENABLE_PROCESSED_OUTPUT"},
+	{windows.ENABLE_WRAP_AT_EOL_OUTPUT, "ENABLE_WRAP_AT_EOL_OUTPUT"},
+	{windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING, "ENABLE_VIRTUAL_TERMINAL_PROCESSING"},
+	{windows.DISABLE_NEWLINE_AUTO_RETURN, "DISABLE_NEWLINE_AUTO_RETURN"},
+	{windows.ENABLE_LVB_GRID_WORLDWIDE, "ENABLE_LVB_GRID_WORLDWIDE"},
+}
+
+// Flags returns the symbolic names of the flags set in m. Unknown bits are
+// reported in hex.
+func (m InputMode) Flags() []string {
+	return modeFlagNames(uint32(m), inputModeFlags)
+}
+
+func (m InputMode) String() string {
+	return strings.Join(m.Flags(), "|")
+}
+
+// Flags returns the symbolic names of the flags set in m. Unknown bits are
+// reported in hex.
+func (m OutputMode) Flags() []string {
+	return modeFlagNames(uint32(m), outputModeFlags)
+}
+
+func (m OutputMode) String() string {
+	return strings.Join(m.Flags(), "|")
+}
+
+func modeFlagNames(mode uint32, flags []modeFlag) []string {
+	names := []string{}
+
+	for _, f := range flags {
+		if mode&f.flag != 0 {
+			names = append(names, f.name)
+			mode &^= f.flag
+		}
+	}
+
+	if mode != 0 {
+		names = append(names, fmt.Sprintf("0x%x", mode))
+	}
+
+	if len(names) == 0 {
+		names = append(names, "0")
+	}
+
+	return names
+}
+
+// ConsoleModes holds the console modes of the standard input and output
+// handles. HasInput and HasOutput are false if the respective handle is not
+// a console, e.g. because it was redirected.
+type ConsoleModes struct {
+	Input     InputMode
+	Output    OutputMode
+	HasInput  bool
+	HasOutput bool
+}
+
+func (m ConsoleModes) String() string {
+	in, out := "none", "none"
+	if m.HasInput {
+		in = m.Input.String()
+	}
+
+	if m.HasOutput {
+		out = m.Output.String()
+	}
+
+	return fmt.Sprintf("input=%s output=%s", in, out)
+}
+
+// GetConsoleModes reads the current console modes of os.Stdin and
+// os.Stdout. It only fails if neither of them is a console.
+func GetConsoleModes() (ConsoleModes, error) {
+	var (
+		modes      ConsoleModes
+		mode       uint32
+		inErr, err error
+	)
+
+	inErr = windows.GetConsoleMode(windows.Handle(os.Stdin.Fd()), &mode)
+	if inErr == nil {
+		modes.Input = InputMode(mode)
+		modes.HasInput = true
+	}
+
+	err = windows.GetConsoleMode(windows.Handle(os.Stdout.Fd()), &mode)
+	if err == nil {
+		modes.Output = OutputMode(mode)
+		modes.HasOutput = true
+	}
+
+	if !modes.HasInput && !modes.HasOutput {
+		return modes, fmt.Errorf("get console mode: %w", inErr)
+	}
+
+	return modes, nil
+}
+
+// ConsoleOptions describes the console changes a ConsoleSession applies on
+// top of the original console state. Every field set to its zero value keeps
+// the original setting.
+type ConsoleOptions struct {
+	// RawInput disables line input, echo and Ctrl+C processing.
+	RawInput bool
+
+	// VirtualTerminalInput makes the console report special keys as escape
+	// sequences. Legacy consoles reject it.
+	VirtualTerminalInput bool
+
+	// MouseInput makes the console report mouse events and disables the
+	// quick edit mode which would otherwise consume them.
+	MouseInput bool
+
+	// WindowInput makes the console report changes of its size.
+	WindowInput bool
+
+	// VirtualTerminalOutput enables ANSI escape sequence processing.
+	VirtualTerminalOutput bool
+
+	// DisableNewlineAutoReturn makes a line feed only move the cursor down
+	// like on unix terminals. Consoles before Windows 10 1607 reject it.
+	DisableNewlineAutoReturn bool
+
+	// CodePage sets the input and output code page, e.g. 65001 for UTF-8.
+	CodePage uint32
+
+	// ScreenReader avoids changes known to break screen readers: RawInput
+	// keeps Ctrl+C processing and MouseInput is ignored. See
+	// ScreenReaderActive.
+	ScreenReader bool
+}
+
+// ConsoleSession tracks the changes made to the console behind os.Stdin and
+// os.Stdout, so that they can be updated and restored as a whole. All
+// methods are safe for concurrent use.
+type ConsoleSession struct {
+	lock sync.Mutex
+
+	in, out           windows.Handle
+	hasIn, hasOut     bool
+	origIn, origOut   uint32
+	origInCP, origOCP uint32
+
+	opts    ConsoleOptions
+	changed bool
+}
+
+// NewConsoleSession records the current console state without changing it.
+// It only fails if neither os.Stdin nor os.Stdout is a console.
+func NewConsoleSession() (*ConsoleSession, error) {
+	s := &ConsoleSession{
+		in:  windows.Handle(os.Stdin.Fd()),
+		out: windows.Handle(os.Stdout.Fd()),
+	}
+
+	inErr := windows.GetConsoleMode(s.in, &s.origIn)
+	s.hasIn = inErr == nil
+	s.hasOut = windows.GetConsoleMode(s.out, &s.origOut) == nil
+
+	if !s.hasIn && !s.hasOut {
+		return nil, fmt.Errorf("get console mode: %w", inErr)
+	}
+
+	s.origInCP = getConsoleCP(procGetConsoleCP)
+	s.origOCP = getConsoleCP(procGetConsoleOutputCP)
+
+	return s, nil
+}
+
+// Update applies opts relative to the original console state, so settings
+// enabled by a previous Update but missing from opts are reverted. If the
+// console rejects the new state, the previous one is kept.
+func (s *ConsoleSession) Update(opts ConsoleOptions) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prev := s.opts
+	err := s.apply(opts)
+	if err != nil {
+		_ = s.apply(prev)
+		return err
+	}
+
+	return nil
+}
+
+// Restore resets the flags and code pages changed by the session to the
+// state recorded by NewConsoleSession. Calling it multiple times is harmless
+// and the session can be updated again afterwards.
+func (s *ConsoleSession) Restore() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.changed {
+		return nil
+	}
+
+	err := s.apply(ConsoleOptions{})
+	s.changed = false
+	s.setLive(false)
+
+	return err
+}
+
+// Options returns the options currently applied by the session.
+func (s *ConsoleSession) Options() ConsoleOptions {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.opts
+}
+
+// Modes reads the current console modes of the handles tracked by the
+// session.
+func (s *ConsoleSession) Modes() (ConsoleModes, error) {
+	var (
+		modes ConsoleModes
+		mode  uint32
+	)
+
+	if s.hasIn {
+		if err := windows.GetConsoleMode(s.in, &mode); err != nil {
+			return modes, fmt.Errorf("get console input mode: %w", err)
+		}
+
+		modes.Input, modes.HasInput = InputMode(mode), true
+	}
+
+	if s.hasOut {
+		if err := windows.GetConsoleMode(s.out, &mode); err != nil {
+			return modes, fmt.Errorf("get console output mode: %w", err)
+		}
+
+		modes.Output, modes.HasOutput = OutputMode(mode), true
+	}
+
+	return modes, nil
+}
+
+// liveSessions holds the sessions with changes, which are applied again
+// after the session of the process was reconnected.
+var liveSessions struct {
+	lock     sync.Mutex
+	sessions map[*ConsoleSession]struct{}
+}
+
+// reapplyConsoleSessions applies the changes of all sessions again, since a
+// console may come back from a reconnect with its modes reset.
+func reapplyConsoleSessions() {
+	liveSessions.lock.Lock()
+	sessions := make([]*ConsoleSession, 0, len(liveSessions.sessions))
+	for s := range liveSessions.sessions {
+		sessions = append(sessions, s)
+	}
+	liveSessions.lock.Unlock()
+
+	for _, s := range sessions {
+		_ = s.Update(s.Options())
+	}
+}
+
+func (s *ConsoleSession) setLive(live bool) {
+	liveSessions.lock.Lock()
+	defer liveSessions.lock.Unlock()
+
+	if !live {
+		delete(liveSessions.sessions, s)
+		return
+	}
+
+	if liveSessions.sessions == nil {
+		liveSessions.sessions = map[*ConsoleSession]struct{}{}
+	}
+	liveSessions.sessions[s] = struct{}{}
+}
+
+// apply changes the console from the state of the applied options to the
+// one of opts. Only the mode flags and code pages either of them touch are
+// written, so changes made by other components in the meantime are kept.
+func (s *ConsoleSession) apply(opts ConsoleOptions) error {
+	prev := s.opts
+	s.changed = true
+	s.opts = opts
+	s.setLive(opts != ConsoleOptions{})
+
+	if s.hasIn {
+		err := updateConsoleMode(s.in, s.origIn, prev.inputMode(s.origIn), opts.inputMode(s.origIn))
+		if err != nil {
+			return fmt.Errorf("set console input mode: %w", err)
+		}
+	} else if opts.RawInput || opts.VirtualTerminalInput || opts.MouseInput || opts.WindowInput {
+		return fmt.Errorf("stdin is not a console")
+	}
+
+	if s.hasOut {
+		err := updateConsoleMode(s.out, s.origOut, prev.outputMode(s.origOut), opts.outputMode(s.origOut))
+		if err != nil {
+			return fmt.Errorf("set console output mode: %w", err)
+		}
+	} else if opts.VirtualTerminalOutput || opts.DisableNewlineAutoReturn {
+		return fmt.Errorf("stdout is not a console")
+	}
+
+	if opts.CodePage == 0 && prev.CodePage == 0 {
+		return nil
+	}
+
+	inCP, outCP := s.origInCP, s.origOCP
+	if opts.CodePage != 0 {
+		inCP, outCP = opts.CodePage, opts.CodePage
+	}
+
+	if inCP != 0 {
+		if err := setConsoleCP(procSetConsoleCP, inCP); err != nil {
+			return fmt.Errorf("set console input code page: %w", err)
+		}
+	}
+
+	if outCP != 0 {
+		if err := setConsoleCP(procSetConsoleOutputCP, outCP); err != nil {
+			return fmt.Errorf("set console output code page: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// updateConsoleMode sets the flags of the console mode of h that applied or
+// want change in orig to their value in want and keeps all others.
+func updateConsoleMode(h windows.Handle, orig, applied, want uint32) error {
+	var cur uint32
+
+	err := windows.GetConsoleMode(h, &cur)
+	if err != nil {
+		return err // nolint: wrapcheck
+	}
+
+	mode := mergeMode(cur, orig, applied, want)
+	if mode == cur {
+		return nil
+	}
+
+	return windows.SetConsoleMode(h, mode) // nolint: wrapcheck
+}
+
+// mergeMode returns cur with the flags that applied or want change in orig
+// set to their value in want.
+func mergeMode(cur, orig, applied, want uint32) uint32 {
+	touched := (applied ^ orig) | (want ^ orig)
+
+	return cur&^touched | want&touched
+}
+
+// PrepareConsole puts the console behind os.Stdin into raw mode: line input,
+// echo and Ctrl+C processing are disabled and, where supported, virtual
+// terminal input is enabled so that special keys are reported as escape
+// sequences. Ctrl+C processing is kept if ScreenReaderActive. Call Restore on
+// the returned session to reset the original input mode.
+func PrepareConsole() (*ConsoleSession, error) {
+	s, err := NewConsoleSession()
+	if err != nil {
+		return nil, err
+	}
+
+	screenReader := ScreenReaderActive()
+
+	err = s.Update(ConsoleOptions{RawInput: true, VirtualTerminalInput: true, ScreenReader: screenReader})
+	if err != nil {
+		err = s.Update(ConsoleOptions{RawInput: true, ScreenReader: screenReader})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// PrepareConsoleOutput enables ANSI escape sequence processing on the console
+// behind os.Stdout. DISABLE_NEWLINE_AUTO_RETURN is enabled as well if the
+// console supports it, so that a line feed only moves the cursor down like on
+// unix terminals. Call Restore on the returned session to reset the original
+// output mode.
+func PrepareConsoleOutput() (*ConsoleSession, error) {
+	s, err := NewConsoleSession()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Update(ConsoleOptions{VirtualTerminalOutput: true, DisableNewlineAutoReturn: true})
+	if err != nil {
+		err = s.Update(ConsoleOptions{VirtualTerminalOutput: true})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// inputMode returns the console input mode opts make of orig.
+func (opts ConsoleOptions) inputMode(orig uint32) uint32 {
+	mode := orig
+	if opts.RawInput {
+		mode &^= windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT
+		if !opts.ScreenReader {
+			mode &^= windows.ENABLE_PROCESSED_INPUT
+		}
+	}
+
+	if opts.VirtualTerminalInput {
+		mode |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	}
+
+	if opts.MouseInput && !opts.ScreenReader {
+		mode = mode&^windows.ENABLE_QUICK_EDIT_MODE | windows.ENABLE_MOUSE_INPUT | windows.ENABLE_EXTENDED_FLAGS
+	}
+
+	if opts.WindowInput {
+		mode |= windows.ENABLE_WINDOW_INPUT
+	}
+
+	return mode
+}
+
+// outputMode returns the console output mode opts make of orig.
+func (opts ConsoleOptions) outputMode(orig uint32) uint32 {
+	mode := orig
+	if opts.VirtualTerminalOutput {
+		mode |= windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
+	}
+
+	if opts.DisableNewlineAutoReturn {
+		mode |= windows.DISABLE_NEWLINE_AUTO_RETURN
+	}
+
+	return mode
+}
+
+func getConsoleCP(proc *windows.LazyProc) uint32 {
+	r, _, _ := syscall.Syscall(proc.Addr(), 0, 0, 0, 0)
+	return uint32(r)
+}
+
+func setConsoleCP(proc *windows.LazyProc, cp uint32) error {
+	r, _, e := syscall.Syscall(proc.Addr(), 1, uintptr(cp), 0, 0)
+	if r == 0 {
+		return error(e)
+	}
+
+	return nil
+}
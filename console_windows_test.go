@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestConsoleModesString(t *testing.T) {
+	modes := ConsoleModes{
+		Input:    InputMode(windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_VIRTUAL_TERMINAL_INPUT | 0x8000),
+		HasInput: true,
+	}
+
+	expected := "input=ENABLE_PROCESSED_INPUT|ENABLE_VIRTUAL_TERMINAL_INPUT|0x8000 output=none"
+	if modes.String() != expected {
+		t.Errorf("expected %q, got %q", expected, modes.String())
+	}
+
+	if OutputMode(0).String() != "0" {
+		t.Errorf("expected %q, got %q", "0", OutputMode(0).String())
+	}
+}
+
+func TestMergeMode(t *testing.T) {
+	orig := uint32(windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT)
+	raw := ConsoleOptions{RawInput: true}.inputMode(orig)
+
+	// another component enabled mouse input after the session went raw
+	cur := raw | windows.ENABLE_MOUSE_INPUT
+
+	restored := mergeMode(cur, orig, raw, orig)
+	if restored != orig|windows.ENABLE_MOUSE_INPUT {
+		t.Errorf("expected %s, but got %s", InputMode(orig|windows.ENABLE_MOUSE_INPUT), InputMode(restored))
+	}
+
+	if mode := mergeMode(cur, orig, raw, raw); mode != cur {
+		t.Errorf("expected %s, but got %s", InputMode(cur), InputMode(mode))
+	}
+}
@@ -0,0 +1,227 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ContextReader is implemented by the readers returned by NewReader.
+type ContextReader interface {
+	// ReadContext reads like Read but returns ctx.Err() once ctx is done
+	// before input is available. Unlike Cancel, this only aborts the one
+	// Read and the reader stays usable.
+	ReadContext(ctx context.Context, p []byte) (int, error)
+}
+
+// NewReaderContext returns a reader like NewReader that is canceled once ctx
+// is done. The pending and all following Reads then fail with an error that
+// wraps both ErrCanceled and ctx.Err().
+func NewReaderContext(ctx context.Context, reader io.Reader, opts ...Option) (CancelReader, error) {
+	r, err := NewReader(reader, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &ctxCancelReader{CancelReader: r, ctx: ctx}
+	cr.stop = context.AfterFunc(ctx, func() { r.Cancel() })
+
+	return cr, nil
+}
+
+// Copy copies from src to dst until the end of src, an error or until ctx is
+// done. src is wrapped with NewReader and opts unless it is a CancelReader
+// already. Once ctx is done, the error wraps both ErrCanceled and ctx.Err().
+func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...Option) (int64, error) {
+	cr, ok := src.(CancelReader)
+	if !ok {
+		r, err := NewReaderContext(ctx, src, opts...)
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+
+		return io.Copy(dst, r) // nolint: wrapcheck
+	}
+
+	stop := context.AfterFunc(ctx, func() { cr.Cancel() })
+	defer stop()
+
+	n, err := io.Copy(dst, cr)
+
+	return n, contextCanceled(ctx, err)
+}
+
+// ReadContext reads from r until ctx is done, like the ReadContext method of
+// ContextReader. Readers that do not implement ContextReader are canceled
+// when ctx is done and the error then wraps both ErrCanceled and ctx.Err().
+func ReadContext(ctx context.Context, r CancelReader, data []byte) (int, error) {
+	switch r.(type) {
+	case contextReader, ContextReader:
+		return readContext(ctx, r, data)
+	}
+
+	err := contextErr(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stop := context.AfterFunc(ctx, func() { r.Cancel() })
+	n, err := r.Read(data)
+	if !stop() {
+		err = contextCanceled(ctx, err)
+	}
+
+	return n, err
+}
+
+// ReadAtLeast reads from r into data until it read at least min bytes, like
+// io.ReadAtLeast, but stops once ctx is done or r is canceled. It returns the
+// number of bytes read before the error in any case, so that input collected
+// before a cancelation is not lost. If the input ends early, the error is
+// io.ErrUnexpectedEOF.
+func ReadAtLeast(ctx context.Context, r CancelReader, data []byte, min int) (int, error) {
+	if len(data) < min {
+		return 0, io.ErrShortBuffer
+	}
+
+	n := 0
+	for n < min {
+		m, err := ReadContext(ctx, r, data[n:])
+		n += m
+
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, io.EOF) && n >= min {
+			break
+		}
+		if errors.Is(err, io.EOF) && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return n, err
+	}
+
+	return n, nil
+}
+
+// ReadFull reads exactly len(data) bytes from r like io.ReadFull, see
+// ReadAtLeast.
+func ReadFull(ctx context.Context, r CancelReader, data []byte) (int, error) {
+	return ReadAtLeast(ctx, r, data, len(data))
+}
+
+// contextCanceled replaces ErrCanceled by a canceledError if ctx is done.
+func contextCanceled(ctx context.Context, err error) error {
+	if !errors.Is(err, ErrCanceled) || ctx.Err() == nil {
+		return err
+	}
+
+	return &canceledError{err: ctx.Err()}
+}
+
+type ctxCancelReader struct {
+	CancelReader
+	ctx  context.Context
+	stop func() bool
+}
+
+func (r *ctxCancelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *ctxCancelReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return r.readContext(ctx, data)
+}
+
+func (r *ctxCancelReader) readContext(ctx context.Context, data []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, &canceledError{err: r.ctx.Err()}
+	}
+
+	n, err := readContext(ctx, r.CancelReader, data)
+
+	return n, contextCanceled(r.ctx, err)
+}
+
+func (r *ctxCancelReader) poll(timeout time.Duration) (bool, error) {
+	return pollReader(r.CancelReader, timeout)
+}
+
+func (r *ctxCancelReader) Close() error {
+	r.stop()
+
+	return r.CancelReader.Close() // nolint: wrapcheck
+}
+
+func (r *ctxCancelReader) unwrap() CancelReader {
+	return r.CancelReader
+}
+
+// contextReader is implemented by readers that can wait for input until a
+// context is done using the mechanism of their backend.
+type contextReader interface {
+	readContext(ctx context.Context, data []byte) (int, error)
+}
+
+// readContext reads from r until ctx is done. Readers that implement
+// neither contextReader nor ContextReader are only checked before the Read.
+func readContext(ctx context.Context, r io.Reader, data []byte) (int, error) {
+	switch cr := r.(type) {
+	case contextReader:
+		return cr.readContext(ctx, data)
+	case ContextReader:
+		return cr.ReadContext(ctx, data)
+	}
+
+	err := contextErr(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.Read(data)
+}
+
+// contextDone reports whether err was returned because the context of a
+// single Read is done, as opposed to the reader being canceled.
+func contextDone(err error) bool {
+	if errors.Is(err, ErrCanceled) {
+		return false
+	}
+
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// contextErr returns ctx.Err() or context.DeadlineExceeded if the deadline
+// of ctx passed but its timer did not fire yet.
+func contextErr(ctx context.Context) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
+
+// timeUntil returns the time until the deadline of ctx rounded up to whole
+// milliseconds and false if ctx has no deadline.
+func timeUntil(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	timeout := time.Until(deadline)
+	if timeout < 0 {
+		return 0, true
+	}
+
+	return (timeout + time.Millisecond - 1).Truncate(time.Millisecond), true
+}
@@ -0,0 +1,131 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReaderContext(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := NewReaderContext(ctx, pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = r.Read(make([]byte, 1))
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ErrCanceled and context.Canceled, but got %v", err)
+	}
+
+	_, err = r.Read(make([]byte, 1))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+
+	if _, ok := infoOf(r); !ok {
+		t.Errorf("expected accessors to reach the reader")
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	streamCtx, stop := context.WithCancel(context.Background())
+	r := NewStreamReader(func() ([]byte, error) {
+		<-streamCtx.Done()
+		return nil, streamCtx.Err()
+	}, stop)
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := ReadContext(ctx, r, make([]byte, 1))
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrCanceled and context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _ = pw.Write([]byte("hello"))
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	var b strings.Builder
+	n, err := Copy(ctx, &b, pr)
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ErrCanceled and context.Canceled, but got %v", err)
+	}
+	if n != 5 || b.String() != "hello" {
+		t.Errorf("expected %q, but got %q (%d bytes)", "hello", b.String(), n)
+	}
+}
+
+func TestReadFull(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if _, err := pw.Write([]byte("abc")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	data := make([]byte, 5)
+	n, err := ReadFull(ctx, r, data)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, but got %v", err)
+	}
+	if string(data[:n]) != "abc" {
+		t.Errorf("expected %q, but got %q", "abc", data[:n])
+	}
+
+	if _, err := pw.Write([]byte("de")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	n, err = ReadAtLeast(context.Background(), r, data, 2)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "de" {
+		t.Errorf("expected %q, but got %q", "de", data[:n])
+	}
+}
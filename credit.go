@@ -0,0 +1,146 @@
+package cancelreader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// creditReader limits how many bytes are read from the backend to the credit
+// granted by the consumer, see WithReadCredit.
+type creditReader struct {
+	CancelReader
+
+	lock     sync.Mutex
+	credit   int64
+	canceled bool
+	changed  chan struct{} // closed and replaced when credit or canceled change
+}
+
+func newCreditReader(cr CancelReader, credit int64) *creditReader {
+	return &creditReader{CancelReader: cr, credit: credit, changed: make(chan struct{})}
+}
+
+func (r *creditReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+// readContext waits for credit and reads at most that many bytes.
+func (r *creditReader) readContext(ctx context.Context, data []byte) (int, error) {
+	r.lock.Lock()
+	for r.credit <= 0 && !r.canceled {
+		changed := r.changed
+		r.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+
+		r.lock.Lock()
+	}
+
+	if r.canceled {
+		r.lock.Unlock()
+		return 0, ErrCanceled
+	}
+
+	if int64(len(data)) > r.credit {
+		data = data[:r.credit]
+	}
+	r.lock.Unlock()
+
+	n, err := readContext(ctx, r.CancelReader, data)
+
+	r.lock.Lock()
+	r.credit -= int64(n)
+	r.lock.Unlock()
+
+	return n, err
+}
+
+func (r *creditReader) poll(timeout time.Duration) (bool, error) {
+	r.lock.Lock()
+	canceled, credit := r.canceled, r.credit
+	r.lock.Unlock()
+
+	if canceled {
+		return false, ErrCanceled
+	}
+
+	if credit <= 0 {
+		return false, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
+
+// Cancel also interrupts a Read waiting for credit.
+func (r *creditReader) Cancel() bool {
+	r.lock.Lock()
+	waiting := r.credit <= 0 && !r.canceled
+	r.canceled = true
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.lock.Unlock()
+
+	return r.CancelReader.Cancel() || waiting
+}
+
+func (r *creditReader) reset() error {
+	rs, ok := r.CancelReader.(resetter)
+	if ok {
+		err := rs.reset()
+		if err != nil {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	r.canceled = false
+	r.lock.Unlock()
+
+	return nil
+}
+
+// setCredit sets the credit, or adds to it if add is set.
+func (r *creditReader) setCredit(n int64, add bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if add {
+		n += r.credit
+	}
+	r.credit = n
+
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// AddReadCredit allows a CancelReader created with WithReadCredit to read n
+// more bytes, e.g. when the remote end of a forwarded session adjusted its
+// window. It returns false for other readers.
+func AddReadCredit(r CancelReader, n int64) bool {
+	info, ok := infoOf(r)
+	if !ok || info.credit == nil {
+		return false
+	}
+
+	info.credit.setCredit(n, true)
+
+	return true
+}
+
+// SetReadLimit sets how many more bytes a CancelReader created with
+// WithReadCredit may read. It returns false for other readers.
+func SetReadLimit(r CancelReader, n int64) bool {
+	info, ok := infoOf(r)
+	if !ok || info.credit == nil {
+		return false
+	}
+
+	info.credit.setCredit(n, false)
+
+	return true
+}
@@ -0,0 +1,74 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadCredit(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := NewReader(pr, WithReadCredit(2))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	_, _ = pw.Write([]byte("abcd"))
+
+	p := make([]byte, 8)
+	n, err := cr.Read(p)
+	if err != nil || string(p[:n]) != "ab" {
+		t.Errorf("expected to read the credit of 2 bytes, but got %q, %v", p[:n], err)
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		n, _ := cr.Read(p)
+		done <- string(p[:n])
+	}()
+
+	select {
+	case s := <-done:
+		t.Fatalf("expected Read to block without credit, but got %q", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !AddReadCredit(cr, 1) {
+		t.Errorf("expected flow control to be enabled")
+	}
+	if s := <-done; s != "c" {
+		t.Errorf("expected %q after adding credit, but got %q", "c", s)
+	}
+
+	go func() {
+		_, err := cr.Read(p)
+		done <- err.Error()
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if !cr.Cancel() {
+		t.Errorf("expected Cancel to interrupt the Read waiting for credit")
+	}
+	if s := <-done; s != ErrCanceled.Error() {
+		t.Errorf("expected ErrCanceled, but got %s", s)
+	}
+
+	if SetReadLimit(Tee(newChanReader()), 1) {
+		t.Errorf("expected SetReadLimit to fail without flow control")
+	}
+
+	if _, err := cr.Read(p); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected the reader to stay canceled, but got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// deadliner is implemented by net.Conn and other readers with deadlines.
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// deadlineReader cancels Reads of a net.Conn or another reader with read
+// deadlines by setting the deadline to now, so connections that are no File,
+// like TLS or in-memory ones, don't need the fallback backend.
+type deadlineReader struct {
+	r io.Reader
+	d deadliner
+	cancelMixin
+
+	// peeked is the input read by poll and err the error it ended with,
+	// both returned by the next Read.
+	peeked []byte
+	err    error
+}
+
+// minPollTimeout is how long poll waits at least, since a read deadline that
+// already passed fails the read without checking for input.
+const minPollTimeout = time.Millisecond
+
+// connOf returns the connection behind reader and its read deadline if
+// reader is no File but has one, or was made a File by asFile. Files like
+// *os.File only use the deadline backend when it is selected, as most of
+// them, e.g. regular files, have no usable deadline.
+func connOf(reader io.Reader) (io.Reader, deadliner, bool) {
+	if cf, ok := reader.(*connFile); ok {
+		reader = cf.Reader
+	} else if _, ok := reader.(File); ok {
+		return nil, nil, false
+	}
+
+	d, ok := reader.(deadliner)
+
+	return reader, d, ok
+}
+
+// openDeadline returns a reader of the deadline backend for reader.
+func openDeadline(reader io.Reader) (CancelReader, error) {
+	if conn, d, ok := connOf(reader); ok {
+		return newDeadlineReader(conn, d), nil
+	}
+
+	if d, ok := reader.(deadliner); ok {
+		return newDeadlineReader(reader, d), nil
+	}
+
+	return nil, errUnsupportedInput(backendDeadline, reader)
+}
+
+func newDeadlineReader(reader io.Reader, d deadliner) *deadlineReader {
+	return &deadlineReader{r: reader, d: d}
+}
+
+func (r *deadlineReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *deadlineReader) readContext(ctx context.Context, data []byte) (int, error) {
+	if r.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	if len(r.peeked) > 0 {
+		n := copy(data, r.peeked)
+		r.peeked = r.peeked[n:]
+
+		return n, nil
+	}
+
+	if r.err != nil {
+		err := r.err
+		r.err = nil
+
+		return 0, err
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = r.d.SetReadDeadline(time.Now()) })
+	n, err := r.r.Read(data)
+	expired := !stop()
+
+	if isTimeout(err) && r.isCanceled() {
+		return n, ErrCanceled
+	}
+
+	if expired {
+		// clear the deadline of ctx unless Cancel set one in the meantime
+		_ = r.d.SetReadDeadline(time.Time{})
+		if r.isCanceled() {
+			_ = r.d.SetReadDeadline(time.Now())
+		}
+
+		if isTimeout(err) {
+			return n, ctx.Err()
+		}
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+// poll waits for input by reading a byte with a read deadline, which the
+// next Read returns.
+func (r *deadlineReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	if len(r.peeked) > 0 || r.err != nil {
+		return true, nil
+	}
+
+	if timeout < minPollTimeout {
+		timeout = minPollTimeout
+	}
+
+	err := r.d.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return false, err // nolint: wrapcheck
+	}
+
+	var b [1]byte
+	n, err := r.r.Read(b[:])
+
+	// clear the deadline unless Cancel set one in the meantime
+	_ = r.d.SetReadDeadline(time.Time{})
+	if r.isCanceled() {
+		_ = r.d.SetReadDeadline(time.Now())
+	}
+
+	r.peeked = append(r.peeked, b[:n]...)
+
+	switch {
+	case isTimeout(err) && r.isCanceled():
+		return n > 0, ErrCanceled
+	case isTimeout(err):
+		return n > 0, nil
+	case err != nil:
+		// a failing Read doesn't block either
+		r.err = err
+	}
+
+	return n > 0 || err != nil, nil
+}
+
+// isTimeout reports whether err was caused by a read deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var ne net.Error
+
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (r *deadlineReader) reset() error {
+	r.resetCanceled()
+
+	return r.d.SetReadDeadline(time.Time{}) // nolint: wrapcheck
+}
+
+func (r *deadlineReader) backendName() string {
+	return backendDeadline
+}
+
+func (r *deadlineReader) Cancel() bool {
+	r.setCanceled()
+
+	return r.d.SetReadDeadline(time.Now()) == nil
+}
+
+// Close does not close the connection, see WithOwnership.
+func (r *deadlineReader) Close() error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDeadlineReader(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	r, err := NewReader(local)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if Backend(r) != backendDeadline {
+		t.Errorf("expected the %s backend, but got %s", backendDeadline, Backend(r))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	data := make([]byte, 1)
+	if _, err := r.(ContextReader).ReadContext(ctx, data); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, but got %v", err)
+	}
+
+	if _, err := TryRead(r, data); !errors.Is(err, ErrWouldBlock) {
+		t.Errorf("expected ErrWouldBlock, but got %v", err)
+	}
+
+	go func() { _, _ = remote.Write([]byte("ab")) }()
+	if ready, err := Poll(r, time.Second); !ready || err != nil {
+		t.Errorf("expected input, but got %v, %v", ready, err)
+	}
+	for _, want := range []byte("ab") {
+		if _, err := r.Read(data); err != nil || data[0] != want {
+			t.Errorf("expected %q, but got %q, %v", want, data, err)
+		}
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		r.Cancel()
+	}()
+	if _, err := r.Read(data); err != ErrCanceled {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
+
+func TestTCPConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no loopback: %s", err)
+	}
+	defer ln.Close()
+
+	remote, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer remote.Close()
+
+	local, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer local.Close()
+
+	for _, opts := range [][]Option{nil, {WithBackend(backendDeadline)}} {
+		r, err := NewReader(local.(*net.TCPConn), opts...)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+
+		if !CanCancel(r) {
+			t.Errorf("expected a cancelable backend, but got %s", Backend(r))
+		}
+		if len(opts) > 0 && Backend(r) != backendDeadline {
+			t.Errorf("expected the %s backend, but got %s", backendDeadline, Backend(r))
+		}
+
+		if _, err := remote.Write([]byte("a")); err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		data := make([]byte, 1)
+		if _, err := r.Read(data); err != nil || data[0] != 'a' {
+			t.Errorf("expected %q, but got %q, %v", "a", data, err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			r.Cancel()
+		}()
+		if _, err := r.Read(data); !errors.Is(err, ErrCanceled) {
+			t.Errorf("%s: expected ErrCanceled, but got %v", Backend(r), err)
+		}
+
+		if err := r.Close(); err != nil {
+			t.Errorf("expected no error, but got %s", err)
+		}
+	}
+}
@@ -0,0 +1,657 @@
+package cancelreader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is an input event decoded by a Decoder. It is one of KeyEvent,
+// MouseEvent, ResizeEvent, UnknownEvent or HotkeyEvent.
+type Event interface {
+	fmt.Stringer
+	isEvent()
+}
+
+// Key identifies a key of a KeyEvent.
+type Key int
+
+// Keys reported by KeyEvent. KeyRune stands for all keys producing a
+// character.
+const (
+	KeyRune Key = iota
+	KeyEscape
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPgUp
+	KeyPgDown
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+)
+
+var keyNames = map[Key]string{
+	KeyRune:      "rune",
+	KeyEscape:    "esc",
+	KeyEnter:     "enter",
+	KeyTab:       "tab",
+	KeyBackspace: "backspace",
+	KeyUp:        "up",
+	KeyDown:      "down",
+	KeyRight:     "right",
+	KeyLeft:      "left",
+	KeyHome:      "home",
+	KeyEnd:       "end",
+	KeyInsert:    "insert",
+	KeyDelete:    "delete",
+	KeyPgUp:      "pgup",
+	KeyPgDown:    "pgdown",
+	KeyF1:        "f1",
+	KeyF2:        "f2",
+	KeyF3:        "f3",
+	KeyF4:        "f4",
+	KeyF5:        "f5",
+	KeyF6:        "f6",
+	KeyF7:        "f7",
+	KeyF8:        "f8",
+	KeyF9:        "f9",
+	KeyF10:       "f10",
+	KeyF11:       "f11",
+	KeyF12:       "f12",
+}
+
+func (k Key) String() string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("key(%d)", int(k))
+}
+
+// Modifiers is a set of modifier keys.
+type Modifiers uint8
+
+// Modifier keys.
+const (
+	ModShift Modifiers = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+func (m Modifiers) String() string {
+	var names []string
+
+	if m&ModCtrl != 0 {
+		names = append(names, "ctrl")
+	}
+
+	if m&ModAlt != 0 {
+		names = append(names, "alt")
+	}
+
+	if m&ModShift != 0 {
+		names = append(names, "shift")
+	}
+
+	return strings.Join(names, "+")
+}
+
+// KeyEvent is a key press. Rune is only set for KeyRune.
+//
+// Modifiers are reported the same way on every platform and keyboard layout:
+//   - Shift is folded into the Rune of KeyRune events, e.g. 'A' instead of
+//     shift+a, and only reported for other keys.
+//   - Alt is reported for keys prefixed with ESC, which is how terminals with
+//     meta-sends-escape and the Windows key translation send it, and with
+//     WithEightBitMeta for bytes with the high bit set.
+//   - AltGr produces the plain character of the layout without modifiers,
+//     while Ctrl+Alt+letter is reported as ctrl+alt+letter.
+//   - Ctrl+letter is reported as the lower-case letter with ModCtrl.
+type KeyEvent struct {
+	Key  Key
+	Rune rune
+	Mod  Modifiers
+}
+
+func (KeyEvent) isEvent() {}
+
+func (e KeyEvent) String() string {
+	name := e.Key.String()
+	if e.Key == KeyRune {
+		name = string(e.Rune)
+	}
+
+	if e.Mod != 0 {
+		return e.Mod.String() + "+" + name
+	}
+
+	return name
+}
+
+// MouseButton identifies the button of a MouseEvent.
+type MouseButton int
+
+// Mouse buttons. MouseNone is reported for motion without a pressed button.
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+var mouseButtonNames = map[MouseButton]string{
+	MouseLeft:       "left",
+	MouseMiddle:     "middle",
+	MouseRight:      "right",
+	MouseNone:       "none",
+	MouseWheelUp:    "wheelup",
+	MouseWheelDown:  "wheeldown",
+	MouseWheelLeft:  "wheelleft",
+	MouseWheelRight: "wheelright",
+}
+
+func (b MouseButton) String() string {
+	if name, ok := mouseButtonNames[b]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("button(%d)", int(b))
+}
+
+// MouseAction tells whether a MouseEvent is a press, release or motion.
+type MouseAction int
+
+// Mouse actions.
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+func (a MouseAction) String() string {
+	switch a {
+	case MousePress:
+		return "press"
+	case MouseRelease:
+		return "release"
+	case MouseMotion:
+		return "motion"
+	default:
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+}
+
+// MouseEvent is a SGR mouse report. X and Y are 1-based.
+type MouseEvent struct {
+	X, Y   int
+	Button MouseButton
+	Action MouseAction
+	Mod    Modifiers
+}
+
+func (MouseEvent) isEvent() {}
+
+func (e MouseEvent) String() string {
+	name := e.Button.String() + " " + e.Action.String()
+	if e.Mod != 0 {
+		name = e.Mod.String() + "+" + name
+	}
+
+	return fmt.Sprintf("%s at %d,%d", name, e.X, e.Y)
+}
+
+// ResizeEvent is a text area size report (ESC [ 8 ; rows ; columns t) as
+// sent by xterm on request and synthesized on Windows by
+// WithEventTranslation and for SSH sessions by NewChannelReader.
+type ResizeEvent struct {
+	Rows, Cols int
+}
+
+func (ResizeEvent) isEvent() {}
+
+func (e ResizeEvent) String() string {
+	return fmt.Sprintf("resize %dx%d", e.Cols, e.Rows)
+}
+
+// UnknownEvent is an escape sequence the Decoder does not understand.
+type UnknownEvent struct {
+	Sequence []byte
+}
+
+func (UnknownEvent) isEvent() {}
+
+func (e UnknownEvent) String() string {
+	return fmt.Sprintf("unknown %q", e.Sequence)
+}
+
+// Decoder decodes the byte stream of a terminal into events. Escape sequences
+// are expected to arrive in a single Read, so a lone ESC at the end of the
+// data read so far is reported as the Escape key, unless WithEscTimeout is
+// used.
+type Decoder struct {
+	r   io.Reader
+	buf []byte
+	err error
+
+	escTimeout   time.Duration
+	eightBitMeta bool
+
+	// see WithHotkeys
+	hotkeys *Hotkeys
+	held    []KeyEvent // keys that may start a sequence
+	queue   []Event
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithEscTimeout makes the Decoder wait up to timeout for the rest of an
+// escape sequence after a lone ESC, ESC [ or ESC O at the end of the data
+// read so far, before reporting it as a key. This disambiguates the Escape
+// key from sequences split across reads, e.g. over slow connections. The
+// timeout is implemented with the wait mechanism of the backend, so the
+// reader must be a CancelReader returned by NewReader or NewChannelReader;
+// other readers are not waited for.
+func WithEscTimeout(timeout time.Duration) DecoderOption {
+	return func(d *Decoder) {
+		d.escTimeout = timeout
+	}
+}
+
+// WithEightBitMeta makes the Decoder report bytes with the high bit set as
+// the key of the lower seven bits with ModAlt, for terminals that send Alt by
+// setting the eighth bit instead of an ESC prefix, e.g. xterm with
+// eightBitInput. It disables the decoding of UTF-8.
+func WithEightBitMeta() DecoderOption {
+	return func(d *Decoder) {
+		d.eightBitMeta = true
+	}
+}
+
+// NewDecoder returns a Decoder reading from r, which is usually a
+// CancelReader.
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{r: r}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// ReadEvent returns the next event. Errors of the underlying reader, e.g.
+// ErrCanceled, are returned once all events decoded so far were returned.
+func (d *Decoder) ReadEvent() (Event, error) {
+	return d.ReadEventContext(context.Background())
+}
+
+// ReadEventContext returns the next event like ReadEvent, but reads with
+// ctx if the reader implements ContextReader, like those returned by
+// NewReader. Events decoded before ctx was done are not lost.
+func (d *Decoder) ReadEventContext(ctx context.Context) (Event, error) {
+	if d.hotkeys != nil {
+		return d.readHotkeys(ctx)
+	}
+
+	ev, _, err := d.readRaw(ctx)
+	return ev, err
+}
+
+// Cancel cancels the pending Read of the reader if it is a CancelReader and
+// returns true if it succeeded.
+func (d *Decoder) Cancel() bool {
+	return cancelInner(d.r)
+}
+
+// readRaw returns the next event and the bytes it was decoded from.
+func (d *Decoder) readRaw(ctx context.Context) (Event, []byte, error) {
+	for {
+		if len(d.buf) > 0 && !d.awaitEscape() {
+			ev, n := d.decode()
+			if n > 0 {
+				raw := d.buf[:n:n]
+				d.buf = d.buf[n:]
+
+				return ev, raw, nil
+			}
+		}
+
+		if d.err != nil {
+			err := d.err
+			d.err = nil
+
+			return nil, nil, err
+		}
+
+		var buf [256]byte
+
+		n, err := readContext(ctx, d.r, buf[:])
+		d.buf = append(d.buf, buf[:n]...)
+		d.err = err
+	}
+}
+
+// decode decodes the event at the start of the buffer and returns how many
+// bytes it consumed.
+func (d *Decoder) decode() (Event, int) {
+	if d.eightBitMeta && d.buf[0] >= 0x80 {
+		ev, _ := decodeEvent([]byte{d.buf[0] &^ 0x80}, true)
+		if key, ok := ev.(KeyEvent); ok {
+			key.Mod |= ModAlt
+			return key, 1
+		}
+	}
+
+	return decodeEvent(d.buf, d.err != nil)
+}
+
+// awaitEscape reports whether the buffer ends with the start of an escape
+// sequence and its continuation arrived within the escape timeout.
+func (d *Decoder) awaitEscape() bool {
+	p, ok := d.r.(poller)
+	if !ok || d.escTimeout <= 0 || d.err != nil || !ambiguousEscape(d.buf) {
+		return false
+	}
+
+	ready, err := p.poll(d.escTimeout)
+	if err != nil {
+		d.err = err
+	}
+
+	return ready
+}
+
+// ambiguousEscape reports whether b is a lone ESC, ESC [ or ESC O, which are
+// keys on their own or the start of a sequence.
+func ambiguousEscape(b []byte) bool {
+	switch {
+	case len(b) == 1:
+		return b[0] == 0x1b
+	case len(b) == 2:
+		return b[0] == 0x1b && (b[1] == '[' || b[1] == 'O')
+	}
+
+	return false
+}
+
+// decodeEvent decodes the event at the start of b and returns how many bytes
+// it consumed. It returns 0 if b only holds the start of an event, unless
+// final is set.
+func decodeEvent(b []byte, final bool) (Event, int) {
+	if b[0] == 0x1b {
+		return decodeEscape(b, final)
+	}
+
+	if b[0] < 0x20 || b[0] == 0x7f {
+		return controlKey(b[0]), 1
+	}
+
+	if !utf8.FullRune(b) && !final {
+		return nil, 0
+	}
+
+	r, n := utf8.DecodeRune(b)
+
+	return KeyEvent{Key: KeyRune, Rune: r}, n
+}
+
+func controlKey(c byte) KeyEvent {
+	switch c {
+	case '\r', '\n':
+		return KeyEvent{Key: KeyEnter}
+	case '\t':
+		return KeyEvent{Key: KeyTab}
+	case 0x7f, 0x08:
+		return KeyEvent{Key: KeyBackspace}
+	case 0x1b:
+		return KeyEvent{Key: KeyEscape}
+	case 0x00:
+		return KeyEvent{Key: KeyRune, Rune: ' ', Mod: ModCtrl}
+	case 0x1c, 0x1d, 0x1e, 0x1f:
+		// Ctrl+\ to Ctrl+_ follow Ctrl+Z like \ to _ follow Z
+		return KeyEvent{Key: KeyRune, Rune: rune(c) + '@', Mod: ModCtrl}
+	default:
+		// Ctrl+A is 0x01 and so on
+		return KeyEvent{Key: KeyRune, Rune: rune(c) + 'a' - 1, Mod: ModCtrl}
+	}
+}
+
+func decodeEscape(b []byte, final bool) (Event, int) {
+	if len(b) == 1 {
+		return KeyEvent{Key: KeyEscape}, 1
+	}
+
+	switch b[1] {
+	case '[':
+		return decodeCSI(b, final)
+	case 'O':
+		if len(b) < 3 {
+			return KeyEvent{Key: KeyRune, Rune: 'O', Mod: ModAlt}, 2
+		}
+
+		if key, ok := ss3Finals[b[2]]; ok {
+			return KeyEvent{Key: key}, 3
+		}
+
+		return UnknownEvent{Sequence: b[:3]}, 3
+	case 0x1b:
+		// the first ESC can't start a sequence
+		return KeyEvent{Key: KeyEscape}, 1
+	}
+
+	ev, n := decodeEvent(b[1:], final)
+	if n == 0 {
+		return nil, 0
+	}
+
+	if key, ok := ev.(KeyEvent); ok {
+		key.Mod |= ModAlt
+		return key, n + 1
+	}
+
+	return KeyEvent{Key: KeyEscape}, 1
+}
+
+var ss3Finals = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+var csiFinals = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+var csiTildes = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPgUp,
+	6:  KeyPgDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+func decodeCSI(b []byte, final bool) (Event, int) {
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+
+	switch {
+	case end < len(b):
+	case len(b) == 2:
+		return KeyEvent{Key: KeyRune, Rune: '[', Mod: ModAlt}, 2
+	case final:
+		return UnknownEvent{Sequence: b}, len(b)
+	default:
+		// the rest of a long sequence is still to be read
+		return nil, 0
+	}
+
+	seq := b[:end+1]
+	params := string(b[2:end])
+	unknown := UnknownEvent{Sequence: seq}
+
+	if strings.HasPrefix(params, "<") {
+		ev, ok := decodeSGRMouse(params[1:], b[end])
+		if !ok {
+			return unknown, len(seq)
+		}
+
+		return ev, len(seq)
+	}
+
+	nums, ok := csiParams(params)
+	if !ok {
+		return unknown, len(seq)
+	}
+
+	switch c := b[end]; {
+	case c == 'Z':
+		return KeyEvent{Key: KeyTab, Mod: ModShift}, len(seq)
+	case c == '~' && len(nums) > 0:
+		key, ok := csiTildes[nums[0]]
+		if !ok {
+			return unknown, len(seq)
+		}
+
+		return KeyEvent{Key: key, Mod: modifiers(nums)}, len(seq)
+	case c == 't' && len(nums) == 3 && nums[0] == 8:
+		return ResizeEvent{Rows: nums[1], Cols: nums[2]}, len(seq)
+	default:
+		key, ok := csiFinals[c]
+		if !ok {
+			return unknown, len(seq)
+		}
+
+		return KeyEvent{Key: key, Mod: modifiers(nums)}, len(seq)
+	}
+}
+
+// modifiers decodes the xterm modifier parameter, which is the second
+// parameter of a sequence like ESC [ 1 ; 5 A.
+func modifiers(nums []int) Modifiers {
+	if len(nums) < 2 || nums[1] < 2 {
+		return 0
+	}
+
+	return Modifiers(nums[1]-1) & (ModShift | ModAlt | ModCtrl)
+}
+
+func csiParams(params string) ([]int, bool) {
+	if params == "" {
+		return nil, true
+	}
+
+	fields := strings.Split(params, ";")
+	nums := make([]int, len(fields))
+
+	for i, f := range fields {
+		if f == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, false
+		}
+
+		nums[i] = n
+	}
+
+	return nums, true
+}
+
+func decodeSGRMouse(params string, final byte) (MouseEvent, bool) {
+	nums, ok := csiParams(params)
+	if !ok || len(nums) != 3 || (final != 'M' && final != 'm') {
+		return MouseEvent{}, false
+	}
+
+	code := nums[0]
+	ev := MouseEvent{X: nums[1], Y: nums[2]}
+
+	if code&4 != 0 {
+		ev.Mod |= ModShift
+	}
+
+	if code&8 != 0 {
+		ev.Mod |= ModAlt
+	}
+
+	if code&16 != 0 {
+		ev.Mod |= ModCtrl
+	}
+
+	switch {
+	case code&64 != 0:
+		ev.Button = MouseWheelUp + MouseButton(code&3)
+	case code&32 != 0:
+		ev.Button = MouseButton(code & 3)
+		ev.Action = MouseMotion
+	default:
+		ev.Button = MouseButton(code & 3)
+	}
+
+	if final == 'm' {
+		ev.Action = MouseRelease
+	}
+
+	return ev, true
+}
@@ -0,0 +1,143 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Event
+	}{
+		{"aä", []Event{KeyEvent{Key: KeyRune, Rune: 'a'}, KeyEvent{Key: KeyRune, Rune: 'ä'}}},
+		{"\r\t\x7f\x03", []Event{
+			KeyEvent{Key: KeyEnter}, KeyEvent{Key: KeyTab}, KeyEvent{Key: KeyBackspace},
+			KeyEvent{Key: KeyRune, Rune: 'c', Mod: ModCtrl},
+		}},
+		{"\x1b", []Event{KeyEvent{Key: KeyEscape}}},
+		{"\x1bx", []Event{KeyEvent{Key: KeyRune, Rune: 'x', Mod: ModAlt}}},
+		{"\x1b[A\x1b[1;5C\x1bOP", []Event{
+			KeyEvent{Key: KeyUp}, KeyEvent{Key: KeyRight, Mod: ModCtrl}, KeyEvent{Key: KeyF1},
+		}},
+		{"\x1b[3;2~\x1b[Z", []Event{KeyEvent{Key: KeyDelete, Mod: ModShift}, KeyEvent{Key: KeyTab, Mod: ModShift}}},
+		{"\x1b[<0;5;10M\x1b[<0;5;10m\x1b[<65;1;2M", []Event{
+			MouseEvent{X: 5, Y: 10, Button: MouseLeft},
+			MouseEvent{X: 5, Y: 10, Button: MouseLeft, Action: MouseRelease},
+			MouseEvent{X: 1, Y: 2, Button: MouseWheelDown},
+		}},
+		{"\x1b[8;24;80t", []Event{ResizeEvent{Rows: 24, Cols: 80}}},
+		{"\x1b[?1;2c", []Event{UnknownEvent{Sequence: []byte("\x1b[?1;2c")}}},
+	}
+
+	for _, test := range tests {
+		d := NewDecoder(strings.NewReader(test.input))
+
+		var events []Event
+		for {
+			ev, err := d.ReadEvent()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: expected no error, but got %s", test.input, err)
+			}
+			events = append(events, ev)
+		}
+
+		if !reflect.DeepEqual(events, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, events)
+		}
+	}
+}
+
+// chunkReader returns one chunk per Read.
+type chunkReader []string
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, (*r)[0])
+	*r = (*r)[1:]
+
+	return n, nil
+}
+
+func TestDecoderSplitReads(t *testing.T) {
+	d := NewDecoder(&chunkReader{"\xc3", "\xa4\x1b[1", ";5A"})
+
+	for _, expected := range []Event{KeyEvent{Key: KeyRune, Rune: 'ä'}, KeyEvent{Key: KeyUp, Mod: ModCtrl}} {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ev != expected {
+			t.Errorf("expected %v, got %v", expected, ev)
+		}
+	}
+}
+
+// pollingChunkReader is a chunkReader whose poll reports whether chunks are
+// left.
+type pollingChunkReader struct {
+	chunkReader
+}
+
+func (r *pollingChunkReader) poll(time.Duration) (bool, error) {
+	return len(r.chunkReader) > 0, nil
+}
+
+func TestDecoderEscTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		opts     []DecoderOption
+		expected Event
+	}{
+		{nil, KeyEvent{Key: KeyEscape}},
+		{[]DecoderOption{WithEscTimeout(10 * time.Millisecond)}, KeyEvent{Key: KeyUp}},
+	} {
+		d := NewDecoder(&pollingChunkReader{chunkReader{"\x1b", "[A"}}, tc.opts...)
+
+		ev, err := d.ReadEvent()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ev != tc.expected {
+			t.Errorf("expected %v, got %v", tc.expected, ev)
+		}
+	}
+}
+
+func TestDecoderModifiers(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		opts     []DecoderOption
+		expected Event
+	}{
+		{"A", nil, KeyEvent{Key: KeyRune, Rune: 'A'}},
+		{"\x1bx", nil, KeyEvent{Key: KeyRune, Rune: 'x', Mod: ModAlt}},
+		{"\x1b\x01", nil, KeyEvent{Key: KeyRune, Rune: 'a', Mod: ModCtrl | ModAlt}},
+		{"\x1a", nil, KeyEvent{Key: KeyRune, Rune: 'z', Mod: ModCtrl}},
+		{"\x1c", nil, KeyEvent{Key: KeyRune, Rune: '\\', Mod: ModCtrl}},
+		{"\x1d", nil, KeyEvent{Key: KeyRune, Rune: ']', Mod: ModCtrl}},
+		{"\x1e", nil, KeyEvent{Key: KeyRune, Rune: '^', Mod: ModCtrl}},
+		{"\x1f", nil, KeyEvent{Key: KeyRune, Rune: '_', Mod: ModCtrl}},
+		{"\xf8", []DecoderOption{WithEightBitMeta()}, KeyEvent{Key: KeyRune, Rune: 'x', Mod: ModAlt}},
+		{"\x81", []DecoderOption{WithEightBitMeta()}, KeyEvent{Key: KeyRune, Rune: 'a', Mod: ModCtrl | ModAlt}},
+	} {
+		d := NewDecoder(strings.NewReader(tc.input), tc.opts...)
+
+		ev, err := d.ReadEvent()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ev != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.input, tc.expected, ev)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Attachment reads from a terminal in raw mode and can let go of it for a
+// while, like detaching from a tmux session. Combined with a Filter, an ssh
+// wrapper can detach on a hotkey and reattach later, e.g. on SIGCONT.
+type Attachment struct {
+	s       *StdinReader
+	makeRaw func(fd uintptr) (func() error, error)
+
+	lock    sync.Mutex
+	restore func() error
+	owner   *StdinRef // holds the reader while detached
+}
+
+// Attach puts the terminal behind file into raw mode and returns an
+// Attachment reading from it with readers created with opts.
+func Attach(file File, opts ...Option) (*Attachment, error) {
+	a := &Attachment{s: &StdinReader{file: file, opts: opts}, makeRaw: makeRaw}
+
+	err := a.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Read reads from the terminal. While detached, Read blocks until Reattach
+// or Close without returning ErrCanceled.
+func (a *Attachment) Read(data []byte) (int, error) {
+	return a.s.Read(data)
+}
+
+// ReadContext implements ContextReader. ctx also ends the wait for
+// Reattach.
+func (a *Attachment) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return a.s.ReadContext(ctx, data)
+}
+
+// Cancel cancels the pending Read and returns true if it succeeded.
+func (a *Attachment) Cancel() bool {
+	return a.s.Cancel()
+}
+
+// Detach interrupts the pending Read, stops reading from the terminal and
+// restores its previous mode, so that a shell or another program can use it.
+// Detaching twice does nothing.
+func (a *Attachment) Detach() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.owner != nil {
+		return nil
+	}
+
+	owner := &StdinRef{r: a.s}
+	_ = a.s.borrow(context.Background(), owner)
+	a.owner = owner
+
+	// readers are recreated on Reattach to pick up the input from there
+	_ = a.s.Close()
+
+	return a.release()
+}
+
+// Reattach puts the terminal back into raw mode and resumes the Reads
+// blocked by Detach. Reattaching an attached terminal does nothing.
+func (a *Attachment) Reattach() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.owner == nil {
+		return nil
+	}
+
+	err := a.acquire()
+	if err != nil {
+		return err
+	}
+
+	a.s.giveBack(a.owner)
+	a.owner = nil
+
+	return nil
+}
+
+// Detached reports whether the terminal is detached.
+func (a *Attachment) Detached() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.owner != nil
+}
+
+// Close restores the terminal and releases the reader. The pending Read is
+// canceled and Reads blocked by Detach fail with os.ErrClosed.
+func (a *Attachment) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	err := a.release()
+	cerr := a.s.shutdown()
+	if a.owner != nil {
+		a.s.giveBack(a.owner)
+		a.owner = nil
+	}
+
+	return errors.Join(err, cerr)
+}
+
+// Fd returns the file descriptor of the terminal.
+func (a *Attachment) Fd() uintptr {
+	return a.s.Fd()
+}
+
+func (a *Attachment) acquire() error {
+	restore, err := a.makeRaw(a.s.Fd())
+	if err != nil {
+		return err
+	}
+
+	a.restore = restore
+
+	return nil
+}
+
+func (a *Attachment) release() error {
+	if a.restore == nil {
+		return nil
+	}
+
+	err := a.restore()
+	a.restore = nil
+
+	return err // nolint: wrapcheck
+}
@@ -0,0 +1,74 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAttachmentDetach(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	raw := 0
+	a := &Attachment{s: &StdinReader{file: pr}, makeRaw: func(uintptr) (func() error, error) {
+		raw++
+		return func() error { raw--; return nil }, nil
+	}}
+	if err := a.acquire(); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	read := make(chan error, 1)
+	var buf [1]byte
+	go func() {
+		_, err := a.Read(buf[:])
+		read <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := a.Detach(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if !a.Detached() || raw != 0 {
+		t.Errorf("expected the terminal to be restored")
+	}
+
+	select {
+	case err := <-read:
+		t.Errorf("expected Read to block while detached, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := a.Reattach(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if a.Detached() || raw != 1 {
+		t.Errorf("expected the terminal to be in raw mode again")
+	}
+
+	_, _ = pw.Write([]byte("a"))
+	if err := <-read; err != nil || buf[0] != 'a' {
+		t.Errorf("expected to read the input after reattaching, but got %q, %v", buf[0], err)
+	}
+
+	go func() {
+		_, err := a.Read(buf[:])
+		read <- err
+	}()
+	_ = a.Detach()
+	if err := a.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if err := <-read; !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected a detached Read to fail on Close, but got %v", err)
+	}
+}
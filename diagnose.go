@@ -0,0 +1,122 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Report describes the environment of an error of this package, see
+// Diagnose.
+type Report struct {
+	Error string
+
+	// Backend, Syscall and Fd are set for a SyscallError.
+	Backend string
+	Syscall string
+	Fd      uintptr
+
+	OS       string // GOOS/GOARCH and the kernel or Windows version
+	Go       string
+	Backends []string
+
+	// Stdin, Stdout and Stderr are the kinds of the standard streams.
+	Stdin, Stdout, Stderr Kind
+
+	// Console describes the console modes on Windows and is empty elsewhere.
+	Console string
+
+	Env          []string // terminal related environment variables
+	ScreenReader bool
+	Handles      int
+
+	// Health lists the most recent Health events of the process.
+	Health []HealthEvent
+}
+
+// HealthEvent is a Health event with the time it occurred.
+type HealthEvent struct {
+	Time   time.Time
+	Health Health
+}
+
+// diagnoseEnv are the environment variables telling which terminal or
+// multiplexer the process runs in.
+var diagnoseEnv = []string{
+	"TERM", "TERM_PROGRAM", "COLORTERM", "WT_SESSION", "ConEmuPID", "MSYSTEM", "TMUX", "STY", "SSH_TTY",
+}
+
+// Diagnose returns a report about err and the environment it occurred in,
+// suitable for pasting into a bug report, e.g. when Cancel returned false or
+// NewReader failed. err may be nil.
+func Diagnose(err error) Report {
+	r := Report{
+		OS:       runtime.GOOS + "/" + runtime.GOARCH,
+		Go:       runtime.Version(),
+		Backends: Backends(),
+		Stdin:    inputKind(os.Stdin),
+		Stdout:   inputKind(os.Stdout),
+		Stderr:   inputKind(os.Stderr),
+		Console:  consoleReport(),
+
+		ScreenReader: ScreenReaderActive(),
+		Health:       recentHealth(),
+	}
+	r.Handles, _ = Handles()
+
+	if v := osVersion(); v != "" {
+		r.OS += " " + v
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	var serr *SyscallError
+	if errors.As(err, &serr) {
+		r.Backend, r.Syscall, r.Fd = serr.Backend, serr.Op, serr.Fd
+	}
+
+	for _, name := range diagnoseEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			r.Env = append(r.Env, name+"="+v)
+		}
+	}
+
+	return r
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+
+	line := func(name, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%-9s "+format+"\n", append([]interface{}{name + ":"}, args...)...)
+	}
+
+	if r.Error != "" {
+		line("error", "%s", r.Error)
+	}
+	if r.Syscall != "" {
+		line("syscall", "%s backend, %s on %d", r.Backend, r.Syscall, r.Fd)
+	}
+	line("os", "%s", r.OS)
+	line("go", "%s", r.Go)
+	line("backends", "%s", strings.Join(r.Backends, ", "))
+	line("streams", "stdin=%s stdout=%s stderr=%s", r.Stdin, r.Stdout, r.Stderr)
+	if r.Console != "" {
+		line("console", "%s", r.Console)
+	}
+	for _, env := range r.Env {
+		line("env", "%s", env)
+	}
+	line("a11y", "screen reader=%v", r.ScreenReader)
+	line("handles", "%d", r.Handles)
+	for _, h := range r.Health {
+		line("health", "%s %s", h.Time.Format(time.RFC3339), h.Health)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	errno := errors.New("bad file descriptor")
+	err := fmt.Errorf("reading: %w", syscallError(backendSelect, "select", 7, errno))
+
+	notifyResumed(HealthReconnected)
+
+	r := Diagnose(err)
+	if r.Backend != backendSelect || r.Syscall != "select" || r.Fd != 7 {
+		t.Errorf("expected the system call of the error, but got %s backend, %s on %d", r.Backend, r.Syscall, r.Fd)
+	}
+	if len(r.Health) == 0 || r.Health[len(r.Health)-1].Health != HealthReconnected {
+		t.Errorf("expected the recent health events, but got %v", r.Health)
+	}
+
+	report := r.String()
+	for _, want := range []string{"error:", "syscall:", "os:", "backends:", "streams:", "health:"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected the report to contain %q, but got\n%s", want, report)
+		}
+	}
+
+	if r := Diagnose(nil); r.Error != "" || r.Syscall != "" {
+		t.Errorf("expected no error details without an error, but got %+v", r)
+	}
+}
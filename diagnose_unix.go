@@ -0,0 +1,22 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+// osVersion returns the name and release of the kernel.
+func osVersion() string {
+	var uts unix.Utsname
+
+	err := unix.Uname(&uts)
+	if err != nil {
+		return ""
+	}
+
+	return unix.ByteSliceToString(uts.Sysname[:]) + " " + unix.ByteSliceToString(uts.Release[:])
+}
+
+func consoleReport() string {
+	return ""
+}
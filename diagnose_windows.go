@@ -0,0 +1,28 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// osVersion returns the version of Windows, which unlike GetVersion is not
+// affected by the compatibility manifest.
+func osVersion() string {
+	v := windows.RtlGetVersion()
+
+	return fmt.Sprintf("Windows %d.%d.%d", v.MajorVersion, v.MinorVersion, v.BuildNumber)
+}
+
+// consoleReport returns the console modes and whether winpty is in use.
+func consoleReport() string {
+	modes, err := GetConsoleModes()
+	if err != nil {
+		return fmt.Sprintf("%v winpty=%v", err, IsWinpty())
+	}
+
+	return fmt.Sprintf("%v winpty=%v", modes, IsWinpty())
+}
@@ -0,0 +1,38 @@
+package cancelreader
+
+// Done returns a channel that is closed once r is canceled or closed, so that
+// a supervisor can select on it instead of waiting for a Read to fail. After
+// Reset, Done returns a new channel. Canceling a single Read with a
+// CancelToken does not close it. For readers not returned by NewReader, Done
+// returns nil, which is never closed.
+func Done(r CancelReader) <-chan struct{} {
+	info, ok := infoOf(r)
+	if !ok {
+		return nil
+	}
+
+	info.lock.Lock()
+	defer info.lock.Unlock()
+
+	if info.done == nil {
+		info.done = make(chan struct{})
+		if info.canceled || info.closed {
+			close(info.done)
+		}
+	}
+
+	return info.done
+}
+
+// markDone closes the channel returned by Done. r.lock must be held.
+func (r *infoReader) markDone() {
+	if r.done == nil {
+		r.done = make(chan struct{})
+	}
+
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+}
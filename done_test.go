@@ -0,0 +1,44 @@
+package cancelreader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDone(t *testing.T) {
+	r, err := NewReader(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	done := Done(r)
+	select {
+	case <-done:
+		t.Errorf("expected Done to be open before Cancel")
+	default:
+	}
+
+	r.Cancel()
+	select {
+	case <-done:
+	default:
+		t.Errorf("expected Done to be closed after Cancel")
+	}
+
+	if err := Reset(r); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	select {
+	case <-Done(r):
+		t.Errorf("expected Done to be open after Reset")
+	default:
+	}
+
+	_ = r.Close()
+	select {
+	case <-Done(r):
+	default:
+		t.Errorf("expected Done to be closed after Close")
+	}
+}
@@ -0,0 +1,75 @@
+package cancelreader
+
+import "errors"
+
+// CancelReadWriter reads from and writes to a pty, serial port or socket
+// with Reads and Writes that can be canceled independently. It combines the
+// reader of NewReader and the writer of NewWriter, which don't share a
+// poller: each direction waits with an epoll or kqueue instance of its own,
+// so the file is registered with each instance only once.
+type CancelReadWriter struct {
+	r CancelReader
+	w CancelWriter
+}
+
+// NewReadWriter returns a CancelReadWriter for file. opts apply to the
+// reader, see NewReader.
+func NewReadWriter(file File, opts ...Option) (*CancelReadWriter, error) {
+	r, err := NewReader(file, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(file)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return &CancelReadWriter{r: r, w: w}, nil
+}
+
+// Read reads from the file like the CancelReader returned by Reader.
+func (rw *CancelReadWriter) Read(data []byte) (int, error) {
+	return rw.r.Read(data)
+}
+
+// Write writes to the file like the CancelWriter returned by Writer.
+func (rw *CancelReadWriter) Write(data []byte) (int, error) {
+	return rw.w.Write(data)
+}
+
+// CancelRead cancels ongoing and future Reads and returns true if it
+// succeeded. Writes are not affected.
+func (rw *CancelReadWriter) CancelRead() bool {
+	return rw.r.Cancel()
+}
+
+// CancelWrite cancels ongoing and future Writes and returns true if it
+// succeeded. Reads are not affected.
+func (rw *CancelReadWriter) CancelWrite() bool {
+	return rw.w.Cancel()
+}
+
+// Cancel cancels Reads and Writes and returns true if both succeeded.
+func (rw *CancelReadWriter) Cancel() bool {
+	read := rw.CancelRead()
+	write := rw.CancelWrite()
+
+	return read && write
+}
+
+// Reader returns the reader, e.g. for Backend, Poll or Reset.
+func (rw *CancelReadWriter) Reader() CancelReader {
+	return rw.r
+}
+
+// Writer returns the writer.
+func (rw *CancelReadWriter) Writer() CancelWriter {
+	return rw.w
+}
+
+// Close releases the reader and writer, but does not close the file.
+func (rw *CancelReadWriter) Close() error {
+	return errors.Join(rw.r.Close(), rw.w.Close())
+}
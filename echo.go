@@ -0,0 +1,165 @@
+package cancelreader
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// echoWindow is how long echoed input waits to be echoed by the remote side.
+const echoWindow = 2 * time.Second
+
+// Echo writes the input it is given back to a terminal, as local echo for
+// remote sessions with high latency. Use Sink to attach it with Tee, so a
+// slow terminal doesn't hold up reading. Characters are echoed as typed,
+// Enter as a new line and Backspace erases the last character; other keys
+// are not echoed.
+//
+// Once the remote side echoes the input itself, which Echo notices in the
+// output passed through Output, local echo is suppressed until it is enabled
+// again.
+type Echo struct {
+	w      io.Writer
+	masked func() bool
+
+	lock       sync.Mutex
+	enabled    bool
+	suppressed bool
+	buf        []byte // start of an incomplete sequence
+	pending    string // echoed and not seen in the output yet
+	echoed     time.Time
+}
+
+// EchoOption configures an Echo.
+type EchoOption func(*Echo)
+
+// WithEchoMask echoes every character typed while masked returns true as an
+// asterisk, e.g. for passwords. See TerminalEcho.
+func WithEchoMask(masked func() bool) EchoOption {
+	return func(e *Echo) {
+		e.masked = masked
+	}
+}
+
+// NewEcho returns an enabled Echo writing to w.
+func NewEcho(w io.Writer, opts ...EchoOption) *Echo {
+	e := &Echo{w: w, enabled: true}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Sink returns a Sink for Tee that buffers input while the terminal is busy.
+func (e *Echo) Sink() Sink {
+	return Sink{W: e, Policy: PolicyBuffer, Buffer: 64}
+}
+
+// SetEnabled turns local echo on or off. Turning it on ends the suppression
+// because of remote echo.
+func (e *Echo) SetEnabled(enabled bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.enabled = enabled
+	e.suppressed = false
+	e.pending = ""
+}
+
+// Enabled reports whether input is echoed, i.e. local echo is enabled and
+// not suppressed.
+func (e *Echo) Enabled() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.enabled && !e.suppressed
+}
+
+// Write echoes the input in p. Sequences split across writes are joined.
+func (e *Echo) Write(p []byte) (int, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.buf = append(e.buf, p...)
+
+	var out strings.Builder
+
+	masked := e.masked != nil && e.masked()
+
+	for len(e.buf) > 0 && !ambiguousEscape(e.buf) {
+		ev, n := decodeEvent(e.buf, false)
+		if n == 0 {
+			break
+		}
+		e.buf = e.buf[n:]
+
+		key, ok := ev.(KeyEvent)
+		switch {
+		case !ok || !e.enabled || e.suppressed:
+		case key == KeyEvent{Key: KeyEnter}:
+			out.WriteString("\r\n")
+			e.pending = ""
+		case key == KeyEvent{Key: KeyBackspace}:
+			out.WriteString("\b \b")
+		case key.Key == KeyRune && key.Mod == 0 && masked:
+			out.WriteByte('*')
+		case key.Key == KeyRune && key.Mod == 0:
+			out.WriteRune(key.Rune)
+			e.pending += string(key.Rune)
+		}
+	}
+
+	if len(e.buf) == 0 {
+		e.buf = nil
+	}
+
+	if out.Len() == 0 {
+		return len(p), nil
+	}
+
+	e.echoed = time.Now()
+
+	_, err := io.WriteString(e.w, out.String())
+
+	return len(p), err // nolint: wrapcheck
+}
+
+// Output returns a writer passing the output of the remote side to w, which
+// suppresses local echo once the remote side echoes the input.
+func (e *Echo) Output(w io.Writer) io.Writer {
+	return &echoOutput{e: e, w: w}
+}
+
+type echoOutput struct {
+	e *Echo
+	w io.Writer
+}
+
+func (o *echoOutput) Write(p []byte) (int, error) {
+	o.e.observe(p)
+
+	return o.w.Write(p) // nolint: wrapcheck
+}
+
+// observe suppresses local echo if p starts with the input echoed lately.
+func (e *Echo) observe(p []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.pending == "" || len(p) == 0 {
+		return
+	}
+
+	if time.Since(e.echoed) > echoWindow {
+		e.pending = ""
+		return
+	}
+
+	s := string(p)
+	if strings.HasPrefix(s, e.pending) || strings.HasPrefix(e.pending, s) {
+		e.suppressed = true
+		e.pending = ""
+	}
+}
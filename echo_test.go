@@ -0,0 +1,44 @@
+package cancelreader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEcho(t *testing.T) {
+	var term bytes.Buffer
+
+	masked := false
+	e := NewEcho(&term, WithEchoMask(func() bool { return masked }))
+
+	for _, chunk := range []string{"ls\x7f\x1b[", "A", "s\r"} {
+		if _, err := e.Write([]byte(chunk)); err != nil {
+			t.Errorf("expected no error, but got %s", err)
+		}
+	}
+
+	masked = true
+	_, _ = e.Write([]byte("pw\r"))
+
+	if expected := "ls\b \bs\r\n**\r\n"; term.String() != expected {
+		t.Errorf("expected %q, but got %q", expected, term.String())
+	}
+
+	// the remote side starts echoing
+	var remote bytes.Buffer
+	masked = false
+	term.Reset()
+
+	_, _ = e.Write([]byte("ab"))
+	_, _ = e.Output(&remote).Write([]byte("a"))
+	_, _ = e.Write([]byte("c"))
+
+	if e.Enabled() || term.String() != "ab" || remote.String() != "a" {
+		t.Errorf("expected local echo to be suppressed, but got %v, %q and %q", e.Enabled(), term.String(), remote.String())
+	}
+
+	e.SetEnabled(true)
+	if !e.Enabled() {
+		t.Errorf("expected local echo to be enabled")
+	}
+}
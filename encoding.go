@@ -0,0 +1,197 @@
+package cancelreader
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the encoding of an input detected by WithBOMDetection.
+type Encoding int
+
+// Encodings reported by DetectedEncoding.
+const (
+	// EncodingUnknown means detection is not enabled or no input was read
+	// yet.
+	EncodingUnknown Encoding = iota
+
+	// EncodingDefault means the input has no byte order mark and is passed
+	// through unchanged.
+	EncodingDefault
+	EncodingUTF8
+	EncodingUTF16LE
+	EncodingUTF16BE
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingDefault:
+		return "default"
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	default:
+		return "unknown"
+	}
+}
+
+var boms = []struct {
+	mark     string
+	encoding Encoding
+}{
+	{"\xef\xbb\xbf", EncodingUTF8},
+	{"\xff\xfe", EncodingUTF16LE},
+	{"\xfe\xff", EncodingUTF16BE},
+}
+
+// DetectedEncoding returns the encoding detected for a CancelReader created
+// with WithBOMDetection. It is EncodingUnknown until the first Read returned.
+func DetectedEncoding(r CancelReader) Encoding {
+	if info, ok := infoOf(r); ok && info.bom != nil {
+		return info.bom.encoding()
+	}
+
+	return EncodingUnknown
+}
+
+// bomReader removes a byte order mark from the input and transcodes UTF-16
+// to UTF-8.
+type bomReader struct {
+	CancelReader
+
+	lock sync.Mutex
+	enc  Encoding
+
+	raw  []byte // input not decoded yet
+	out  []byte // decoded input not returned yet
+	high rune   // pending high surrogate
+	err  error
+}
+
+func newBOMReader(cr CancelReader) *bomReader {
+	return &bomReader{CancelReader: cr}
+}
+
+func (r *bomReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *bomReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			err := r.err
+			r.err = nil
+
+			return 0, err
+		}
+
+		buf := make([]byte, len(data)+4)
+		n, err := readContext(ctx, r.CancelReader, buf)
+		r.raw = append(r.raw, buf[:n]...)
+		r.err = err
+		r.decode(err != nil)
+	}
+
+	n := copy(data, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *bomReader) poll(timeout time.Duration) (bool, error) {
+	if len(r.out) > 0 {
+		return true, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
+
+func (r *bomReader) encoding() Encoding {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.enc
+}
+
+// decode moves the raw input to out. Incomplete input is kept unless final is
+// set.
+func (r *bomReader) decode(final bool) {
+	enc := r.encoding()
+	if enc == EncodingUnknown {
+		enc = r.detect(final)
+		if enc == EncodingUnknown {
+			return
+		}
+
+		r.lock.Lock()
+		r.enc = enc
+		r.lock.Unlock()
+	}
+
+	if enc != EncodingUTF16LE && enc != EncodingUTF16BE {
+		r.out = append(r.out, r.raw...)
+		r.raw = nil
+
+		return
+	}
+
+	for len(r.raw) >= 2 {
+		u := rune(r.raw[0]) | rune(r.raw[1])<<8
+		if enc == EncodingUTF16BE {
+			u = rune(r.raw[0])<<8 | rune(r.raw[1])
+		}
+		r.raw = r.raw[2:]
+
+		if r.high != 0 {
+			high := r.high
+			r.high = 0
+
+			if utf16.IsSurrogate(u) && u >= 0xdc00 {
+				r.out = utf8.AppendRune(r.out, utf16.DecodeRune(high, u))
+				continue
+			}
+
+			// the high surrogate is not followed by a low one
+			r.out = utf8.AppendRune(r.out, utf8.RuneError)
+		}
+
+		if utf16.IsSurrogate(u) && u < 0xdc00 {
+			r.high = u
+			continue
+		}
+
+		// lone low surrogates are encoded as utf8.RuneError
+		r.out = utf8.AppendRune(r.out, u)
+	}
+
+	if final && (r.high != 0 || len(r.raw) > 0) {
+		r.out = utf8.AppendRune(r.out, utf8.RuneError)
+		r.high, r.raw = 0, nil
+	}
+}
+
+// detect returns the encoding given by the byte order mark at the start of
+// the raw input and removes the mark. It returns EncodingUnknown if the input
+// might still turn out to be a mark, unless final is set.
+func (r *bomReader) detect(final bool) Encoding {
+	for _, bom := range boms {
+		if bytes.HasPrefix(r.raw, []byte(bom.mark)) {
+			r.raw = r.raw[len(bom.mark):]
+			return bom.encoding
+		}
+	}
+
+	for _, bom := range boms {
+		if !final && bytes.HasPrefix([]byte(bom.mark), r.raw) {
+			return EncodingUnknown
+		}
+	}
+
+	return EncodingDefault
+}
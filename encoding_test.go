@@ -0,0 +1,39 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBOMDetection(t *testing.T) {
+	for _, tc := range []struct {
+		chunks   chunkReader
+		expected string
+		encoding Encoding
+	}{
+		{chunkReader{"h\xc3\xa9"}, "h\xc3\xa9", EncodingDefault},
+		{chunkReader{"\xef", "\xbb\xbfh\xc3\xa9"}, "h\xc3\xa9", EncodingUTF8},
+		{chunkReader{"\xef\xbbx"}, "\xef\xbbx", EncodingDefault},
+		{chunkReader{"\xff\xfeh", "\x00\xe9\x00\x3d", "\xd8\x00\xde"}, "h\xc3\xa9\xf0\x9f\x98\x80", EncodingUTF16LE},
+		{chunkReader{"\xfe\xff\x00h\xd8"}, "h\xef\xbf\xbd", EncodingUTF16BE},
+	} {
+		cr, _ := newFallbackCancelReader(&tc.chunks)
+		r := &infoReader{CancelReader: cr, clock: systemClock{}}
+		newConfig([]Option{WithBOMDetection()}).wrap(r)
+
+		if e := DetectedEncoding(r); e != EncodingUnknown {
+			t.Errorf("expected no encoding before reading, but got %s", e)
+		}
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if string(out) != tc.expected {
+			t.Errorf("expected %q, but got %q", tc.expected, out)
+		}
+		if e := DetectedEncoding(r); e != tc.encoding {
+			t.Errorf("expected encoding %s, but got %s", tc.encoding, e)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package cancelreader
+
+import "fmt"
+
+// SyscallError is a failed system call of a backend. Err is usually an errno
+// or a Windows error code, so callers can branch on it with errors.Is.
+type SyscallError struct {
+	Backend string
+	Op      string  // the system call, e.g. "epoll_ctl"
+	Fd      uintptr // the file descriptor or handle the call was made for
+	Err     error
+}
+
+func (e *SyscallError) Error() string {
+	return fmt.Sprintf("%s: %s on %d: %v", e.Backend, e.Op, e.Fd, e.Err)
+}
+
+func (e *SyscallError) Unwrap() error {
+	return e.Err
+}
+
+// syscallError returns a SyscallError or nil if err is nil.
+func syscallError(backend, op string, fd uintptr, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &SyscallError{Backend: backend, Op: op, Fd: fd, Err: err}
+}
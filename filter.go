@@ -0,0 +1,117 @@
+package cancelreader
+
+import (
+	"context"
+	"io"
+)
+
+// Filter forwards the input of a reader byte for byte, except for key
+// sequences starting with a hotkey, which are handled locally like screen's
+// Ctrl+A or the ~ escape of ssh. This way proxies and nested TUIs can pass
+// everything else verbatim to a remote end.
+type Filter struct {
+	d      *Decoder
+	hotkey KeyEvent
+	handle func(KeyEvent)
+
+	lineStart   bool // only recognize the hotkey at the start of a line
+	atLineStart bool
+	pending     []byte // raw bytes of a hotkey waiting for the next key
+
+	out []byte
+	err error
+}
+
+// FilterOption configures a Filter.
+type FilterOption func(*Filter)
+
+// WithLineStart makes the Filter only recognize the hotkey at the start of
+// the input or after Enter, like the escape character of ssh.
+func WithLineStart() FilterOption {
+	return func(f *Filter) {
+		f.lineStart = true
+	}
+}
+
+// NewFilter returns a Filter reading from r. The key following the hotkey is
+// passed to handle and not forwarded. Pressing the hotkey twice forwards it
+// once.
+func NewFilter(r io.Reader, hotkey KeyEvent, handle func(KeyEvent), opts ...FilterOption) *Filter {
+	f := &Filter{d: NewDecoder(r), hotkey: hotkey, handle: handle, atLineStart: true}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+func (f *Filter) Read(data []byte) (int, error) {
+	return f.ReadContext(context.Background(), data)
+}
+
+// ReadContext reads like Read but passes ctx to the reader, see
+// Decoder.ReadEventContext.
+func (f *Filter) ReadContext(ctx context.Context, data []byte) (int, error) {
+	for len(f.out) == 0 {
+		if f.err != nil {
+			err := f.err
+			f.err = nil
+
+			return 0, err
+		}
+
+		ev, raw, err := f.d.readRaw(ctx)
+		if contextDone(err) {
+			// keep a pending hotkey for the next Read
+			return 0, err
+		}
+
+		if err != nil {
+			// forward a hotkey that was not followed by a key
+			f.out = append(f.out, f.pending...)
+			f.pending = nil
+			f.err = err
+
+			continue
+		}
+
+		f.filter(ev, raw)
+	}
+
+	n := copy(data, f.out)
+	f.out = f.out[n:]
+
+	return n, nil
+}
+
+// Cancel cancels the pending Read of the reader if it is a CancelReader and
+// returns true if it succeeded.
+func (f *Filter) Cancel() bool {
+	return f.d.Cancel()
+}
+
+// filter forwards or intercepts a decoded event.
+func (f *Filter) filter(ev Event, raw []byte) {
+	key, isKey := ev.(KeyEvent)
+
+	switch {
+	case f.pending != nil && isKey && key == f.hotkey:
+		// the hotkey twice forwards it once
+		f.forward(key, f.pending)
+		f.pending = nil
+	case f.pending != nil:
+		f.pending = nil
+		if isKey {
+			f.handle(key)
+		}
+	case isKey && key == f.hotkey && (!f.lineStart || f.atLineStart):
+		f.pending = raw
+	default:
+		f.forward(key, raw)
+	}
+}
+
+func (f *Filter) forward(key KeyEvent, raw []byte) {
+	f.out = append(f.out, raw...)
+	f.atLineStart = key.Key == KeyEnter
+}
@@ -0,0 +1,38 @@
+package cancelreader
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	ctrlA := KeyEvent{Key: KeyRune, Rune: 'a', Mod: ModCtrl}
+	tilde := KeyEvent{Key: KeyRune, Rune: '~'}
+
+	for _, tc := range []struct {
+		input    string
+		hotkey   KeyEvent
+		opts     []FilterOption
+		expected string
+		handled  string
+	}{
+		{"ab\x1b[A\x01dc\x01\x01", ctrlA, nil, "ab\x1b[Ac\x01", "d"},
+		{"a~.\r~.", tilde, []FilterOption{WithLineStart()}, "a~.\r", "."},
+		{"x\x01", ctrlA, nil, "x\x01", ""},
+	} {
+		var handled []rune
+
+		f := NewFilter(strings.NewReader(tc.input), tc.hotkey, func(key KeyEvent) {
+			handled = append(handled, key.Rune)
+		}, tc.opts...)
+
+		out, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if string(out) != tc.expected || string(handled) != tc.handled {
+			t.Errorf("%q: expected %q and %q handled, got %q and %q", tc.input, tc.expected, tc.handled, out, string(handled))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FixtureEnv names the environment variable holding the path of a trace that
+// OpenStdin replays instead of reading os.Stdin under go test.
+const FixtureEnv = "CANCELREADER_FIXTURE"
+
+// OpenStdin returns a CancelReader for os.Stdin. When running under go test
+// without a terminal on stdin and FixtureEnv names a trace file, it returns
+// NewFixtureReader for the trace instead, so interactive tools can run their
+// integration tests unattended. Traces are written by Record or by hand.
+func OpenStdin(opts ...Option) (CancelReader, error) {
+	path := os.Getenv(FixtureEnv)
+	if path == "" || !underGoTest() || fileKind(os.Stdin) == KindTerminal {
+		return NewReader(os.Stdin, opts...)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open fixture: %w", err)
+	}
+
+	r, err := NewFixtureReader(f, 1, opts...)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// underGoTest reports whether the process is a test binary built by go test.
+func underGoTest() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+
+	return strings.HasSuffix(name, ".test")
+}
+
+// NewFixtureReader returns a CancelReader that reads the data of a trace
+// written by Record with its timing scaled by speed, see Replay. The data is
+// fed through a pipe to a reader created by NewReader with opts, so Cancel
+// takes the same path as with real input, except on Windows, where pipes
+// use the fallback backend. Reads return io.EOF at the end of the trace and
+// Close returns errors of parsing the trace. If trace is an io.Closer, Close
+// closes it.
+func NewFixtureReader(trace io.Reader, speed float64, opts ...Option) (CancelReader, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create fixture pipe: %w", err)
+	}
+
+	cr, err := NewReader(pr, opts...)
+	if err != nil {
+		_ = pr.Close()
+		_ = pw.Close()
+
+		return nil, err
+	}
+
+	r := &fixtureReader{CancelReader: cr, pipe: pr, trace: trace}
+
+	go func() {
+		err := Replay(pw, trace, speed)
+
+		r.lock.Lock()
+		if !r.closed {
+			r.err = err
+		}
+		r.lock.Unlock()
+
+		_ = pw.Close()
+	}()
+
+	return r, nil
+}
+
+type fixtureReader struct {
+	CancelReader
+	pipe  *os.File
+	trace io.Reader
+
+	lock   sync.Mutex
+	err    error
+	closed bool // writing to the pipe fails afterwards
+}
+
+func (r *fixtureReader) unwrap() CancelReader {
+	return r.CancelReader
+}
+
+// Close closes the reader and the pipe, which stops the replay.
+func (r *fixtureReader) Close() error {
+	r.lock.Lock()
+	r.closed = true
+	r.lock.Unlock()
+
+	err := r.CancelReader.Close()
+	_ = r.pipe.Close()
+
+	var closeErr error
+	if c, ok := r.trace.(io.Closer); ok {
+		closeErr = c.Close()
+	}
+
+	r.lock.Lock()
+	replayErr := r.err
+	r.lock.Unlock()
+
+	return errors.Join(err, replayErr, closeErr)
+}
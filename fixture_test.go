@@ -0,0 +1,72 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixtureReader(t *testing.T) {
+	cr, err := NewFixtureReader(strings.NewReader("0 \"ab\"\n500000 \"c\"\n"), 1)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	p := make([]byte, 8)
+	n, err := cr.Read(p)
+	if err != nil || string(p[:n]) != "ab" {
+		t.Errorf("expected %q, but got %q, %v", "ab", p[:n], err)
+	}
+
+	// the second chunk is due later, so the Read blocks and can be canceled
+	time.AfterFunc(20*time.Millisecond, func() { cr.Cancel() })
+	if _, err := cr.Read(p); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	cr, err = NewFixtureReader(strings.NewReader("0 \"x\"\nbroken\n"), 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	data, err := ioutil.ReadAll(cr)
+	if err != nil || string(data) != "x" {
+		t.Errorf("expected %q until EOF, but got %q, %v", "x", data, err)
+	}
+	if err := cr.Close(); err == nil || !strings.Contains(err.Error(), "trace line 2") {
+		t.Errorf("expected the trace error on Close, but got %v", err)
+	}
+}
+
+func TestOpenStdin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture")
+	if err := ioutil.WriteFile(path, []byte("0 \"y\\r\"\n"), 0o600); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	os.Setenv(FixtureEnv, path)
+	defer os.Unsetenv(FixtureEnv)
+
+	if fileKind(os.Stdin) == KindTerminal {
+		t.Skip("stdin is a terminal")
+	}
+
+	cr, err := OpenStdin()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	data, err := ioutil.ReadAll(cr)
+	if err != nil || string(data) != "y\r" {
+		t.Errorf("expected the fixture, but got %q, %v", data, err)
+	}
+}
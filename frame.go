@@ -0,0 +1,199 @@
+package cancelreader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrFrameTooLarge is returned by FrameReader for frames larger than the
+// limit set with WithMaxFrameSize.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// defaultMaxFrameSize is the frame size limit of a FrameReader.
+const defaultMaxFrameSize = 1 << 20
+
+// FrameReader reads messages from a CancelReader framed by a split function,
+// e.g. for a control channel multiplexed over stdin. Split functions have
+// the signature of bufio.SplitFunc; DelimitedFrames, Netstrings,
+// UvarintFrames and LSPFrames cover common protocols.
+type FrameReader struct {
+	r     io.Reader
+	split bufio.SplitFunc
+	max   int
+
+	buf []byte
+	err error
+}
+
+// FrameOption configures a FrameReader.
+type FrameOption func(*FrameReader)
+
+// WithMaxFrameSize limits the size of frames, including their length
+// prefix or headers, to n bytes instead of 1 MiB.
+func WithMaxFrameSize(n int) FrameOption {
+	return func(f *FrameReader) {
+		f.max = n
+	}
+}
+
+// NewFrameReader returns a FrameReader reading from r, which is usually a
+// CancelReader.
+func NewFrameReader(r io.Reader, split bufio.SplitFunc, opts ...FrameOption) *FrameReader {
+	f := &FrameReader{r: r, split: split, max: defaultMaxFrameSize}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// ReadFrame returns the next complete frame. If the reader fails, e.g. with
+// ErrCanceled, the data of the incomplete frame read so far is returned with
+// the error. The FrameReader keeps that data, so a later ReadFrame continues
+// the frame if the reader is usable again, e.g. after ReadContext timed out.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	return f.ReadFrameContext(context.Background())
+}
+
+// ReadFrameContext reads like ReadFrame but passes ctx to the reader if it
+// implements ContextReader.
+func (f *FrameReader) ReadFrameContext(ctx context.Context) ([]byte, error) {
+	for {
+		advance, frame, err := f.split(f.buf, errors.Is(f.err, io.EOF))
+		if err != nil {
+			return nil, err
+		}
+
+		if advance > 0 || frame != nil {
+			frame = append([]byte(nil), frame...)
+			f.buf = f.buf[advance:]
+
+			return frame, nil
+		}
+
+		if f.err != nil {
+			err := f.err
+			if !errors.Is(err, io.EOF) {
+				// the reader may be used again, e.g. after a reset
+				f.err = nil
+			}
+
+			if len(f.buf) > 0 && errors.Is(err, io.EOF) {
+				return append([]byte(nil), f.buf...), io.ErrUnexpectedEOF
+			}
+
+			return append([]byte(nil), f.buf...), err
+		}
+
+		if len(f.buf) >= f.max {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrFrameTooLarge, f.max)
+		}
+
+		var chunk [4096]byte
+
+		// frames completed by the data read with an error are returned first
+		n, err := readContext(ctx, f.r, chunk[:])
+		f.buf = append(f.buf, chunk[:n]...)
+		f.err = err
+	}
+}
+
+// DelimitedFrames returns a split function for frames ending with delim,
+// which is not part of the frame.
+func DelimitedFrames(delim byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+// Netstrings splits netstrings like "5:hello,".
+func Netstrings(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		if len(data) > 10 {
+			return 0, nil, errors.New("netstring: missing length")
+		}
+
+		return 0, nil, nil
+	}
+
+	n, err := strconv.Atoi(string(data[:i]))
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("netstring: invalid length %q", data[:i])
+	}
+
+	end := i + 1 + n
+	if len(data) <= end {
+		return 0, nil, nil
+	}
+
+	if data[end] != ',' {
+		return 0, nil, errors.New("netstring: missing trailing comma")
+	}
+
+	return end + 1, data[i+1 : end], nil
+}
+
+// UvarintFrames splits frames prefixed with their length as an unsigned
+// varint, like those written with binary.PutUvarint.
+func UvarintFrames(data []byte, atEOF bool) (int, []byte, error) {
+	n, size := binary.Uvarint(data)
+	switch {
+	case size == 0:
+		return 0, nil, nil
+	case size < 0:
+		return 0, nil, errors.New("uvarint frame: length overflows 64 bits")
+	}
+
+	if uint64(len(data)-size) < n {
+		return 0, nil, nil
+	}
+
+	end := size + int(n)
+
+	return end, data[size:end], nil
+}
+
+// LSPFrames splits messages with headers terminated by an empty line and a
+// Content-Length header, like those of the Language Server Protocol. The
+// frame is the content without the headers.
+func LSPFrames(data []byte, atEOF bool) (int, []byte, error) {
+	i := bytes.Index(data, []byte("\r\n\r\n"))
+	if i < 0 {
+		return 0, nil, nil
+	}
+
+	length := -1
+	for _, line := range strings.Split(string(data[:i]), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return 0, nil, fmt.Errorf("lsp frame: invalid Content-Length %q", value)
+			}
+			length = n
+		}
+	}
+
+	if length < 0 {
+		return 0, nil, errors.New("lsp frame: missing Content-Length")
+	}
+
+	start := i + 4
+	if len(data)-start < length {
+		return 0, nil, nil
+	}
+
+	return start + length, data[start : start+length], nil
+}
@@ -0,0 +1,63 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFrameReader(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		split    func([]byte, bool) (int, []byte, error)
+		chunks   []string
+		expected []string
+	}{
+		{"delimited", DelimitedFrames('\n'), []string{"a\nb", "c\n"}, []string{"a", "bc"}},
+		{"netstring", Netstrings, []string{"5:he", "llo,0:,"}, []string{"hello", ""}},
+		{"uvarint", UvarintFrames, []string{"\x02h", "i\x01!"}, []string{"hi", "!"}},
+		{"lsp", LSPFrames, []string{"Content-Length: 2\r\n", "\r\n{}Content-Length: 1\r\n\r\n1"}, []string{"{}", "1"}},
+	} {
+		f := NewFrameReader(&chunkReader{tc.chunks[0], tc.chunks[1]}, tc.split)
+
+		for _, expected := range tc.expected {
+			frame, err := f.ReadFrame()
+			if err != nil {
+				t.Fatalf("%s: expected no error, but got %s", tc.name, err)
+			}
+			if string(frame) != expected {
+				t.Errorf("%s: expected frame %q, but got %q", tc.name, expected, frame)
+			}
+		}
+
+		if _, err := f.ReadFrame(); !errors.Is(err, io.EOF) {
+			t.Errorf("%s: expected EOF, but got %v", tc.name, err)
+		}
+	}
+}
+
+func TestFrameReaderCanceled(t *testing.T) {
+	cr := newChanReader("5:he")
+	f := NewFrameReader(cr, Netstrings)
+
+	time.AfterFunc(20*time.Millisecond, func() { cr.Cancel() })
+
+	frame, err := f.ReadFrame()
+	if !errors.Is(err, ErrCanceled) || string(frame) != "5:he" {
+		t.Errorf("expected the partial frame with ErrCanceled, but got %q, %v", frame, err)
+	}
+
+	f = NewFrameReader(&chunkReader{"1:a,4:b"}, Netstrings)
+	if frame, _ := f.ReadFrame(); string(frame) != "a" {
+		t.Errorf("expected frame %q, but got %q", "a", frame)
+	}
+	if frame, err := f.ReadFrame(); !errors.Is(err, io.ErrUnexpectedEOF) || string(frame) != "4:b" {
+		t.Errorf("expected the truncated frame with ErrUnexpectedEOF, but got %q, %v", frame, err)
+	}
+
+	f = NewFrameReader(&chunkReader{"123456"}, DelimitedFrames('\n'), WithMaxFrameSize(4))
+	if _, err := f.ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("expected ErrFrameTooLarge, but got %v", err)
+	}
+}
@@ -1,11 +1,9 @@
 module github.com/abakum/cancelreader
 
-go 1.17
-
-require golang.org/x/sys v0.6.0
+go 1.21
 
 require (
-	github.com/containerd/console v1.0.4 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/xlab/closer v1.1.0 // indirect
+	github.com/containerd/console v1.0.4
+	github.com/mattn/go-isatty v0.0.20
+	golang.org/x/sys v0.6.0
 )
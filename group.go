@@ -0,0 +1,76 @@
+package cancelreader
+
+import "sync"
+
+// CancelGroup cancels many readers at once, e.g. the sessions of a server
+// or the panes of a terminal UI on shutdown.
+type CancelGroup struct {
+	lock     sync.Mutex
+	readers  []CancelReader
+	canceled bool
+}
+
+// NewCancelGroup returns an empty CancelGroup.
+func NewCancelGroup() *CancelGroup {
+	return &CancelGroup{}
+}
+
+// Add adds r to the group. If the group was already canceled, r is canceled
+// right away.
+func (g *CancelGroup) Add(r CancelReader) {
+	g.lock.Lock()
+	if !g.canceled {
+		g.readers = append(g.readers, r)
+		g.lock.Unlock()
+
+		return
+	}
+	g.lock.Unlock()
+
+	r.Cancel()
+}
+
+// Remove removes r from the group, e.g. after it was closed.
+func (g *CancelGroup) Remove(r CancelReader) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for i, cr := range g.readers {
+		if cr == r {
+			g.readers = append(g.readers[:i], g.readers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Cancel cancels all readers of the group concurrently and returns those
+// whose pending Read could not be interrupted, see CancelReader.Cancel.
+// Readers added later are canceled when they are added.
+func (g *CancelGroup) Cancel() []CancelReader {
+	g.lock.Lock()
+	readers := g.readers
+	g.readers = nil
+	g.canceled = true
+	g.lock.Unlock()
+
+	graceful := make([]bool, len(readers))
+
+	var wg sync.WaitGroup
+	for i, r := range readers {
+		wg.Add(1)
+		go func(i int, r CancelReader) {
+			defer wg.Done()
+			graceful[i] = r.Cancel()
+		}(i, r)
+	}
+	wg.Wait()
+
+	var failed []CancelReader
+	for i, r := range readers {
+		if !graceful[i] {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,45 @@
+package cancelreader
+
+import (
+	"strings"
+	"testing"
+)
+
+func recvX() ([]byte, error) {
+	return []byte("x"), nil
+}
+
+func TestCancelGroup(t *testing.T) {
+	g := NewCancelGroup()
+
+	streams := make([]CancelReader, 3)
+	for i := range streams {
+		streams[i] = NewStreamReader(recvX, func() {})
+		g.Add(streams[i])
+	}
+	g.Remove(streams[2])
+
+	fallback, err := NewReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	g.Add(fallback)
+
+	failed := g.Cancel()
+	if len(failed) != 1 || failed[0] != fallback {
+		t.Errorf("expected only the fallback reader to fail, but got %v", failed)
+	}
+
+	if _, err := streams[0].Read(make([]byte, 1)); err != ErrCanceled {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+	if _, err := streams[2].Read(make([]byte, 1)); err != nil {
+		t.Errorf("expected the removed reader not to be canceled, but got %s", err)
+	}
+
+	late := NewStreamReader(recvX, func() {})
+	g.Add(late)
+	if _, err := late.Read(make([]byte, 1)); err != ErrCanceled {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
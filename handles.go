@@ -0,0 +1,122 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrHandleLimit is returned by NewReader if a cancelable reader would
+// exceed the limit set with SetHandleLimit.
+var ErrHandleLimit = errors.New("handle limit reached")
+
+// handleCosts are the file descriptors or handles each backend keeps open:
+// the poller and the cancel pipe, or CONIN$ and the cancel event.
+var handleCosts = map[string]int{
+	backendEpoll:    3,
+	backendKqueue:   3,
+	backendSelect:   2,
+	backendConsole:  2,
+	backendFallback: 0,
+	backendBridge:   2,
+	backendPoll:     2,
+	backendNetpoll:  1,
+	backendShared:   0,
+	backendDeadline: 0,
+}
+
+var handleBudget struct {
+	lock  sync.Mutex
+	inUse int
+	limit int
+}
+
+// SetHandleLimit caps the file descriptors or handles used by all readers
+// of the package, e.g. for servers running thousands of readers with a low
+// fd limit. Once a reader would exceed the limit, NewReader picks a backend
+// without a poller of its own, like select instead of epoll, and fails with
+// ErrHandleLimit if even that does not fit. A limit of 0 removes the limit.
+// Readers created before are not affected.
+func SetHandleLimit(n int) {
+	handleBudget.lock.Lock()
+	defer handleBudget.lock.Unlock()
+
+	handleBudget.limit = n
+}
+
+// Handles returns the file descriptors or handles used by the open readers
+// of the package and the limit set with SetHandleLimit.
+func Handles() (inUse, limit int) {
+	handleBudget.lock.Lock()
+	defer handleBudget.lock.Unlock()
+
+	return handleBudget.inUse, handleBudget.limit
+}
+
+// reserveHandles accounts for n handles and reports whether they fit into
+// the limit.
+func reserveHandles(n int) bool {
+	handleBudget.lock.Lock()
+	defer handleBudget.lock.Unlock()
+
+	if handleBudget.limit > 0 && n > 0 && handleBudget.inUse+n > handleBudget.limit {
+		return false
+	}
+	handleBudget.inUse += n
+
+	return true
+}
+
+func releaseHandles(n int) {
+	handleBudget.lock.Lock()
+	defer handleBudget.lock.Unlock()
+
+	handleBudget.inUse -= n
+}
+
+// withinBudget accounts for the handles of cr, which was created for reader
+// with cfg. If they don't fit, cr is replaced by a reader of a cheaper
+// backend unless the backend was chosen with WithBackend. It returns the
+// reader and its number of handles.
+func withinBudget(reader io.Reader, cfg *config, cr CancelReader) (CancelReader, int, error) {
+	name := backendOf(cr)
+	cost := handleCosts[name]
+	if reserveHandles(cost) {
+		return cr, cost, nil
+	}
+	_ = cr.Close()
+
+	if cfg.backend == "" {
+		for _, alt := range backends {
+			// the experimental netpoll backend is only used on request
+			if alt == backendFallback || alt == backendNetpoll || handleCosts[alt] >= cost {
+				continue
+			}
+
+			c := *cfg
+			c.backend = alt
+
+			cr, err := openReader(reader, &c)
+			if err != nil {
+				continue
+			}
+
+			if reserveHandles(handleCosts[alt]) {
+				return cr, handleCosts[alt], nil
+			}
+			_ = cr.Close()
+		}
+	}
+
+	return nil, 0, fmt.Errorf("%s backend: %w", name, ErrHandleLimit)
+}
+
+// backendOf returns the name of the backend of cr.
+func backendOf(cr CancelReader) string {
+	if b, ok := cr.(interface{ backendName() string }); ok {
+		return b.backendName()
+	}
+
+	return ""
+}
@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestHandleLimit(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	inUse, _ := Handles()
+	defer SetHandleLimit(0)
+	SetHandleLimit(inUse + handleCosts[backends[0]] + handleCosts[backendSelect])
+
+	first, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer first.Close()
+
+	if stats, _ := Stats(first); stats.Handles != handleCosts[backends[0]] {
+		t.Errorf("expected %d handles, but got %d", handleCosts[backends[0]], stats.Handles)
+	}
+
+	second, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer second.Close()
+
+	if Backend(second) != backendSelect {
+		t.Errorf("expected the select backend near the limit, but got %s", Backend(second))
+	}
+
+	if _, err := NewReader(pr); !errors.Is(err, ErrHandleLimit) {
+		t.Errorf("expected ErrHandleLimit, but got %v", err)
+	}
+
+	if _, err := NewReader(pr, WithBackend(backendFallback)); err != nil {
+		t.Errorf("expected the fallback backend to need no handles, but got %s", err)
+	}
+
+	_ = second.Close()
+	_ = second.Close()
+	if now, _ := Handles(); now != inUse+handleCosts[backends[0]] {
+		t.Errorf("expected Close to release the handles once, but %d are in use", now)
+	}
+}
@@ -0,0 +1,101 @@
+package cancelreader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// handoffState is the state sent along with the input by Export.
+type handoffState struct {
+	Name    string  `json:"name"`
+	Handle  uintptr `json:"handle,omitempty"` // on Windows
+	Pending []byte  `json:"pending,omitempty"`
+}
+
+// handoff cancels r, a reader returned by NewReader, and returns its input
+// and the state to hand over to another process.
+func handoff(r CancelReader) (File, handoffState, error) {
+	info, ok := infoOf(r)
+	if !ok {
+		return nil, handoffState{}, fmt.Errorf("%T was not returned by NewReader", r)
+	}
+
+	if info.input == nil {
+		return nil, handoffState{}, fmt.Errorf("input of %s backend is not a file", info.backend)
+	}
+
+	canceled := r.Cancel()
+
+	state := handoffState{Name: info.input.Name()}
+	if info.readAhead != nil {
+		if !canceled {
+			return nil, handoffState{}, fmt.Errorf("%s backend can't stop reading ahead", info.backend)
+		}
+
+		state.Pending = info.readAhead.buffered()
+	}
+
+	return info.input, state, nil
+}
+
+func (s handoffState) marshal() ([]byte, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("marshal handoff state: %w", err)
+	}
+
+	return data, nil
+}
+
+func unmarshalHandoff(data []byte) (handoffState, error) {
+	var s handoffState
+
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return s, fmt.Errorf("unmarshal handoff state: %w", err)
+	}
+
+	return s, nil
+}
+
+// withPending makes the reader return data before reading the input.
+func withPending(data []byte) Option {
+	return func(c *config) {
+		c.pending = data
+	}
+}
+
+// pendingReader returns the input handed over by Export first.
+type pendingReader struct {
+	CancelReader
+	pending []byte
+}
+
+func (r *pendingReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *pendingReader) readContext(ctx context.Context, data []byte) (int, error) {
+	if len(r.pending) == 0 {
+		return readContext(ctx, r.CancelReader, data)
+	}
+
+	n := copy(data, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+func (r *pendingReader) poll(timeout time.Duration) (bool, error) {
+	if len(r.pending) > 0 {
+		return true, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
+
+func (r *pendingReader) unwrap() CancelReader {
+	return r.CancelReader
+}
@@ -0,0 +1,88 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Export hands the input of r, a CancelReader returned by NewReader, over to
+// another process, e.g. the new version of a self-upgrading daemon, which
+// receives it with Import. The file descriptor is sent over conn along with
+// the input read ahead with WithReadAhead, so no typed-ahead input gets
+// lost. Export cancels r, which must not be read from afterwards; closing r
+// does not affect the imported input.
+func Export(r CancelReader, conn *net.UnixConn) error {
+	file, state, err := handoff(r)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	data, err := state.marshal()
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	msg := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(msg, uint32(len(data)))
+	msg = append(msg, data...)
+
+	_, _, err = conn.WriteMsgUnix(msg, unix.UnixRights(int(file.Fd())), nil)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	return nil
+}
+
+// Import receives an input sent by Export and returns a CancelReader for it
+// created with opts, which returns the input read ahead by the exporting
+// process first.
+func Import(conn *net.UnixConn, opts ...Option) (CancelReader, error) {
+	var size [4]byte
+
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(size[:], oob)
+	if err == nil && n < len(size) {
+		_, err = io.ReadFull(conn, size[n:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err = io.ReadFull(conn, data); err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	state, err := unmarshalHandoff(data)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	for _, msg := range msgs {
+		fds, err := unix.ParseUnixRights(&msg)
+		if err != nil || len(fds) != 1 {
+			continue
+		}
+
+		file := os.NewFile(uintptr(fds[0]), state.Name)
+
+		return NewReader(file, append(opts, withPending(state.Pending))...)
+	}
+
+	return nil, fmt.Errorf("import: no file descriptor received")
+}
@@ -0,0 +1,73 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestExportImport(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socket")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer c.Close()
+		conns[i] = c.(*net.UnixConn)
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	old, err := NewReader(pr, WithReadAhead(16))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if _, err = pw.Write([]byte("typed ahead")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err = Export(old, conns[0]); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	old.Close()
+
+	r, err := Import(conns[1])
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if _, err = pw.Write([]byte(" and new")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	data := make([]byte, len("typed ahead and new"))
+	if _, err = io.ReadFull(r, data); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data) != "typed ahead and new" {
+		t.Errorf("expected %q, but got %q", "typed ahead and new", data)
+	}
+}
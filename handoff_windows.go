@@ -0,0 +1,73 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Export hands the input of r, a CancelReader returned by NewReader, over to
+// the process with the given pid, e.g. the new version of a self-upgrading
+// daemon, which receives it with Import. The handle is duplicated into the
+// process and its value is written to w along with the input read ahead
+// with WithReadAhead, so no typed-ahead input gets lost. Export cancels r,
+// which must not be read from afterwards; closing r does not affect the
+// imported input.
+func Export(r CancelReader, pid int, w io.Writer) error {
+	file, state, err := handoff(r)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_DUP_HANDLE, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("export: open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(process) // nolint: errcheck
+
+	var handle windows.Handle
+
+	err = windows.DuplicateHandle(windows.CurrentProcess(), windows.Handle(file.Fd()), process, &handle,
+		0, false, windows.DUPLICATE_SAME_ACCESS)
+	if err != nil {
+		return fmt.Errorf("export: duplicate handle: %w", err)
+	}
+
+	state.Handle = uintptr(handle)
+
+	data, err := state.marshal()
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	return nil
+}
+
+// Import receives an input sent by Export and returns a CancelReader for it
+// created with opts, which returns the input read ahead by the exporting
+// process first. Like with NewReader, ongoing reads can only be canceled if
+// the input is the console behind os.Stdin.
+func Import(r io.Reader, opts ...Option) (CancelReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	state, err := unmarshalHandoff(data)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	file := os.NewFile(state.Handle, state.Name)
+
+	return NewReader(file, append(opts, withPending(state.Pending))...)
+}
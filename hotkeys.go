@@ -0,0 +1,236 @@
+package cancelreader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hotkeys is a registry of key sequences like Ctrl+B followed by d that a
+// Decoder created with WithHotkeys intercepts, for multiplexer-style tools.
+// Keys starting a sequence are held back until the sequence is complete,
+// the next key does not continue it, or the timeout passed, in which case
+// they are returned as ordinary input. Hotkeys can be changed while a
+// Decoder uses them.
+type Hotkeys struct {
+	timeout time.Duration
+
+	lock     sync.Mutex
+	bindings map[string]hotkey
+}
+
+type hotkey struct {
+	keys []KeyEvent
+	fn   func()
+}
+
+// HotkeyEvent is returned by a Decoder for a completed sequence registered
+// without a callback.
+type HotkeyEvent struct {
+	Name string
+}
+
+func (HotkeyEvent) isEvent() {}
+
+func (e HotkeyEvent) String() string {
+	return "hotkey " + e.Name
+}
+
+// hotkeyCall is a callback queued in order with the held back keys.
+type hotkeyCall struct {
+	fn func()
+}
+
+func (hotkeyCall) isEvent() {}
+
+func (hotkeyCall) String() string {
+	return "hotkey callback"
+}
+
+// NewHotkeys returns an empty registry waiting up to timeout for the next key
+// of a sequence. The timeout is implemented with the wait mechanism of the
+// backend like WithEscTimeout; with other readers or a timeout of 0 the next
+// key is waited for indefinitely.
+func NewHotkeys(timeout time.Duration) *Hotkeys {
+	return &Hotkeys{timeout: timeout, bindings: map[string]hotkey{}}
+}
+
+// Register binds the sequence keys to name. A completed sequence calls fn or,
+// if fn is nil, is returned as a HotkeyEvent. A sequence must not be a prefix
+// of another one, as it could never be told apart.
+func (h *Hotkeys) Register(name string, keys []KeyEvent, fn func()) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("hotkey %s: empty sequence", name)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for other, b := range h.bindings {
+		if other != name && (hasKeyPrefix(b.keys, keys) || hasKeyPrefix(keys, b.keys)) {
+			return fmt.Errorf("hotkey %s: %s conflicts with %s", name, formatKeys(keys), other)
+		}
+	}
+
+	h.bindings[name] = hotkey{keys: append([]KeyEvent(nil), keys...), fn: fn}
+
+	return nil
+}
+
+// Unregister removes the sequence bound to name.
+func (h *Hotkeys) Unregister(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.bindings, name)
+}
+
+// match returns the event of the sequence completed by keys. prefix reports
+// whether keys start a sequence.
+func (h *Hotkeys) match(keys []KeyEvent) (ev Event, prefix bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for name, b := range h.bindings {
+		switch {
+		case len(b.keys) == len(keys) && hasKeyPrefix(b.keys, keys):
+			if b.fn != nil {
+				return hotkeyCall{b.fn}, false
+			}
+
+			return HotkeyEvent{name}, false
+		case hasKeyPrefix(b.keys, keys):
+			prefix = true
+		}
+	}
+
+	return nil, prefix
+}
+
+// hasKeyPrefix reports whether keys starts with prefix.
+func hasKeyPrefix(keys, prefix []KeyEvent) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+
+	for i, k := range prefix {
+		if keys[i] != k {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatKeys(keys []KeyEvent) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+	}
+
+	return strings.Join(names, " ")
+}
+
+// WithHotkeys makes the Decoder intercept the sequences of h in ReadEvent.
+func WithHotkeys(h *Hotkeys) DecoderOption {
+	return func(d *Decoder) {
+		d.hotkeys = h
+	}
+}
+
+// readHotkeys returns the next event that is not part of a hotkey sequence
+// and calls the callbacks of completed sequences in order.
+func (d *Decoder) readHotkeys(ctx context.Context) (Event, error) {
+	for {
+		if len(d.queue) > 0 {
+			ev := d.queue[0]
+			d.queue = d.queue[1:]
+
+			if call, ok := ev.(hotkeyCall); ok {
+				call.fn()
+				continue
+			}
+
+			return ev, nil
+		}
+
+		if len(d.held) > 0 && !d.awaitHotkey() {
+			d.releaseHeld()
+			continue
+		}
+
+		ev, _, err := d.readRaw(ctx)
+		if contextDone(err) {
+			// keep the held back keys for the next ReadEvent
+			return nil, err
+		}
+
+		if err != nil {
+			if len(d.held) > 0 {
+				// return the held back keys first
+				d.releaseHeld()
+				d.err = err
+
+				continue
+			}
+
+			return nil, err
+		}
+
+		key, ok := ev.(KeyEvent)
+		if !ok {
+			d.releaseHeld()
+			d.queue = append(d.queue, ev)
+
+			continue
+		}
+
+		d.matchKey(key)
+	}
+}
+
+// matchKey adds key to the held back keys and queues the completed sequence
+// or the keys that don't form one.
+func (d *Decoder) matchKey(key KeyEvent) {
+	seq := append(d.held, key)
+
+	ev, prefix := d.hotkeys.match(seq)
+	switch {
+	case ev != nil:
+		d.held = nil
+		d.queue = append(d.queue, ev)
+	case prefix:
+		d.held = seq
+	case len(d.held) > 0:
+		// key may start a sequence of its own
+		d.releaseHeld()
+		d.matchKey(key)
+	default:
+		d.queue = append(d.queue, key)
+	}
+}
+
+func (d *Decoder) releaseHeld() {
+	for _, k := range d.held {
+		d.queue = append(d.queue, k)
+	}
+	d.held = nil
+}
+
+// awaitHotkey reports whether more input is available within the timeout of
+// the hotkeys.
+func (d *Decoder) awaitHotkey() bool {
+	p, ok := d.r.(poller)
+	if len(d.buf) > 0 || d.err != nil || !ok || d.hotkeys.timeout <= 0 {
+		return true
+	}
+
+	ready, err := p.poll(d.hotkeys.timeout)
+	if err != nil {
+		d.err = err
+	}
+
+	return ready || err != nil
+}
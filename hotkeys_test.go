@@ -0,0 +1,46 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestHotkeys(t *testing.T) {
+	ctrlB := KeyEvent{Key: KeyRune, Rune: 'b', Mod: ModCtrl}
+	key := func(r rune) KeyEvent { return KeyEvent{Key: KeyRune, Rune: r} }
+
+	h := NewHotkeys(10 * time.Millisecond)
+
+	detached := 0
+	if err := h.Register("detach", []KeyEvent{ctrlB, key('d')}, func() { detached++ }); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if err := h.Register("next", []KeyEvent{ctrlB, key('n')}, nil); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if err := h.Register("prefix", []KeyEvent{ctrlB}, nil); err == nil {
+		t.Errorf("expected a prefix of another sequence to be rejected")
+	}
+
+	d := NewDecoder(&pollingChunkReader{chunkReader{"a", "\x02", "d", "\x02n", "\x02", "x", "\x02"}}, WithHotkeys(h))
+
+	for _, expected := range []Event{key('a'), HotkeyEvent{"next"}, ctrlB, key('x'), ctrlB} {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ev != expected {
+			t.Errorf("expected %v, got %v", expected, ev)
+		}
+	}
+
+	if detached != 1 {
+		t.Errorf("expected the callback to be called once, but got %d", detached)
+	}
+
+	if _, err := d.ReadEvent(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// idleLockReader locks after inactivity and passes the input to the unlock
+// callback instead of the application until it unlocks, see WithIdleLock.
+type idleLockReader struct {
+	CancelReader
+	timeout time.Duration
+	lockFn  func()
+	unlock  func(input []byte) bool
+	makeRaw func() (func() error, error) // nil if the input is no terminal
+	clock   Clock
+
+	lock      sync.Mutex
+	timer     Timer
+	lastInput time.Time
+	locked    bool
+	restore   func() error // restores the terminal mode from before locking
+}
+
+func newIdleLockReader(r *infoReader, timeout time.Duration, lock func(), unlock func([]byte) bool, clock Clock) *idleLockReader {
+	lr := &idleLockReader{
+		CancelReader: r.CancelReader,
+		timeout:      timeout,
+		lockFn:       lock,
+		unlock:       unlock,
+		clock:        clock,
+		lastInput:    clock.Now(),
+	}
+
+	if r.input != nil && r.kind == KindTerminal {
+		fd := r.input.Fd()
+		lr.makeRaw = func() (func() error, error) { return makeRaw(fd) }
+	}
+
+	lr.timer = clock.AfterFunc(timeout, lr.checkIdle)
+
+	return lr
+}
+
+// checkIdle locks once no input arrived for the timeout.
+func (r *idleLockReader) checkIdle() {
+	r.lock.Lock()
+
+	if r.locked || r.timer == nil {
+		r.lock.Unlock()
+		return
+	}
+
+	if left := r.timeout - r.clock.Now().Sub(r.lastInput); left > 0 {
+		// input arrived while the timer fired
+		r.timer.Reset(left)
+		r.lock.Unlock()
+
+		return
+	}
+
+	// keys unlock without Enter and are not echoed while locked
+	r.locked = true
+	if r.makeRaw != nil {
+		r.restore, _ = r.makeRaw()
+	}
+	r.lock.Unlock()
+
+	r.lockFn()
+}
+
+func (r *idleLockReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *idleLockReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for {
+		n, err := readContext(ctx, r.CancelReader, data)
+		if n == 0 {
+			return n, err
+		}
+
+		r.lock.Lock()
+		locked := r.locked
+		r.lock.Unlock()
+
+		if locked && !r.unlock(data[:n]) {
+			if err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		r.lock.Lock()
+		if r.locked {
+			_ = r.unlockTerminal()
+		}
+		r.lastInput = r.clock.Now()
+		if r.timer != nil {
+			r.timer.Reset(r.timeout)
+		}
+		r.lock.Unlock()
+
+		if locked {
+			// the input unlocked and is not meant for the application
+			if err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		return n, err
+	}
+}
+
+// poll reports input of the underlying reader, even if it only unlocks the
+// terminal and is not returned by Read.
+func (r *idleLockReader) poll(timeout time.Duration) (bool, error) {
+	return pollReader(r.CancelReader, timeout)
+}
+
+// unlockTerminal restores the terminal mode. It must be called with the lock
+// held.
+func (r *idleLockReader) unlockTerminal() error {
+	r.locked = false
+
+	if r.restore == nil {
+		return nil
+	}
+
+	err := r.restore()
+	r.restore = nil
+
+	return err
+}
+
+func (r *idleLockReader) unwrap() CancelReader {
+	return r.CancelReader
+}
+
+// Close stops the idle detection and restores the terminal if it is locked.
+func (r *idleLockReader) Close() error {
+	r.lock.Lock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	err := r.unlockTerminal()
+	r.lock.Unlock()
+
+	return errors.Join(r.CancelReader.Close(), err)
+}
@@ -0,0 +1,57 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestIdleLock(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	clock := &manualClock{now: time.Unix(0, 0)}
+	locked := make(chan struct{}, 1)
+
+	var pin []byte
+
+	cr, err := NewReader(pr, WithClock(clock), WithIdleLock(time.Minute, func() {
+		locked <- struct{}{}
+	}, func(input []byte) bool {
+		pin = append(pin, input...)
+		return string(pin) == "1234"
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-locked:
+		t.Fatalf("expected no lock before the timeout")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-locked:
+	default:
+		t.Fatalf("expected a lock after the timeout")
+	}
+
+	go func() {
+		for _, chunk := range []string{"12", "34", "x"} {
+			_, _ = pw.Write([]byte(chunk))
+		}
+	}()
+
+	data := make([]byte, 8)
+	n, err := cr.Read(data)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "x" || string(pin) != "1234" {
+		t.Errorf("expected the PIN to unlock and %q to be read, but got %q and %q", "x", pin, data[:n])
+	}
+}
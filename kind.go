@@ -0,0 +1,56 @@
+package cancelreader
+
+import (
+	"io"
+	"net"
+)
+
+// Kind describes what kind of input a CancelReader reads from.
+type Kind int
+
+// Kinds reported by InputKind.
+const (
+	KindUnknown Kind = iota
+	KindTerminal
+	KindPipe
+	KindFile
+	KindSocket
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindTerminal:
+		return "terminal"
+	case KindPipe:
+		return "pipe"
+	case KindFile:
+		return "file"
+	case KindSocket:
+		return "socket"
+	default:
+		return "unknown"
+	}
+}
+
+// InputKind returns the kind of input a CancelReader returned by NewReader
+// reads from, as determined when it was created. Applications can use it to
+// decide whether to show prompts and progress bars or to enable raw mode. It
+// returns KindUnknown for other readers.
+func InputKind(r CancelReader) Kind {
+	if info, ok := infoOf(r); ok {
+		return info.kind
+	}
+
+	return KindUnknown
+}
+
+func inputKind(reader io.Reader) Kind {
+	switch r := reader.(type) {
+	case net.Conn:
+		return KindSocket
+	case File:
+		return fileKind(r)
+	default:
+		return KindUnknown
+	}
+}
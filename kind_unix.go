@@ -0,0 +1,30 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+func fileKind(f File) Kind {
+	var stat unix.Stat_t
+
+	err := unix.Fstat(int(f.Fd()), &stat)
+	if err != nil {
+		return KindUnknown
+	}
+
+	switch stat.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		if _, err := unix.IoctlGetTermios(int(f.Fd()), ioctlGetTermios); err == nil {
+			return KindTerminal
+		}
+	case unix.S_IFIFO:
+		return KindPipe
+	case unix.S_IFREG:
+		return KindFile
+	case unix.S_IFSOCK:
+		return KindSocket
+	}
+
+	return KindUnknown
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import "golang.org/x/sys/windows"
+
+func fileKind(f File) Kind {
+	h := windows.Handle(f.Fd())
+
+	t, err := windows.GetFileType(h)
+	if err != nil {
+		return KindUnknown
+	}
+
+	switch t {
+	case windows.FILE_TYPE_CHAR:
+		var mode uint32
+		if windows.GetConsoleMode(h, &mode) == nil {
+			return KindTerminal
+		}
+	case windows.FILE_TYPE_PIPE:
+		// Cygwin and MSYS2 terminals without winpty are pipes as well
+		return KindPipe
+	case windows.FILE_TYPE_DISK:
+		return KindFile
+	}
+
+	return KindUnknown
+}
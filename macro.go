@@ -0,0 +1,168 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxMacroDepth limits how often the replacement of a macro may contain
+	// another macro.
+	maxMacroDepth = 8
+
+	// macroWait is how long the start of a macro sequence at the end of the
+	// input is held back waiting for the rest.
+	macroWait = 25 * time.Millisecond
+)
+
+// ErrMacroRecursion is returned by NewReader for macros expanding into each
+// other in a cycle or more than eight levels deep.
+var ErrMacroRecursion = errors.New("macro recursion")
+
+// checkMacros returns an error if the expansion of macros does not
+// terminate within maxMacroDepth levels.
+func checkMacros(macros map[string]string) error {
+	levels := make(map[string]int, len(macros)) // 0 while being computed
+
+	var level func(seq string) int
+	level = func(seq string) int {
+		if l, ok := levels[seq]; ok {
+			if l == 0 {
+				return maxMacroDepth + 1 // cycle
+			}
+
+			return l
+		}
+		levels[seq] = 0
+
+		l := 1
+		for other := range macros {
+			if strings.Contains(macros[seq], other) {
+				if sub := level(other) + 1; sub > l {
+					l = sub
+				}
+			}
+		}
+		levels[seq] = l
+
+		return l
+	}
+
+	for seq := range macros {
+		if seq == "" {
+			return errors.New("macro with empty sequence")
+		}
+
+		if level(seq) > maxMacroDepth {
+			return fmt.Errorf("%w: %q", ErrMacroRecursion, seq)
+		}
+	}
+
+	return nil
+}
+
+// macroReader replaces input sequences with their macro expansion, see
+// WithMacros.
+type macroReader struct {
+	CancelReader
+	macros map[string]string
+
+	out  []byte
+	held []byte // the start of a sequence waiting for the rest
+	err  error
+}
+
+func newMacroReader(cr CancelReader, macros map[string]string) *macroReader {
+	return &macroReader{CancelReader: cr, macros: macros}
+}
+
+func (r *macroReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *macroReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			err := r.err
+			r.err = nil
+
+			return 0, err
+		}
+
+		buf := make([]byte, len(data))
+		n, err := readContext(ctx, r.CancelReader, buf)
+		r.err = err
+
+		in := append(r.held, buf[:n]...)
+		r.out, r.held = r.expand(in, err != nil, maxMacroDepth)
+
+		if len(r.held) > 0 && !r.continued() {
+			var out []byte
+			out, r.held = r.expand(r.held, true, maxMacroDepth)
+			r.out = append(r.out, out...)
+		}
+	}
+
+	n := copy(data, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *macroReader) poll(timeout time.Duration) (bool, error) {
+	if len(r.out) > 0 || r.err != nil {
+		return true, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
+
+// continued reports whether more input arrives within macroWait.
+func (r *macroReader) continued() bool {
+	p, ok := r.CancelReader.(poller)
+	if !ok || r.err != nil {
+		return false
+	}
+
+	ready, err := p.poll(macroWait)
+
+	return ready && err == nil
+}
+
+// expand replaces the longest sequence at each position of in with its
+// expansion, which is expanded again up to depth levels. Unless final is
+// set, a possible start of a longer sequence at the end of in is returned
+// as rest.
+func (r *macroReader) expand(in []byte, final bool, depth int) (out, rest []byte) {
+	for i := 0; i < len(in); {
+		match, partial := "", false
+
+		for seq := range r.macros {
+			switch {
+			case strings.HasPrefix(string(in[i:]), seq):
+				if len(seq) > len(match) {
+					match = seq
+				}
+			case strings.HasPrefix(seq, string(in[i:])):
+				partial = true
+			}
+		}
+
+		switch {
+		case partial && !final:
+			return out, in[i:]
+		case match != "" && depth > 0:
+			expanded, _ := r.expand([]byte(r.macros[match]), true, depth-1)
+			out = append(out, expanded...)
+			i += len(match)
+		default:
+			out = append(out, in[i])
+			i++
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package cancelreader
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMacros(t *testing.T) {
+	macros := map[string]string{
+		"\x1b[15~": "reload\r", // F5
+		"\x1b":     "",         // Escape is disabled
+		"r":        "R",
+		"hi":       "hello r",
+	}
+
+	if err := checkMacros(macros); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	cr := newMacroReader(&pollingCancelReader{pollingChunkReader{chunkReader{"a\x1b[1", "5~hi", "\x1b"}}}, macros)
+
+	data, err := ioutil.ReadAll(cr)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if expected := "aReload\rhello R"; string(data) != expected {
+		t.Errorf("expected %q, but got %q", expected, data)
+	}
+
+	for _, macros := range []map[string]string{
+		{"a": "b", "b": "a"},
+		{"a": "xa"},
+	} {
+		if _, err := NewReader(strings.NewReader(""), WithMacros(macros)); !errors.Is(err, ErrMacroRecursion) {
+			t.Errorf("expected ErrMacroRecursion for %q, but got %v", macros, err)
+		}
+	}
+}
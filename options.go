@@ -0,0 +1,441 @@
+package cancelreader
+
+import (
+	"fmt"
+	"time"
+)
+
+// names of the implementations behind NewReader
+const (
+	backendEpoll    = "epoll"
+	backendKqueue   = "kqueue"
+	backendSelect   = "select"
+	backendConsole  = "console"
+	backendFallback = "fallback"
+	backendBridge   = "bridge"
+	backendPoll     = "poll"
+	backendNetpoll  = "netpoll"
+	backendShared   = "shared"
+	backendDeadline = "deadline"
+)
+
+// Option configures a CancelReader returned by NewReader. Options that do not
+// apply to the current platform or input are ignored.
+type Option func(*config)
+
+type config struct {
+	backend         string
+	translateKeys   bool
+	translateEvents bool
+	detectBOM       bool
+	normalize       bool
+	ctrlZ           CtrlZ
+	onFirstRead     func(FirstRead)
+	batchInterval   time.Duration
+	loaded          func() bool
+	idleTimeout     time.Duration
+	stateEvents     chan<- StateEvent
+	traceHash       bool
+	clock           Clock
+	detectResume    bool
+	healthEvents    chan<- Health
+	probeBackends   bool
+	readAhead       int
+	readCredit      int64
+	flowControl     bool
+	macros          map[string]string
+	remapKey        func(KeyEvent) KeyEvent
+	pending         []byte // handed over by Export
+	quantum         time.Duration
+	lockTimeout     time.Duration
+	lockFn          func()
+	unlockFn        func(input []byte) bool
+	cancelTimeout   time.Duration
+	noInputFlush    bool
+	cancelOnClose   bool
+	eofOnCancel     bool
+	poller          *sharedPoller // see Poller
+	tty             bool          // input opened by NewTTYReader
+	ownership       bool
+	dupFd           bool
+	platformConfig
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// wrap applies the options implemented on top of the backends.
+func (c *config) wrap(r *infoReader) {
+	if len(c.pending) > 0 {
+		r.CancelReader = &pendingReader{CancelReader: r.CancelReader, pending: c.pending}
+	}
+
+	if c.flowControl {
+		r.credit = newCreditReader(r.CancelReader, c.readCredit)
+		r.CancelReader = r.credit
+	}
+
+	if c.readAhead > 0 {
+		r.readAhead = newReadAheadReader(r.CancelReader, c.readAhead)
+		r.CancelReader = r.readAhead
+	}
+
+	if c.detectBOM {
+		r.bom = newBOMReader(r.CancelReader)
+		r.CancelReader = r.bom
+	}
+
+	ctrlZEOF := c.ctrlZ == CtrlZEOF || c.normalize && c.ctrlZ != CtrlZData
+	if c.normalize || ctrlZEOF {
+		r.CancelReader = newPipelineReader(r.CancelReader, c.normalize, c.normalize, ctrlZEOF)
+	}
+
+	if c.remapKey != nil {
+		r.CancelReader = newRemapReader(r.CancelReader, c.remapKey)
+	}
+
+	if len(c.macros) > 0 {
+		r.CancelReader = newMacroReader(r.CancelReader, c.macros)
+	}
+
+	if c.batchInterval > 0 {
+		r.CancelReader = newBatchReader(r.CancelReader, c.batchInterval, c.loaded, c.clock)
+	}
+
+	if c.quantum > 0 {
+		r.CancelReader = newQuantizingReader(r.CancelReader, c.quantum, c.clock)
+	}
+
+	if c.lockTimeout > 0 {
+		r.CancelReader = newIdleLockReader(r, c.lockTimeout, c.lockFn, c.unlockFn, c.clock)
+	}
+}
+
+// Backends returns the names of the implementations available on this
+// platform for WithBackend, the default one first. The fallback backend,
+// which can't cancel ongoing reads, is always available.
+func Backends() []string {
+	return append(registeredBackends(), backends...)
+}
+
+// WithBackend forces NewReader to use the named implementation instead of
+// picking one based on the platform and input. See Backends for the available
+// names. NewReader fails if the backend is unknown or can't handle the input.
+func WithBackend(name string) Option {
+	return func(c *config) {
+		c.backend = name
+	}
+}
+
+func errUnknownBackend(name string) error {
+	return fmt.Errorf("unknown backend %q, available are %q", name, Backends())
+}
+
+func errUnsupportedInput(name string, reader interface{}) error {
+	return fmt.Errorf("%s backend can't read from %T", name, reader)
+}
+
+// WithKeyTranslation makes the Windows implementation read key events from the
+// console and translate them into the escape sequences of xterm. This way,
+// arrow keys, function keys, Home/End and their modifiers can be parsed like
+// on unix terminals even on legacy consoles without virtual terminal input
+// support. It should be combined with a console in raw mode, see
+// PrepareConsole.
+func WithKeyTranslation() Option {
+	return func(c *config) {
+		c.translateKeys = true
+	}
+}
+
+// WithCancelTimeout sets how long Cancel waits for the pending Read to
+// acknowledge the cancelation before giving up and returning false. It only
+// applies to the Windows implementation, which defaults to 100ms, as the
+// other backends cancel without waiting.
+func WithCancelTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.cancelTimeout = d
+	}
+}
+
+// WithCancelOnClose makes Close cancel the pending Read and wait for it to
+// return before releasing the resources of the backend, so that closing a
+// reader that another goroutine reads from neither hangs nor leaks. Close
+// does not wait if the Read can't be interrupted, see CanCancel.
+func WithCancelOnClose() Option {
+	return func(c *config) {
+		c.cancelOnClose = true
+	}
+}
+
+// WithEOFOnCancel makes Reads of a canceled reader return io.EOF instead of
+// ErrCanceled, for consumers like bufio.Scanner that only stop cleanly at
+// the end of the input.
+func WithEOFOnCancel() Option {
+	return func(c *config) {
+		c.eofOnCancel = true
+	}
+}
+
+// WithOwnership makes Close also close the input after releasing the
+// backend, if the input is an io.Closer. Errors of both are combined.
+func WithOwnership() Option {
+	return func(c *config) {
+		c.ownership = true
+	}
+}
+
+// WithDupFd makes the reader use a duplicate of the file descriptor of the
+// input, so that closing the original, e.g. os.Stdin, can't invalidate the
+// descriptor registered with epoll or kqueue during a Read. It is ignored on
+// Windows, where the console backend opens CONIN$ on its own, and for a
+// syscall.Conn, which is always waited on with a duplicate while Reads go
+// through the connection.
+func WithDupFd() Option {
+	return func(c *config) {
+		c.dupFd = true
+	}
+}
+
+// WithoutInputFlush keeps the input that is already buffered by the Windows
+// console, e.g. keys typed ahead before the reader was created, instead of
+// discarding it when CONIN$ is opened. The buffer may then contain events
+// that wake up a Read without being readable, so that it can't be canceled.
+func WithoutInputFlush() Option {
+	return func(c *config) {
+		c.noInputFlush = true
+	}
+}
+
+// WithEventTranslation extends WithKeyTranslation to mouse and window size
+// events on Windows. Mouse events are encoded as SGR mouse reports and a
+// resize of the console as the xterm text area size report ESC [ 8 ; rows ;
+// columns t, which takes the place of SIGWINCH. The console has to report
+// these events, see ConsoleOptions.MouseInput and ConsoleOptions.WindowInput.
+func WithEventTranslation() Option {
+	return func(c *config) {
+		c.translateKeys = true
+		c.translateEvents = true
+	}
+}
+
+// WithBOMDetection detects a byte order mark at the start of the input, as
+// written by PowerShell when redirecting to a file or pipe. A UTF-8 BOM is
+// removed and UTF-16 input is transcoded to UTF-8. Input without a BOM is
+// passed through unchanged. DetectedEncoding reports the result.
+func WithBOMDetection() Option {
+	return func(c *config) {
+		c.detectBOM = true
+	}
+}
+
+// WithPipelineNormalization makes input from cmd and PowerShell pipelines
+// look like input from unix pipelines: CRLF line endings are replaced with
+// LF, a Ctrl+Z at the start of a line ends the input like in cooked console
+// input unless WithCtrlZ(CtrlZData) is used, and a missing final LF is added,
+// since PowerShell always appends one. A CR at the end of a Read is held back
+// until the next byte is known, so this is meant for redirected input and
+// consoles in line mode, not raw terminals.
+func WithPipelineNormalization() Option {
+	return func(c *config) {
+		c.normalize = true
+	}
+}
+
+// CtrlZ selects how WithCtrlZ handles Ctrl+Z in the input.
+type CtrlZ int
+
+const (
+	// CtrlZDefault passes Ctrl+Z through unless WithPipelineNormalization
+	// is used.
+	CtrlZDefault CtrlZ = iota
+
+	// CtrlZEOF ends the input with io.EOF at a Ctrl+Z at the start of a
+	// line, like native Windows console tools do in cooked input.
+	CtrlZEOF
+
+	// CtrlZData always passes Ctrl+Z through as data, also with
+	// WithPipelineNormalization, e.g. for raw mode input.
+	CtrlZData
+)
+
+// WithCtrlZ selects how a Ctrl+Z (SUB) in the input is handled. The Windows
+// console delivers it as data, even at the start of a line in cooked input,
+// where native console tools treat it as the end of the input.
+func WithCtrlZ(mode CtrlZ) Option {
+	return func(c *config) {
+		c.ctrlZ = mode
+	}
+}
+
+// WithFirstReadHook calls fn once when the reader returns data for the first
+// time, e.g. to profile why an interactive startup is slow over RDP or SSH.
+// See also FirstReadLatency.
+func WithFirstReadHook(fn func(FirstRead)) Option {
+	return func(c *config) {
+		c.onFirstRead = fn
+	}
+}
+
+// defaultPressure is the CPU pressure in percent above which
+// WithAdaptivePolling batches input if no load signal is given.
+const defaultPressure = 10
+
+// WithAdaptivePolling batches input while loaded reports that the system is
+// under load: a Read that received input waits up to interval for more
+// before it returns. With thousands of idle sessions, e.g. in a terminal
+// gateway, this trades a little latency for far fewer wakeups. Without load,
+// reads return immediately. If loaded is nil, CPUPressureAbove(10) is used.
+func WithAdaptivePolling(interval time.Duration, loaded func() bool) Option {
+	return func(c *config) {
+		if loaded == nil {
+			loaded = CPUPressureAbove(defaultPressure)
+		}
+
+		c.batchInterval = interval
+		c.loaded = loaded
+	}
+}
+
+// WithStateEvents sends a StateEvent to events whenever the reader becomes
+// idle because no input arrived for timeout and when input arrives again,
+// e.g. to show activity indicators per session in a multiplexer. Events are
+// dropped if events is not ready to receive, so it should be buffered.
+func WithStateEvents(timeout time.Duration, events chan<- StateEvent) Option {
+	return func(c *config) {
+		c.idleTimeout = timeout
+		c.stateEvents = events
+	}
+}
+
+// WithTraceHash makes the reader compute a 64-bit FNV-1a hash of all bytes it
+// returned, see Stats. Proxies can compare it with a hash computed on the
+// other side to verify that what a client typed reached the backend
+// unchanged, e.g. across the transcoding of Windows input.
+func WithTraceHash() Option {
+	return func(c *config) {
+		c.traceHash = true
+	}
+}
+
+// WithClock makes the reader take the time from clock instead of the time
+// package, see Clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithResumeDetection makes the reader re-validate its epoll registrations or
+// its console handle after the system resumed from sleep, so that Cancel keeps
+// working. Resumes are detected by comparing the wall clock with the
+// monotonic clock every few seconds and on Windows also by power events. The
+// kqueue and select backends register the input on every wait and don't need
+// it. On Windows, reconnects of the remote desktop or console session are
+// handled the same way and the changes of ConsoleSessions are applied again.
+func WithResumeDetection() Option {
+	return func(c *config) {
+		c.detectResume = true
+	}
+}
+
+// WithHealthEvents sends the Health events detected with WithResumeDetection
+// to events, e.g. to redraw the screen after a reconnect. Events are dropped
+// if events is not ready to receive, so it should be buffered.
+func WithHealthEvents(events chan<- Health) Option {
+	return func(c *config) {
+		c.healthEvents = events
+	}
+}
+
+// WithBackendProbe makes NewReader measure the wake-up latency of the
+// backends available for the input and use the fastest one instead of the
+// default, e.g. select where epoll on ttys is degraded in a container. The
+// results are measured once per kind of input and process and can be
+// inspected with Probes. Probing does not consume input, but pending input
+// keeps the default backend as it hides the latency.
+func WithBackendProbe() Option {
+	return func(c *config) {
+		c.probeBackends = true
+	}
+}
+
+// WithReadAhead keeps a Read of up to size bytes outstanding in the
+// background, so that input is usually buffered already when the
+// application reads. This hides the latency of the overlapped reads of the
+// Windows console in particular. Cancel still interrupts the outstanding
+// Read; input read ahead before is returned before ErrCanceled, so nothing is
+// lost by canceling.
+func WithReadAhead(size int) Option {
+	return func(c *config) {
+		c.readAhead = size
+	}
+}
+
+// WithReadCredit enables credit-based flow control: the reader reads at most
+// credit bytes from the input until more is granted with AddReadCredit or
+// SetReadLimit. Reads block while the credit is used up and Cancel interrupts
+// them. With WithReadAhead, the credit also limits reading ahead.
+func WithReadCredit(credit int64) Option {
+	return func(c *config) {
+		c.flowControl = true
+		c.readCredit = credit
+	}
+}
+
+// WithMacros replaces input sequences, the keys of macros, with byte strings
+// before the application reads them, e.g. to remap keys in kiosk
+// deployments. At each position the longest sequence wins and the start of
+// a sequence is held back briefly for the rest to arrive. Replacements may
+// contain other sequences, which are expanded up to eight levels deep;
+// NewReader fails with ErrMacroRecursion for deeper or cyclic macros.
+func WithMacros(macros map[string]string) Option {
+	return func(c *config) {
+		c.macros = make(map[string]string, len(macros))
+		for seq, replacement := range macros {
+			c.macros[seq] = replacement
+		}
+	}
+}
+
+// WithKeyRemap passes every key decoded from the input to remap and
+// delivers the returned key instead, e.g. to swap keys for accessibility or
+// a Dvorak layout in any application. Keys that remap returns unchanged and
+// other input pass through byte for byte. On Windows, it applies to the
+// sequences of WithKeyTranslation.
+func WithKeyRemap(remap func(KeyEvent) KeyEvent) Option {
+	return func(c *config) {
+		c.remapKey = remap
+	}
+}
+
+// WithIdleLock calls lock once no input arrived for timeout, e.g. to show the
+// lock screen of a kiosk terminal. While locked, input is passed to unlock
+// instead of being returned by Read, until unlock returns true, so the lock
+// screen reads the PIN through the same reader. A terminal is put into raw
+// mode while locked, so keys arrive without Enter and are not echoed, and
+// its previous mode is restored on unlock.
+func WithIdleLock(timeout time.Duration, lock func(), unlock func(input []byte) bool) Option {
+	return func(c *config) {
+		c.lockTimeout = timeout
+		c.lockFn = lock
+		c.unlockFn = unlock
+	}
+}
+
+// WithKeystrokeQuantization delays input until the next multiple of quantum
+// since the reader was created and returns everything that arrived until
+// then at once, so the timing of keystrokes forwarded to a remote endpoint
+// doesn't reveal what was typed, like ObscureKeystrokeTiming of OpenSSH. It
+// adds less than quantum of latency; 20ms is a good value. It is off by
+// default.
+func WithKeystrokeQuantization(quantum time.Duration) Option {
+	return func(c *config) {
+		c.quantum = quantum
+	}
+}
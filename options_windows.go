@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+type platformConfig struct {
+	records         chan<- InputRecord
+	recordsOnly     bool
+	cancelEventName string
+}
+
+// WithCancelEventName backs the cancel event of the Windows implementation
+// with a named event object, so that another process, e.g. a supervisor, can
+// cancel the pending Read with CancelByName. The name may start with
+// "Global\\" or "Local\\" to select the namespace.
+func WithCancelEventName(name string) Option {
+	return func(c *config) {
+		c.cancelEventName = name
+	}
+}
+
+// WithInputRecords makes the Windows implementation send every console input
+// record it reads to records, preserving repeat counts, virtual key codes and
+// control key states. Read keeps returning the translated byte stream as with
+// WithKeyTranslation. Read blocks until records has been received from, so
+// the channel has to be drained concurrently.
+func WithInputRecords(records chan<- InputRecord) Option {
+	return func(c *config) {
+		c.translateKeys = true
+		c.records = records
+	}
+}
+
+// WithInputRecordsOnly is like WithInputRecords but does not produce a byte
+// stream. Read only forwards console input records to records and returns
+// when the reader is canceled or fails.
+func WithInputRecordsOnly(records chan<- InputRecord) Option {
+	return func(c *config) {
+		c.translateKeys = true
+		c.records = records
+		c.recordsOnly = true
+	}
+}
@@ -0,0 +1,119 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ctrlZ is the end of file marker of DOS and the Windows console.
+const ctrlZ = 0x1a
+
+// pipelineReader normalizes the input of cmd and PowerShell pipelines, see
+// WithPipelineNormalization and WithCtrlZ.
+type pipelineReader struct {
+	CancelReader
+
+	crlf     bool // replace CRLF with LF
+	finalLF  bool // add a missing final LF
+	ctrlZEOF bool // end the input at a Ctrl+Z at the start of a line
+
+	out []byte
+	err error
+
+	cr      bool // a CR is held back to see if a LF follows
+	emitted bool
+	last    byte
+	eof     bool // Ctrl+Z ended the input
+}
+
+func newPipelineReader(cr CancelReader, crlf, finalLF, ctrlZEOF bool) *pipelineReader {
+	return &pipelineReader{CancelReader: cr, crlf: crlf, finalLF: finalLF, ctrlZEOF: ctrlZEOF}
+}
+
+func (r *pipelineReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *pipelineReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		if r.err != nil {
+			err := r.err
+			r.err = nil
+
+			return 0, err
+		}
+
+		buf := make([]byte, len(data))
+		n, err := readContext(ctx, r.CancelReader, buf)
+		r.err = err
+		r.normalize(buf[:n], errors.Is(err, io.EOF))
+	}
+
+	n := copy(data, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *pipelineReader) poll(timeout time.Duration) (bool, error) {
+	if len(r.out) > 0 || r.eof {
+		return true, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
+
+// normalize moves b to out, replacing CRLF with LF and stopping at a Ctrl+Z
+// at the start of a line. At the end of the input, a missing final LF is
+// added. Each of these steps is only done if enabled.
+func (r *pipelineReader) normalize(b []byte, atEOF bool) {
+	for _, c := range b {
+		if r.cr {
+			r.cr = false
+			if c == '\n' {
+				r.emit('\n')
+				continue
+			}
+
+			r.emit('\r')
+		}
+
+		if c == '\r' && r.crlf {
+			r.cr = true
+			continue
+		}
+
+		if c == ctrlZ && r.ctrlZEOF && (!r.emitted || r.last == '\n' || r.last == '\r') {
+			r.eof = true
+			break
+		}
+
+		r.emit(c)
+	}
+
+	if !atEOF && !r.eof {
+		return
+	}
+
+	r.eof = true
+	if r.cr {
+		r.cr = false
+		r.emit('\n')
+	}
+
+	if r.finalLF && r.emitted && r.last != '\n' {
+		r.emit('\n')
+	}
+}
+
+func (r *pipelineReader) emit(c byte) {
+	r.out = append(r.out, c)
+	r.last = c
+	r.emitted = true
+}
@@ -0,0 +1,56 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+)
+
+func TestPipelineNormalization(t *testing.T) {
+	for _, tc := range []struct {
+		chunks   chunkReader
+		expected string
+	}{
+		{chunkReader{"a\r\nb\r\n"}, "a\nb\n"},
+		{chunkReader{"a\r", "\nb"}, "a\nb\n"},
+		{chunkReader{"a\rb\r"}, "a\rb\n"},
+		{chunkReader{"a\n\x1a\r\nignored"}, "a\n"},
+		{chunkReader{"\x1a"}, ""},
+		{chunkReader{"a\x1ab"}, "a\x1ab\n"},
+		{chunkReader{}, ""},
+	} {
+		cr, _ := newFallbackCancelReader(&tc.chunks)
+
+		out, err := io.ReadAll(newPipelineReader(cr, true, true, true))
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if string(out) != tc.expected {
+			t.Errorf("expected %q, but got %q", tc.expected, out)
+		}
+	}
+}
+
+func TestCtrlZ(t *testing.T) {
+	for _, tc := range []struct {
+		opts     []Option
+		expected string
+	}{
+		{nil, "a\r\n\x1a\r\nb"},
+		{[]Option{WithCtrlZ(CtrlZEOF)}, "a\r\n"},
+		{[]Option{WithPipelineNormalization()}, "a\n"},
+		{[]Option{WithPipelineNormalization(), WithCtrlZ(CtrlZData)}, "a\n\x1a\nb\n"},
+	} {
+		cr, _ := newFallbackCancelReader(&chunkReader{"a\r\n\x1a\r\nb"})
+
+		r := &infoReader{CancelReader: cr, clock: systemClock{}}
+		newConfig(tc.opts).wrap(r)
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if string(out) != tc.expected {
+			t.Errorf("expected %q, but got %q", tc.expected, out)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWouldBlock is returned by TryRead if no input is available.
+var ErrWouldBlock = errors.New("read would block")
+
+// Poll waits up to timeout for input without consuming it and reports
+// whether input is available, so that callers can interleave waiting for
+// input with timers or animation frames. It returns ErrCanceled once r is
+// canceled and fails for readers that can't wait for input with a timeout,
+// like the fallback backend.
+func Poll(r CancelReader, timeout time.Duration) (bool, error) {
+	info, ok := infoOf(r)
+	if ok {
+		info.lock.Lock()
+		canceled, unread := info.canceled, len(info.unread)
+		info.lock.Unlock()
+
+		if canceled {
+			return false, info.withCause(ErrCanceled)
+		}
+
+		if unread > 0 {
+			return true, nil
+		}
+
+		if info.backend == backendFallback {
+			return false, fmt.Errorf("%s backend cannot poll for input", info.backend)
+		}
+	}
+
+	p, isPoller := r.(poller)
+	if !isPoller {
+		return false, fmt.Errorf("%T cannot poll for input", r)
+	}
+
+	ready, err := p.poll(timeout)
+	if ok {
+		err = info.withCause(err)
+	}
+
+	return ready, err
+}
+
+// TryRead reads from r if input is available and returns ErrWouldBlock
+// otherwise, so that event loops can check for input without committing to
+// a blocking Read.
+func TryRead(r CancelReader, data []byte) (int, error) {
+	ready, err := Poll(r, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ready {
+		return 0, ErrWouldBlock
+	}
+
+	return r.Read(data)
+}
+
+// Pending returns how much input can be read from r without blocking: the
+// number of bytes on unix and the number of console input events on
+// Windows, plus the input read ahead, see WithReadAhead. It lets an
+// application decide between drawing a frame and reading input.
+func Pending(r CancelReader) (int, error) {
+	info, ok := infoOf(r)
+	if !ok || info.input == nil {
+		return 0, fmt.Errorf("%T does not read from a File", r)
+	}
+
+	n, err := inputPending(info.input.Fd())
+	if err != nil {
+		return 0, err
+	}
+
+	if info.readAhead != nil {
+		n += info.readAhead.pending()
+	}
+
+	info.lock.Lock()
+	n += len(info.unread)
+	info.lock.Unlock()
+
+	return n, nil
+}
+
+// ReadAtMost reads into data like a terminal in non-canonical mode with VMIN
+// and VTIME set: it blocks until input arrives and then returns once min
+// bytes are read, data is full or no more input arrived for interByte. This
+// tells a lone ESC apart from the start of an escape sequence.
+func ReadAtMost(r CancelReader, data []byte, min int, interByte time.Duration) (int, error) {
+	n := 0
+
+	for n < len(data) && (n == 0 || n < min) {
+		if n > 0 {
+			ready, err := Poll(r, interByte)
+			if !ready || err != nil {
+				// an error is returned by the next Read
+				return n, nil
+			}
+		}
+
+		m, err := r.Read(data[n:])
+		n += m
+
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,179 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTryRead(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	data := make([]byte, 4)
+	if _, err := TryRead(r, data); !errors.Is(err, ErrWouldBlock) {
+		t.Errorf("expected ErrWouldBlock, but got %v", err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	n, err := TryRead(r, data)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "a" {
+		t.Errorf("expected %q, but got %q", "a", data[:n])
+	}
+}
+
+func TestPoll(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if ready, err := Poll(r, 10*time.Millisecond); ready || err != nil {
+		t.Errorf("expected no input, but got %v, %v", ready, err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if ready, err := Poll(r, time.Second); !ready || err != nil {
+		t.Errorf("expected input, but got %v, %v", ready, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		r.Cancel()
+	}()
+
+	// the input is not consumed, so drain it first
+	if _, err := r.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if _, err := Poll(r, time.Second); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
+
+func TestPending(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if n, err := Pending(r); n != 0 || err != nil {
+		t.Errorf("expected no pending input, but got %d, %v", n, err)
+	}
+
+	if _, err := pw.Write([]byte("abc")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if n, err := Pending(r); n != 3 || err != nil {
+		t.Errorf("expected 3 pending bytes, but got %d, %v", n, err)
+	}
+}
+
+func TestReadAtMost(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	go func() {
+		_, _ = pw.Write([]byte("\x1b"))
+		time.Sleep(10 * time.Millisecond)
+		_, _ = pw.Write([]byte("[A"))
+		time.Sleep(200 * time.Millisecond)
+		_, _ = pw.Write([]byte("x"))
+	}()
+
+	data := make([]byte, 8)
+	n, err := ReadAtMost(r, data, 8, 100*time.Millisecond)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "\x1b[A" {
+		t.Errorf("expected %q, but got %q", "\x1b[A", data[:n])
+	}
+
+	n, err = ReadAtMost(r, data, 1, 100*time.Millisecond)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "x" {
+		t.Errorf("expected %q, but got %q", "x", data[:n])
+	}
+}
+
+func TestPollWrapped(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"macros":    WithMacros(map[string]string{"x": "y"}),
+		"idle lock": WithIdleLock(time.Hour, func() {}, func([]byte) bool { return true }),
+	} {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+
+		r, err := NewReader(pr, opt)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+
+		if ready, err := Poll(r, 10*time.Millisecond); ready || err != nil {
+			t.Errorf("%s: expected no input, but got %v, %v", name, ready, err)
+		}
+
+		if _, err := pw.Write([]byte("a")); err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ready, err := Poll(r, time.Second); !ready || err != nil {
+			t.Errorf("%s: expected input, but got %v, %v", name, ready, err)
+		}
+
+		_ = r.Close()
+		_ = pr.Close()
+		_ = pw.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+)
+
+// Poller waits for the input of many readers with a single epoll or kqueue
+// instance, see NewPoller. The readers it creates keep no file descriptors of
+// their own, while each reader created by NewReader keeps a poller and a
+// cancel pipe, so applications with hundreds of readers stay within their
+// file descriptor limit.
+type Poller struct {
+	shared *sharedPoller // nil if not supported on this platform
+}
+
+// NewPoller starts a Poller. On platforms without epoll or kqueue, the
+// readers of the Poller are created by NewReader as usual.
+func NewPoller() (*Poller, error) {
+	shared, err := newSharedPoller()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Poller{shared: shared}, nil
+}
+
+// NewReader returns a CancelReader for file like NewReader, but waiting for
+// input through p. Options selecting a backend are ignored unless p is not
+// supported on this platform. Inputs p can't wait for use the backend
+// NewReader would choose, e.g. fallback for regular files on Linux and select
+// for /dev/tty on macOS and BSD.
+func (p *Poller) NewReader(file File, opts ...Option) (CancelReader, error) {
+	if p.shared == nil {
+		return NewReader(file, opts...)
+	}
+
+	return NewReader(file, append(opts, func(c *config) {
+		c.backend = ""
+		c.probeBackends = false
+		c.poller = p.shared
+	})...)
+}
+
+// Close stops p. Pending and later Reads of its readers fail with
+// os.ErrClosed.
+func (p *Poller) Close() error {
+	if p.shared == nil {
+		return nil
+	}
+
+	return p.shared.close()
+}
+
+// errPollerInput is returned for inputs a Poller can't wait for.
+var errPollerInput = errors.New("shared poller needs a File")
+
+// openShared returns a reader of the shared poller of cfg.
+func openShared(reader io.Reader, cfg *config) (CancelReader, error) {
+	file, ok := reader.(File)
+	if !ok {
+		return nil, errPollerInput
+	}
+
+	// inputs the shared poller can't wait for use their usual backend
+	if !sharesInput(file) {
+		return newReader(file, cfg)
+	}
+
+	return cfg.poller.open(file)
+}
@@ -0,0 +1,78 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// sharesInput reports whether file can be added to the shared kqueue. Like
+// with NewReader, /dev/tty uses the select backend, as kqueue returns
+// instantly when polling it.
+func sharesInput(file File) bool {
+	return file.Name() != "/dev/tty"
+}
+
+type kqueueSet struct {
+	kqueue int
+	events []unix.Kevent_t
+}
+
+func newPollSet() (pollSet, error) {
+	kqueue, err := unix.Kqueue()
+	if err != nil {
+		return nil, syscallError(backendShared, "kqueue", 0, err)
+	}
+
+	return &kqueueSet{kqueue: kqueue, events: make([]unix.Kevent_t, 64)}, nil
+}
+
+func (s *kqueueSet) change(fd int, flags int) error {
+	changes := make([]unix.Kevent_t, 1)
+	unix.SetKevent(&changes[0], fd, unix.EVFILT_READ, flags)
+
+	_, err := unix.Kevent(s.kqueue, changes, nil, nil)
+
+	return syscallError(backendShared, "kevent", uintptr(fd), err)
+}
+
+func (s *kqueueSet) arm(fd int) error {
+	return s.change(fd, unix.EV_ADD|unix.EV_ENABLE|unix.EV_ONESHOT)
+}
+
+func (s *kqueueSet) remove(fd int) error {
+	err := s.change(fd, unix.EV_DELETE)
+	if errors.Is(err, unix.ENOENT) {
+		// the one-shot event fired already
+		return nil
+	}
+
+	return err
+}
+
+func (s *kqueueSet) wait() ([]int, error) {
+	for {
+		n, err := unix.Kevent(s.kqueue, nil, s.events, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return nil, syscallError(backendShared, "kevent", uintptr(s.kqueue), err)
+		}
+
+		fds := make([]int, n)
+		for i := range fds {
+			fds[i] = int(s.events[i].Ident)
+		}
+
+		return fds, nil
+	}
+}
+
+func (s *kqueueSet) close() error {
+	return syscallError(backendShared, "close", uintptr(s.kqueue), unix.Close(s.kqueue))
+}
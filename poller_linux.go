@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package cancelreader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// sharesInput reports whether file can be added to the shared epoll set.
+// Like with NewReader, regular files use the fallback backend, as epoll
+// does not support them.
+func sharesInput(file File) bool {
+	return fileKind(file) != KindFile
+}
+
+type epollSet struct {
+	epoll  int
+	events []unix.EpollEvent
+}
+
+func newPollSet() (pollSet, error) {
+	epoll, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, syscallError(backendShared, "epoll_create1", 0, err)
+	}
+
+	return &epollSet{epoll: epoll, events: make([]unix.EpollEvent, 64)}, nil
+}
+
+func (s *epollSet) arm(fd int) error {
+	event := unix.EpollEvent{Events: unix.EPOLLIN | unix.EPOLLONESHOT, Fd: int32(fd)}
+
+	err := unix.EpollCtl(s.epoll, unix.EPOLL_CTL_MOD, fd, &event)
+	if errors.Is(err, unix.ENOENT) {
+		err = unix.EpollCtl(s.epoll, unix.EPOLL_CTL_ADD, fd, &event)
+	}
+
+	return syscallError(backendShared, "epoll_ctl", uintptr(fd), err)
+}
+
+func (s *epollSet) remove(fd int) error {
+	err := unix.EpollCtl(s.epoll, unix.EPOLL_CTL_DEL, fd, nil)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+
+	return syscallError(backendShared, "epoll_ctl", uintptr(fd), err)
+}
+
+func (s *epollSet) wait() ([]int, error) {
+	for {
+		n, err := unix.EpollWait(s.epoll, s.events, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return nil, syscallError(backendShared, "epoll_wait", uintptr(s.epoll), err)
+		}
+
+		fds := make([]int, n)
+		for i := range fds {
+			fds[i] = int(s.events[i].Fd)
+		}
+
+		return fds, nil
+	}
+}
+
+func (s *epollSet) close() error {
+	return syscallError(backendShared, "close", uintptr(s.epoll), unix.Close(s.epoll))
+}
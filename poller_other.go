@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package cancelreader
+
+// sharedPoller is not supported on this platform, so Poller uses NewReader.
+type sharedPoller struct{}
+
+func newSharedPoller() (*sharedPoller, error) {
+	return nil, nil
+}
+
+func sharesInput(File) bool {
+	return false
+}
+
+func (*sharedPoller) open(File) (CancelReader, error) {
+	return nil, errUnknownBackend(backendShared)
+}
+
+func (*sharedPoller) close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPoller(t *testing.T) {
+	p, err := NewPoller()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	readers := make([]CancelReader, 2)
+	writers := make([]*os.File, 2)
+	for i := range readers {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer pr.Close()
+		defer pw.Close()
+
+		r, err := p.NewReader(pr)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer r.Close()
+
+		readers[i], writers[i] = r, pw
+	}
+
+	if Backend(readers[0]) != backendShared {
+		t.Errorf("expected the %s backend, but got %s", backendShared, Backend(readers[0]))
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		readers[0].Cancel()
+		_, _ = writers[1].Write([]byte("a"))
+	}()
+
+	if _, err := readers[0].Read(make([]byte, 1)); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	data := make([]byte, 1)
+	if _, err := readers[1].Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = p.Close()
+	}()
+
+	if _, err := readers[1].Read(data); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected os.ErrClosed, but got %v", err)
+	}
+}
+
+func TestPollerUsualBackend(t *testing.T) {
+	p, err := NewPoller()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer p.Close()
+
+	f, err := os.CreateTemp(t.TempDir(), "poller")
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("a"); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	r, err := p.NewReader(f)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if runtime.GOOS == "linux" && Backend(r) != backendFallback {
+		t.Errorf("expected the %s backend, but got %s", backendFallback, Backend(r))
+	}
+
+	data := make([]byte, 1)
+	if _, err := r.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return
+	}
+	defer tty.Close()
+
+	r, err = p.NewReader(tty)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if runtime.GOOS != "linux" && Backend(r) != backendSelect {
+		t.Errorf("expected the %s backend, but got %s", backendSelect, Backend(r))
+	}
+}
@@ -0,0 +1,287 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollSet is the epoll or kqueue instance of a sharedPoller.
+type pollSet interface {
+	// arm reports fd once when it becomes readable.
+	arm(fd int) error
+	remove(fd int) error
+	// wait blocks until file descriptors become readable and returns them.
+	wait() ([]int, error)
+	close() error
+}
+
+// sharedPoller waits for the inputs of its readers with a single pollSet. A
+// goroutine dispatches the readiness to the waiting Reads, so canceling one
+// of them only needs a channel instead of a pipe per reader.
+type sharedPoller struct {
+	set          pollSet
+	wakeR, wakeW *os.File // wake up the dispatcher on close
+
+	lock    sync.Mutex
+	fds     map[int]bool
+	waiters map[int]chan struct{}
+	err     error // of the dispatcher
+
+	closeOnce sync.Once
+	stopped   chan struct{} // closed when the dispatcher stops
+}
+
+func newSharedPoller() (*sharedPoller, error) {
+	set, err := newPollSet()
+	if err != nil {
+		return nil, err
+	}
+
+	wakeR, wakeW, err := os.Pipe()
+	if err != nil {
+		_ = set.close()
+		return nil, fmt.Errorf("creating wake pipe: %w", err)
+	}
+
+	err = set.arm(int(wakeR.Fd()))
+	if err != nil {
+		_ = set.close()
+		_ = wakeR.Close()
+		_ = wakeW.Close()
+
+		return nil, err
+	}
+
+	p := &sharedPoller{
+		set:     set,
+		wakeR:   wakeR,
+		wakeW:   wakeW,
+		fds:     make(map[int]bool),
+		waiters: make(map[int]chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go p.run()
+
+	return p, nil
+}
+
+// run dispatches readiness until the poller is closed.
+func (p *sharedPoller) run() {
+	defer close(p.stopped)
+
+	wake := int(p.wakeR.Fd())
+
+	for {
+		fds, err := p.set.wait()
+		if err != nil {
+			p.lock.Lock()
+			p.err = err
+			p.lock.Unlock()
+
+			return
+		}
+
+		for _, fd := range fds {
+			if fd == wake {
+				return
+			}
+
+			p.lock.Lock()
+			ready := p.waiters[fd]
+			delete(p.waiters, fd)
+			p.lock.Unlock()
+
+			if ready != nil {
+				close(ready)
+			}
+		}
+	}
+}
+
+// wait waits until fd is readable, canceled is closed, ctx is done or
+// timeout passed unless it is negative.
+func (p *sharedPoller) wait(ctx context.Context, fd int, canceled <-chan struct{}, timeout time.Duration) error {
+	ready := make(chan struct{})
+
+	p.lock.Lock()
+	if p.waiters[fd] != nil {
+		p.lock.Unlock()
+		return errors.New("shared poller: concurrent reads")
+	}
+	p.waiters[fd] = ready
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		if p.waiters[fd] == ready {
+			delete(p.waiters, fd)
+		}
+		p.lock.Unlock()
+	}()
+
+	err := p.set.arm(fd)
+	if err != nil {
+		return err
+	}
+
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-canceled:
+		return ErrCanceled
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-expired:
+		return ErrTimeout
+	case <-p.stopped:
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		if p.err != nil {
+			return fmt.Errorf("shared poller: %w", p.err)
+		}
+
+		return os.ErrClosed
+	}
+}
+
+// open returns a reader for file waiting for input through p.
+func (p *sharedPoller) open(file File) (CancelReader, error) {
+	fd := int(file.Fd())
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	select {
+	case <-p.stopped:
+		return nil, os.ErrClosed
+	default:
+	}
+
+	if p.fds[fd] {
+		return nil, fmt.Errorf("shared poller: file descriptor %d is already in use", fd)
+	}
+	p.fds[fd] = true
+
+	return &sharedReader{poller: p, file: file, fd: fd, canceled: make(chan struct{})}, nil
+}
+
+func (p *sharedPoller) unregister(fd int) error {
+	p.lock.Lock()
+	delete(p.fds, fd)
+	p.lock.Unlock()
+
+	return p.set.remove(fd)
+}
+
+func (p *sharedPoller) close() error {
+	err := os.ErrClosed
+
+	p.closeOnce.Do(func() {
+		_, werr := p.wakeW.Write([]byte{0})
+		if werr == nil {
+			<-p.stopped
+		}
+
+		err = errors.Join(werr, p.set.close(), p.wakeR.Close(), p.wakeW.Close())
+	})
+
+	return err
+}
+
+// sharedReader reads from a file once its sharedPoller reports it readable.
+type sharedReader struct {
+	poller *sharedPoller
+	file   File
+	fd     int
+	cancelMixin
+
+	lock     sync.Mutex
+	canceled chan struct{} // closed by Cancel and replaced by reset
+}
+
+func (r *sharedReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *sharedReader) readContext(ctx context.Context, data []byte) (int, error) {
+	if r.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	err := r.poller.wait(ctx, r.fd, r.canceledChan(), -1)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.file.Read(data)
+}
+
+func (r *sharedReader) poll(timeout time.Duration) (bool, error) {
+	if r.isCanceled() {
+		return false, ErrCanceled
+	}
+
+	err := r.poller.wait(context.Background(), r.fd, r.canceledChan(), timeout)
+	if errors.Is(err, ErrTimeout) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *sharedReader) canceledChan() <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.canceled
+}
+
+func (r *sharedReader) Cancel() bool {
+	r.setCanceled()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	select {
+	case <-r.canceled:
+	default:
+		close(r.canceled)
+	}
+
+	return true
+}
+
+func (r *sharedReader) reset() error {
+	r.resetCanceled()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.canceled = make(chan struct{})
+
+	return nil
+}
+
+func (r *sharedReader) backendName() string {
+	return backendShared
+}
+
+func (r *sharedReader) Close() error {
+	return r.poller.unregister(r.fd)
+}
@@ -0,0 +1,152 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProbeResult is the wake-up latency measured for a backend, see
+// WithBackendProbe.
+type ProbeResult struct {
+	Backend string
+
+	// Latency is how much later than requested a wait with a timeout
+	// returned.
+	Latency time.Duration
+
+	// Err is set if the backend can't handle the input.
+	Err error
+}
+
+const (
+	// probeTimeout is the timeout of the probing wait.
+	probeTimeout = 10 * time.Millisecond
+
+	// probeMargin is how much faster a backend must be to be preferred over
+	// the default one.
+	probeMargin = 5 * time.Millisecond
+)
+
+// probed caches the backend picked for each kind of input.
+var probed struct {
+	lock    sync.Mutex
+	results map[Kind]probe
+}
+
+type probe struct {
+	backend string
+	results []ProbeResult
+}
+
+// Probes returns the results of WithBackendProbe for a CancelReader returned
+// by NewReader. It returns false if the backends were not probed.
+func Probes(r CancelReader) ([]ProbeResult, bool) {
+	info, ok := infoOf(r)
+	if !ok || info.probes == nil {
+		return nil, false
+	}
+
+	return append([]ProbeResult(nil), info.probes...), true
+}
+
+// probeBackends returns the backend to use for reader and the results of the
+// probes. The backend is empty to keep the default one.
+func probeBackends(reader io.Reader, cfg *config) (string, []ProbeResult) {
+	var candidates []string
+	for _, name := range backends {
+		// the bridge would consume the input while probing
+		if name != backendFallback && name != backendBridge {
+			candidates = append(candidates, name)
+		}
+	}
+
+	file, ok := reader.(File)
+	if !ok || cfg.backend != "" || len(candidates) < 2 {
+		return "", nil
+	}
+
+	kind := fileKind(file)
+	if kind == KindFile {
+		return "", nil
+	}
+
+	probed.lock.Lock()
+	defer probed.lock.Unlock()
+
+	if p, ok := probed.results[kind]; ok {
+		return p.backend, p.results
+	}
+
+	var results []ProbeResult
+
+	best := -1
+	for _, name := range candidates {
+		res, ready := probeBackend(file, cfg, name)
+		if ready {
+			// pending input hides the latency, try again next time
+			return "", append(results, res)
+		}
+		results = append(results, res)
+
+		switch {
+		case res.Err != nil:
+		case best < 0, res.Latency+probeMargin < results[best].Latency:
+			best = len(results) - 1
+		}
+	}
+
+	backend := ""
+	if best > 0 {
+		backend = results[best].Backend
+	}
+
+	if probed.results == nil {
+		probed.results = make(map[Kind]probe)
+	}
+	probed.results[kind] = probe{backend: backend, results: results}
+
+	return backend, results
+}
+
+// probeBackend measures how long a wait of the named backend for file
+// overshoots its timeout. It reports whether input is pending instead. The
+// wait does not consume input.
+func probeBackend(file File, cfg *config, name string) (ProbeResult, bool) {
+	res := ProbeResult{Backend: name}
+
+	c := *cfg
+	c.backend = name
+
+	cr, err := openReader(file, &c)
+	if err != nil {
+		res.Err = err
+		return res, false
+	}
+	defer cr.Close()
+
+	p, ok := cr.(poller)
+	if !ok {
+		res.Err = fmt.Errorf("%s backend can't wait for input", name)
+		return res, false
+	}
+
+	start := time.Now()
+	ready, err := p.poll(probeTimeout)
+	elapsed := time.Since(start)
+
+	switch {
+	case err != nil:
+		res.Err = err
+	case ready:
+		return res, true
+	case elapsed < probeTimeout:
+		res.Err = errors.New("wait returned early")
+	default:
+		res.Latency = elapsed - probeTimeout
+	}
+
+	return res, false
+}
@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBackendProbe(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := NewReader(pr, WithBackendProbe())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	probes, ok := Probes(cr)
+	if !ok || len(probes) != len(Backends())-1 {
+		t.Fatalf("expected a probe for each backend but the fallback, but got %v", probes)
+	}
+
+	fastest := probes[0]
+	for _, p := range probes {
+		if p.Err != nil {
+			t.Errorf("expected %s to handle a pipe, but got %s", p.Backend, p.Err)
+		}
+		if p.Latency+probeMargin < fastest.Latency {
+			fastest = p
+		}
+	}
+	if Backend(cr) != fastest.Backend {
+		t.Errorf("expected the fastest backend %s, but got %s", fastest.Backend, Backend(cr))
+	}
+
+	if _, ok := Probes(Tee(cr)); !ok {
+		t.Errorf("expected Probes to see through Tee")
+	}
+
+	_, _ = pw.Write([]byte("x"))
+	again, err := NewReader(pr, WithBackendProbe())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer again.Close()
+
+	if cached, _ := Probes(again); len(cached) != len(probes) || cached[0] != probes[0] {
+		t.Errorf("expected the cached results despite pending input, but got %v", cached)
+	}
+}
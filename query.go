@@ -0,0 +1,75 @@
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrTimeout is returned by Query if the terminal did not respond in time.
+var ErrTimeout = errors.New("timeout")
+
+// Query writes query to the terminal w and returns everything read from r
+// until a CSI sequence ending with final (e.g. 'c' for the response to the
+// primary device attributes query ESC [ c) has been read. If no response
+// arrives within timeout, r is canceled and ErrTimeout is returned together
+// with the data read so far.
+//
+// Terminals that do not understand a query stay silent, so it is common to
+// append ESC [ c to the query and wait for its response, which every
+// terminal sends.
+func Query(r CancelReader, w io.Writer, query string, final byte, timeout time.Duration) ([]byte, error) {
+	timer := time.AfterFunc(timeout, func() { r.Cancel() })
+	defer timer.Stop()
+
+	if _, err := io.WriteString(w, query); err != nil {
+		return nil, fmt.Errorf("write query: %w", err)
+	}
+
+	var (
+		response []byte
+		buf      [256]byte
+	)
+
+	for {
+		n, err := r.Read(buf[:])
+		response = append(response, buf[:n]...)
+
+		if endsWithCSI(response, final) {
+			return response, nil
+		}
+
+		if errors.Is(err, ErrCanceled) && !timer.Stop() {
+			return response, ErrTimeout
+		}
+
+		if err != nil {
+			return response, err // nolint: wrapcheck
+		}
+	}
+}
+
+// endsWithCSI reports whether b contains a complete CSI sequence ending with
+// final.
+func endsWithCSI(b []byte, final byte) bool {
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] != 0x1b || b[i+1] != '[' {
+			continue
+		}
+
+		for j := i + 2; j < len(b); j++ {
+			if b[j] >= 0x40 && b[j] <= 0x7e {
+				if b[j] == final {
+					return true
+				}
+
+				i = j
+
+				break
+			}
+		}
+	}
+
+	return false
+}
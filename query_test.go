@@ -0,0 +1,80 @@
+package cancelreader
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	cr, err := newFallbackCancelReader(strings.NewReader("x\x1b[?62;22c"))
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	var w bytes.Buffer
+	response, err := Query(cr, &w, "\x1b[c", 'c', time.Second)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(response) != "x\x1b[?62;22c" {
+		t.Errorf("expected response %q, got %q", "x\x1b[?62;22c", response)
+	}
+	if w.String() != "\x1b[c" {
+		t.Errorf("expected query %q, got %q", "\x1b[c", w.String())
+	}
+}
+
+// chanReader is a CancelReader returning the chunks sent to it.
+type chanReader struct {
+	chunks   chan string
+	canceled chan struct{}
+}
+
+func newChanReader(chunks ...string) *chanReader {
+	r := &chanReader{chunks: make(chan string, len(chunks)), canceled: make(chan struct{})}
+	for _, chunk := range chunks {
+		r.chunks <- chunk
+	}
+
+	return r
+}
+
+func (r *chanReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.canceled:
+		return 0, ErrCanceled
+	default:
+	}
+
+	select {
+	case chunk := <-r.chunks:
+		return copy(p, chunk), nil
+	case <-r.canceled:
+		return 0, ErrCanceled
+	}
+}
+
+func (r *chanReader) Cancel() bool {
+	close(r.canceled)
+	return true
+}
+
+func (r *chanReader) Close() error {
+	return nil
+}
+
+func TestQueryTimeout(t *testing.T) {
+	cr := newChanReader("\x1b[1;1R")
+
+	response, err := Query(cr, ioutil.Discard, "\x1b[c", 'c', 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if string(response) != "\x1b[1;1R" {
+		t.Errorf("expected response %q, got %q", "\x1b[1;1R", response)
+	}
+}
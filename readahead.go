@@ -0,0 +1,210 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// readAheadReader keeps a Read of the backend outstanding, so that input is
+// usually buffered already when the application reads, see WithReadAhead.
+type readAheadReader struct {
+	CancelReader
+	size int
+
+	lock    sync.Mutex
+	buf     []byte
+	err     error         // returned once buf is empty
+	changed chan struct{} // closed and replaced when buf, err or room change
+	running bool
+	stopped chan struct{} // closed when the read-ahead stops
+}
+
+func newReadAheadReader(cr CancelReader, size int) *readAheadReader {
+	r := &readAheadReader{CancelReader: cr, size: size, changed: make(chan struct{})}
+	r.start()
+
+	return r
+}
+
+// start starts reading ahead. It must be called with the lock held or before
+// the reader is shared.
+func (r *readAheadReader) start() {
+	r.running = true
+	r.stopped = make(chan struct{})
+
+	go r.run(r.stopped)
+}
+
+// notify wakes up the waiters. It must be called with the lock held.
+func (r *readAheadReader) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// run reads into the buffer while it has room until the backend fails.
+func (r *readAheadReader) run(stopped chan struct{}) {
+	defer close(stopped)
+
+	tmp := make([]byte, r.size)
+
+	for {
+		r.lock.Lock()
+		for len(r.buf) >= r.size {
+			changed := r.changed
+			r.lock.Unlock()
+			<-changed
+			r.lock.Lock()
+		}
+		room := r.size - len(r.buf)
+		r.lock.Unlock()
+
+		n, err := r.CancelReader.Read(tmp[:room])
+
+		r.lock.Lock()
+		r.buf = append(r.buf, tmp[:n]...)
+		if err != nil {
+			r.err = err
+			r.running = false
+		}
+		r.notify()
+		r.lock.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *readAheadReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+// readContext returns the buffered input or waits for it. Input read ahead
+// before a cancelation is returned before ErrCanceled.
+func (r *readAheadReader) readContext(ctx context.Context, data []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for len(r.buf) == 0 && r.err == nil {
+		changed := r.changed
+		r.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			r.lock.Lock()
+			return 0, ctx.Err()
+		}
+
+		r.lock.Lock()
+	}
+
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(data, r.buf)
+	r.buf = r.buf[n:]
+	r.notify()
+
+	return n, nil
+}
+
+func (r *readAheadReader) poll(timeout time.Duration) (bool, error) {
+	expired := time.After(timeout)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for len(r.buf) == 0 && r.err == nil {
+		changed := r.changed
+		r.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-expired:
+			r.lock.Lock()
+			return false, nil
+		}
+
+		r.lock.Lock()
+	}
+
+	if len(r.buf) == 0 && errors.Is(r.err, ErrCanceled) {
+		return false, r.err
+	}
+
+	return true, nil
+}
+
+// pending returns the number of bytes read ahead and not returned yet.
+func (r *readAheadReader) pending() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.buf)
+}
+
+// buffered waits until the read-ahead stopped and returns the input read
+// ahead and not returned yet.
+func (r *readAheadReader) buffered() []byte {
+	r.lock.Lock()
+	stopped := r.stopped
+	r.lock.Unlock()
+	<-stopped
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	buf := r.buf
+	r.buf = nil
+	r.notify()
+
+	return buf
+}
+
+// reset makes the reader usable again after a cancelation once the
+// read-ahead stopped. The buffered input is kept.
+func (r *readAheadReader) reset() error {
+	r.lock.Lock()
+	stopped := r.stopped
+	r.lock.Unlock()
+	<-stopped
+
+	rs, ok := r.CancelReader.(resetter)
+	if !ok {
+		return errors.New("backend cannot be reset")
+	}
+
+	err := rs.reset()
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if errors.Is(r.err, ErrCanceled) {
+		r.err = nil
+		r.start()
+	}
+
+	return nil
+}
+
+// Close stops reading ahead before closing the backend. Input read ahead and
+// not returned yet is dropped; use Cancel and read until ErrCanceled to get
+// all of it.
+func (r *readAheadReader) Close() error {
+	r.lock.Lock()
+	running, stopped := r.running, r.stopped
+	r.lock.Unlock()
+
+	if running && r.CancelReader.Cancel() {
+		<-stopped
+	}
+
+	return r.CancelReader.Close()
+}
@@ -0,0 +1,62 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadAhead(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := NewReader(pr, WithReadAhead(64))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	ra := cr.(*infoReader).readAhead
+
+	_, _ = pw.Write([]byte("abc"))
+	if ready, err := ra.poll(time.Second); !ready || err != nil {
+		t.Fatalf("expected input to be read ahead, but got %v, %v", ready, err)
+	}
+
+	// the input is buffered, so the backend is not involved anymore
+	p := make([]byte, 2)
+	n, err := cr.Read(p)
+	if err != nil || string(p[:n]) != "ab" {
+		t.Errorf("expected %q, but got %q, %v", "ab", p[:n], err)
+	}
+
+	if !cr.Cancel() {
+		t.Errorf("expected Cancel to interrupt the outstanding read")
+	}
+
+	n, err = cr.Read(p)
+	if err != nil || string(p[:n]) != "c" {
+		t.Errorf("expected the input read ahead to survive the cancelation, but got %q, %v", p[:n], err)
+	}
+
+	if _, err := cr.Read(p); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	// nothing was taken from the pipe after the cancelation
+	_, _ = pw.Write([]byte("d"))
+	_ = cr.Close()
+
+	n, err = pr.Read(p)
+	if err != nil || string(p[:n]) != "d" {
+		t.Errorf("expected the input after the cancelation to stay in the pipe, but got %q, %v", p[:n], err)
+	}
+}
@@ -0,0 +1,100 @@
+package cancelreader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record returns a CancelReader that writes everything read from r to trace.
+// Every chunk is written as a line holding the microseconds since Record was
+// called and the Go-quoted data, so traces can be inspected and edited by
+// hand and replayed with Replay. Errors writing the trace do not affect
+// reading but are returned by Close, which also closes r.
+func Record(r CancelReader, trace io.Writer) CancelReader {
+	return &recordingReader{CancelReader: r, trace: trace, start: time.Now()}
+}
+
+type recordingReader struct {
+	CancelReader
+	trace io.Writer
+	start time.Time
+
+	lock sync.Mutex
+	err  error
+}
+
+func (r *recordingReader) Read(data []byte) (int, error) {
+	n, err := r.CancelReader.Read(data)
+	if n > 0 {
+		r.lock.Lock()
+		if r.err == nil {
+			_, r.err = fmt.Fprintf(r.trace, "%d %q\n", time.Since(r.start).Microseconds(), data[:n])
+		}
+		r.lock.Unlock()
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+func (r *recordingReader) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var traceErr error
+	if r.err != nil {
+		traceErr = fmt.Errorf("writing trace: %w", r.err)
+	}
+
+	return errors.Join(r.CancelReader.Close(), traceErr)
+}
+
+// Replay writes the data of a trace written by Record to dst. The original
+// timing is reproduced scaled by speed, so 2 replays twice as fast. If speed
+// is 0, the data is written as fast as possible.
+func Replay(dst io.Writer, trace io.Reader, speed float64) error {
+	start := time.Now()
+	scanner := bufio.NewScanner(trace)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+
+		i := strings.IndexByte(text, ' ')
+		if i < 0 {
+			return fmt.Errorf("trace line %d: missing data", line)
+		}
+
+		offset, err := strconv.ParseInt(text[:i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("trace line %d: parse offset: %w", line, err)
+		}
+
+		data, err := strconv.Unquote(text[i+1:])
+		if err != nil {
+			return fmt.Errorf("trace line %d: parse data: %w", line, err)
+		}
+
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(offset) * float64(time.Microsecond) / speed))
+			time.Sleep(time.Until(at))
+		}
+
+		if _, err = io.WriteString(dst, data); err != nil {
+			return fmt.Errorf("replay: %w", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read trace: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package cancelreader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	input := "hello\x1b[A\xff"
+
+	cr, err := newFallbackCancelReader(strings.NewReader(input))
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	var trace bytes.Buffer
+	recorded, err := ioutil.ReadAll(Record(cr, &trace))
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(recorded) != input {
+		t.Errorf("expected to read %q, got %q", input, string(recorded))
+	}
+
+	var replayed bytes.Buffer
+	err = Replay(&replayed, &trace, 0)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if replayed.String() != input {
+		t.Errorf("expected to replay %q, got %q", input, replayed.String())
+	}
+}
+
+func TestReplayInvalidTrace(t *testing.T) {
+	err := Replay(ioutil.Discard, strings.NewReader("12 \"ok\"\nbroken\n"), 0)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error for line 2, got %v", err)
+	}
+}
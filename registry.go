@@ -0,0 +1,145 @@
+package cancelreader
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+var registry struct {
+	lock     sync.Mutex
+	names    []string
+	backends map[string]func(File) (CancelReader, error)
+}
+
+// RegisterBackend adds an implementation named name, e.g. a native one for a
+// platform that only has the fallback backend. NewReader uses the backend
+// registered first for Files instead of the built-in ones, and any
+// registered backend when it is selected with WithBackend. Backends lists the
+// registered backends first. RegisterBackend panics if the name is taken.
+func RegisterBackend(name string, open func(file File) (CancelReader, error)) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	_, taken := registry.backends[name]
+	for _, builtin := range backends {
+		taken = taken || builtin == name
+	}
+
+	if taken || open == nil {
+		panic("cancelreader: RegisterBackend called twice or with nil open for backend " + name)
+	}
+
+	if registry.backends == nil {
+		registry.backends = make(map[string]func(File) (CancelReader, error))
+	}
+
+	registry.names = append(registry.names, name)
+	registry.backends[name] = open
+}
+
+// CanCancel reports whether Cancel can interrupt a Read of r that is
+// blocked waiting for input. It is false for the fallback backend, which is
+// used for readers that are not Files and on platforms without a native
+// backend.
+func CanCancel(r CancelReader) bool {
+	if info, ok := infoOf(r); ok {
+		return info.backend != backendFallback
+	}
+
+	_, fallback := r.(*fallbackCancelReader)
+
+	return !fallback
+}
+
+// openReader returns a reader of the backend selected by cfg, preferring the
+// registered ones.
+func openReader(reader io.Reader, cfg *config) (CancelReader, error) {
+	if cfg.poller != nil {
+		return openShared(reader, cfg)
+	}
+
+	if cfg.backend == backendDeadline {
+		return openDeadline(reader)
+	}
+
+	// connections that are no File are canceled with their read deadline
+	if conn, d, ok := connOf(reader); ok && cfg.backend == "" && conn == reader {
+		return newDeadlineReader(conn, d), nil
+	}
+
+	registry.lock.Lock()
+	name := cfg.backend
+	if name == "" && len(registry.names) > 0 {
+		name = registry.names[0]
+	}
+	open, ok := registry.backends[name]
+	registry.lock.Unlock()
+
+	if !ok {
+		return openPlatform(reader, cfg)
+	}
+
+	file, isFile := reader.(File)
+	switch {
+	case !isFile && cfg.backend != "":
+		return nil, errUnsupportedInput(name, reader)
+	case !isFile:
+		return openPlatform(reader, cfg)
+	}
+
+	cr, err := open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &registeredReader{CancelReader: cr, name: name}, nil
+}
+
+// openPlatform returns a reader of the platform backend selected by cfg. A
+// connection the platform backend can only read with the fallback one is
+// canceled with its read deadline instead.
+func openPlatform(reader io.Reader, cfg *config) (CancelReader, error) {
+	cr, err := newReader(reader, cfg)
+	if err != nil || cfg.backend != "" || backendOf(cr) != backendFallback {
+		return cr, err
+	}
+
+	conn, d, ok := connOf(reader)
+	if !ok {
+		return cr, nil
+	}
+
+	err = cr.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeadlineReader(conn, d), nil
+}
+
+// registeredBackends returns the names of the registered backends.
+func registeredBackends() []string {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return append([]string(nil), registry.names...)
+}
+
+// registeredReader names the reader of a registered backend.
+type registeredReader struct {
+	CancelReader
+	name string
+}
+
+func (r *registeredReader) readContext(ctx context.Context, data []byte) (int, error) {
+	return readContext(ctx, r.CancelReader, data)
+}
+
+func (r *registeredReader) backendName() string {
+	return r.name
+}
+
+func (r *registeredReader) unwrap() CancelReader {
+	return r.CancelReader
+}
@@ -0,0 +1,72 @@
+package cancelreader
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRegisterBackend(t *testing.T) {
+	defer func(names []string, backends map[string]func(File) (CancelReader, error)) {
+		registry.names, registry.backends = names, backends
+	}(registry.names, registry.backends)
+
+	registry.names, registry.backends = nil, nil
+
+	opened := 0
+	open := func(file File) (CancelReader, error) {
+		opened++
+		return newFallbackCancelReader(file)
+	}
+	RegisterBackend("test", open)
+
+	if backends := Backends(); backends[0] != "test" {
+		t.Errorf("expected the registered backend first, but got %q", backends)
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if opened != 1 || Backend(r) != "test" {
+		t.Errorf("expected the test backend, but got %q", Backend(r))
+	}
+
+	if _, err = NewReader(strings.NewReader(""), WithBackend("test")); err == nil {
+		t.Errorf("expected an error for a non-file input")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected registering a taken name to panic")
+			}
+		}()
+
+		RegisterBackend(backendFallback, open)
+	}()
+}
+
+func TestCanCancel(t *testing.T) {
+	r, err := NewReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if CanCancel(r) {
+		t.Errorf("expected the fallback backend not to cancel reads")
+	}
+
+	if !CanCancel(NewChannelReader(strings.NewReader(""), nil)) {
+		t.Errorf("expected a channel reader to cancel reads")
+	}
+}
@@ -0,0 +1,145 @@
+package cancelreader
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// remapEscWait is how long the remapping waits for the rest of an escape
+// sequence, see WithEscTimeout.
+const remapEscWait = 25 * time.Millisecond
+
+// Bytes returns the xterm encoding of the key, which a Decoder decodes to
+// the same KeyEvent. Keys without an encoding, like Ctrl with keys other than
+// letters, return nil.
+func (e KeyEvent) Bytes() []byte {
+	// Alt is sent as an ESC prefix for keys encoded as a single character
+	simple := e.Key == KeyRune || e.Key == KeyEnter || e.Key == KeyBackspace || e.Key == KeyTab && e.Mod == ModAlt
+	if e.Mod&ModAlt != 0 && simple {
+		plain := e
+		plain.Mod &^= ModAlt
+
+		if b := plain.Bytes(); b != nil {
+			return append([]byte{0x1b}, b...)
+		}
+
+		return nil
+	}
+
+	switch e.Key {
+	case KeyRune:
+		return runeBytes(e.Rune, e.Mod)
+	case KeyEscape:
+		return []byte{0x1b}
+	case KeyEnter:
+		return []byte{'\r'}
+	case KeyTab:
+		if e.Mod == ModShift {
+			return []byte("\x1b[Z")
+		}
+
+		return []byte{'\t'}
+	case KeyBackspace:
+		return []byte{0x7f}
+	}
+
+	for final, key := range csiFinals {
+		if key != e.Key {
+			continue
+		}
+
+		if e.Mod == 0 {
+			if e.Key >= KeyF1 {
+				return []byte{0x1b, 'O', final}
+			}
+
+			return []byte{0x1b, '[', final}
+		}
+
+		return []byte("\x1b[1;" + strconv.Itoa(int(e.Mod)+1) + string(final))
+	}
+
+	for n, key := range csiTildes {
+		// Home and End have a CSI final of their own
+		if key != e.Key || n == 1 || n == 4 || n == 7 || n == 8 {
+			continue
+		}
+
+		if e.Mod == 0 {
+			return []byte("\x1b[" + strconv.Itoa(n) + "~")
+		}
+
+		return []byte("\x1b[" + strconv.Itoa(n) + ";" + strconv.Itoa(int(e.Mod)+1) + "~")
+	}
+
+	return nil
+}
+
+// runeBytes encodes a character with Ctrl or no modifiers.
+func runeBytes(r rune, mod Modifiers) []byte {
+	switch {
+	case mod == 0:
+		return []byte(string(r))
+	case mod != ModCtrl:
+		return nil
+	case r == ' ':
+		return []byte{0}
+	case r >= 'a' && r <= 'a'+0x1e:
+		return []byte{byte(r - 'a' + 1)}
+	}
+
+	return nil
+}
+
+// remapReader passes the key events of the input through a remapping
+// function, see WithKeyRemap.
+type remapReader struct {
+	CancelReader
+	d     *Decoder
+	remap func(KeyEvent) KeyEvent
+
+	out []byte
+}
+
+func newRemapReader(cr CancelReader, remap func(KeyEvent) KeyEvent) *remapReader {
+	return &remapReader{CancelReader: cr, d: NewDecoder(cr, WithEscTimeout(remapEscWait)), remap: remap}
+}
+
+func (r *remapReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *remapReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for len(r.out) == 0 {
+		ev, raw, err := r.d.readRaw(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		key, ok := ev.(KeyEvent)
+		if !ok {
+			r.out = append(r.out, raw...)
+			continue
+		}
+
+		mapped := r.remap(key)
+		if b := mapped.Bytes(); mapped != key && b != nil {
+			raw = b
+		}
+		r.out = append(r.out, raw...)
+	}
+
+	n := copy(data, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *remapReader) poll(timeout time.Duration) (bool, error) {
+	if len(r.out) > 0 || len(r.d.buf) > 0 {
+		return true, nil
+	}
+
+	return pollReader(r.CancelReader, timeout)
+}
@@ -0,0 +1,53 @@
+package cancelreader
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestKeyEventBytes(t *testing.T) {
+	for _, key := range []KeyEvent{
+		{Key: KeyRune, Rune: 'a'},
+		{Key: KeyRune, Rune: 'ж', Mod: ModAlt},
+		{Key: KeyRune, Rune: 'c', Mod: ModCtrl},
+		{Key: KeyEnter},
+		{Key: KeyTab, Mod: ModShift},
+		{Key: KeyUp},
+		{Key: KeyUp, Mod: ModAlt},
+		{Key: KeyF1},
+		{Key: KeyF1, Mod: ModCtrl},
+		{Key: KeyDelete, Mod: ModShift},
+	} {
+		b := key.Bytes()
+
+		ev, n := decodeEvent(b, true)
+		if n != len(b) || ev != key {
+			t.Errorf("expected %q to decode to %v, but got %v after %d bytes", b, key, ev, n)
+		}
+	}
+}
+
+func TestKeyRemap(t *testing.T) {
+	swap := func(e KeyEvent) KeyEvent {
+		if e.Key == KeyRune && e.Mod == 0 {
+			switch e.Rune {
+			case 'a':
+				e.Rune = 'b'
+			case 'b':
+				e.Rune = 'a'
+			}
+		}
+
+		return e
+	}
+
+	cr := newRemapReader(&pollingCancelReader{pollingChunkReader{chunkReader{"ab\x1b[", "A", "\x1bac"}}}, swap)
+
+	data, err := ioutil.ReadAll(cr)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if expected := "ba\x1b[A\x1bac"; string(data) != expected {
+		t.Errorf("expected %q, but got %q", expected, data)
+	}
+}
@@ -0,0 +1,98 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExportCancel sends the cancel endpoint of a CancelReader returned by
+// NewReader over a unix socket, so that the process on the other end, e.g. a
+// supervisor, can cancel its pending Read with ReceiveCancel. The fallback
+// backend has no cancel endpoint.
+func ExportCancel(r CancelReader, conn *net.UnixConn) error {
+	info, ok := infoOf(r)
+	if !ok {
+		return fmt.Errorf("export cancel: %T was not returned by NewReader", r)
+	}
+
+	s, ok := info.base.(interface{ cancelSignal() File })
+	if !ok {
+		return fmt.Errorf("export cancel: backend %s has no cancel endpoint", info.backend)
+	}
+
+	_, _, err := conn.WriteMsgUnix([]byte{0}, unix.UnixRights(int(s.cancelSignal().Fd())), nil)
+	if err != nil {
+		return fmt.Errorf("export cancel: %w", err)
+	}
+
+	return nil
+}
+
+// RemoteCancel cancels a CancelReader of another process, see ExportCancel.
+type RemoteCancel struct {
+	f *os.File
+}
+
+// maxReceivedFds is how many descriptors ReceiveCancel takes from a message.
+const maxReceivedFds = 8
+
+// ReceiveCancel receives a cancel endpoint sent by ExportCancel.
+func ReceiveCancel(conn *net.UnixConn) (*RemoteCancel, error) {
+	var b [1]byte
+
+	// room for more descriptors than expected, which are closed below
+	oob := make([]byte, unix.CmsgSpace(4*maxReceivedFds))
+
+	_, oobn, _, _, err := conn.ReadMsgUnix(b[:], oob)
+	if err != nil {
+		return nil, fmt.Errorf("receive cancel: %w", err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("receive cancel: %w", err)
+	}
+
+	// take the first message carrying a single descriptor and close all
+	// others, so that unexpected ones don't leak
+	var c *RemoteCancel
+	for _, msg := range msgs {
+		fds, err := unix.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+
+		if c == nil && len(fds) == 1 {
+			c = &RemoteCancel{f: os.NewFile(uintptr(fds[0]), "cancel signal")}
+			continue
+		}
+
+		for _, fd := range fds {
+			_ = unix.Close(fd)
+		}
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("receive cancel: no cancel endpoint received")
+	}
+
+	return c, nil
+}
+
+// Cancel cancels the pending and future Reads of the remote reader and
+// returns true if the cancel signal was sent.
+func (c *RemoteCancel) Cancel() bool {
+	_, err := c.f.Write([]byte{remoteSignal})
+	return err == nil
+}
+
+// Close releases the cancel endpoint.
+func (c *RemoteCancel) Close() error {
+	return c.f.Close()
+}
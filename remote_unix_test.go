@@ -0,0 +1,114 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRemoteCancel(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socket")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer c.Close()
+		conns[i] = c.(*net.UnixConn)
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+	defer pr.Close()
+
+	cr, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	if err := ExportCancel(cr, conns[0]); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	remote, err := ReceiveCancel(conns[1])
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer remote.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cr.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	if !remote.Cancel() {
+		t.Errorf("expected cancellation to be success")
+	}
+
+	select {
+	case err = <-done:
+		if err != ErrCanceled {
+			t.Errorf("expected cancel error but got %s", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("expected cancellation to unblock reader")
+	}
+}
+
+func TestReceiveCancelClosesExtraFds(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "local")
+	defer local.Close()
+
+	f := os.NewFile(uintptr(fds[1]), "remote")
+	c, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer c.Close()
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	open, _ := os.ReadDir("/dev/fd")
+
+	// two descriptors in one message are no cancel endpoint
+	rights := unix.UnixRights(int(pr.Fd()), int(pw.Fd()))
+	if err := unix.Sendmsg(int(local.Fd()), []byte{0}, rights, nil, 0); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if _, err := ReceiveCancel(c.(*net.UnixConn)); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	if after, _ := os.ReadDir("/dev/fd"); len(after) != len(open) {
+		t.Errorf("expected %d open files, but got %d", len(open), len(after))
+	}
+}
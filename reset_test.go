@@ -0,0 +1,116 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReset(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	CancelWithError(r, errors.New("suspended"))
+
+	data := make([]byte, 1)
+	if _, err := r.Read(data); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	if err := Reset(r); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	n, err := r.Read(data)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data[:n]) != "a" {
+		t.Errorf("expected %q, but got %q", "a", data[:n])
+	}
+}
+
+func TestCancelOnClose(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr, WithCancelOnClose())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := r.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrCanceled) {
+			t.Errorf("expected ErrCanceled, but got %v", err)
+		}
+	default:
+		t.Errorf("expected Close to wait for the pending Read")
+	}
+}
+
+func TestDupFd(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	r, err := NewReader(pr, WithDupFd())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	dup := r.(*infoReader).input
+	if flags, err := unix.FcntlInt(dup.Fd(), unix.F_GETFD, 0); err != nil || flags&unix.FD_CLOEXEC == 0 {
+		t.Errorf("expected the duplicate to be closed on exec, but got %#x, %v", flags, err)
+	}
+
+	// the reader keeps working after the original is closed
+	if err := pr.Close(); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	data := make([]byte, 1)
+	if _, err := r.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+}
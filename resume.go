@@ -0,0 +1,175 @@
+package cancelreader
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// resumeCheckInterval is how often the clocks are compared to detect a
+	// resume from sleep.
+	resumeCheckInterval = 2 * time.Second
+
+	// resumeThreshold is how far the wall clock has to get ahead of the
+	// monotonic clock to be taken for a suspension.
+	resumeThreshold = time.Second
+)
+
+// resumable is implemented by backends that have to re-validate their poller
+// registrations or console handles after the system resumed from sleep.
+type resumable interface {
+	// resumed is called concurrently with Read. It marks the reader for
+	// re-validation and wakes up a waiting Read to do it.
+	resumed()
+}
+
+// resumeFlag is set when the system resumed and taken by the Read that
+// re-validates the reader.
+type resumeFlag struct {
+	set int32
+}
+
+func (f *resumeFlag) mark() {
+	atomic.StoreInt32(&f.set, 1)
+}
+
+func (f *resumeFlag) take() bool {
+	return atomic.CompareAndSwapInt32(&f.set, 1, 0)
+}
+
+// Health is an event in the life of a reader other than input, see
+// WithHealthEvents.
+type Health int
+
+const (
+	// HealthResumed means that the system resumed from sleep.
+	HealthResumed Health = iota
+	// HealthReconnected means that the remote desktop or console session of
+	// the process was connected again.
+	HealthReconnected
+)
+
+func (h Health) String() string {
+	switch h {
+	case HealthResumed:
+		return "resumed"
+	case HealthReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// resumeWatcher notifies the registered readers after a resume. It runs while
+// readers are registered.
+var resumeWatcher struct {
+	lock    sync.Mutex
+	readers map[*func(Health)]struct{}
+	stop    chan struct{}
+}
+
+// watchResume registers fn for resume notifications and returns a function
+// to unregister it.
+func watchResume(fn func(Health)) func() {
+	resumeWatcher.lock.Lock()
+	defer resumeWatcher.lock.Unlock()
+
+	if resumeWatcher.readers == nil {
+		resumeWatcher.readers = map[*func(Health)]struct{}{}
+		resumeWatcher.stop = make(chan struct{})
+		go detectResume(resumeWatcher.stop)
+	}
+	key := &fn
+	resumeWatcher.readers[key] = struct{}{}
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			resumeWatcher.lock.Lock()
+			defer resumeWatcher.lock.Unlock()
+
+			delete(resumeWatcher.readers, key)
+			if len(resumeWatcher.readers) == 0 {
+				close(resumeWatcher.stop)
+				resumeWatcher.readers = nil
+			}
+		})
+	}
+}
+
+// maxHealthHistory is how many Health events are kept for Diagnose.
+const maxHealthHistory = 8
+
+var healthHistory struct {
+	lock   sync.Mutex
+	events []HealthEvent
+}
+
+// recentHealth returns the most recent Health events, the oldest first.
+func recentHealth() []HealthEvent {
+	healthHistory.lock.Lock()
+	defer healthHistory.lock.Unlock()
+
+	return append([]HealthEvent(nil), healthHistory.events...)
+}
+
+// notifyResumed notifies the registered readers.
+func notifyResumed(h Health) {
+	healthHistory.lock.Lock()
+	healthHistory.events = append(healthHistory.events, HealthEvent{Time: time.Now(), Health: h})
+	if len(healthHistory.events) > maxHealthHistory {
+		healthHistory.events = healthHistory.events[1:]
+	}
+	healthHistory.lock.Unlock()
+
+	resumeWatcher.lock.Lock()
+	defer resumeWatcher.lock.Unlock()
+
+	for fn := range resumeWatcher.readers {
+		(*fn)(h)
+	}
+}
+
+// resumed re-validates the backend and reports h.
+func (r *infoReader) resumed(h Health) {
+	if res, ok := r.base.(resumable); ok {
+		res.resumed()
+	}
+
+	if r.healthEvents != nil {
+		select {
+		case r.healthEvents <- h:
+		default:
+		}
+	}
+}
+
+// detectResume detects resumes until stop is closed. The monotonic clock does
+// not advance while the system is suspended on most platforms, while the wall
+// clock does, so a resume shows up as a jump of the wall clock. Platforms
+// that report power events do so additionally.
+func detectResume(stop <-chan struct{}) {
+	unregister := platformResumeEvents()
+	defer unregister()
+
+	ticker := time.NewTicker(resumeCheckInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if now.Round(0).Sub(last.Round(0))-now.Sub(last) > resumeThreshold {
+			notifyResumed(HealthResumed)
+		}
+		last = now
+	}
+}
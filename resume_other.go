@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package cancelreader
+
+// platformResumeEvents does nothing, resumes are detected by the clocks.
+func platformResumeEvents() func() {
+	return func() {}
+}
@@ -0,0 +1,24 @@
+package cancelreader
+
+import "testing"
+
+func TestWatchResume(t *testing.T) {
+	var calls []Health
+
+	unregister := watchResume(func(h Health) { calls = append(calls, h) })
+	notifyResumed(HealthReconnected)
+	unregister()
+	unregister()
+	notifyResumed(HealthResumed)
+
+	if len(calls) != 1 || calls[0] != HealthReconnected {
+		t.Errorf("expected 1 reconnected notification, got %v", calls)
+	}
+
+	resumeWatcher.lock.Lock()
+	defer resumeWatcher.lock.Unlock()
+
+	if resumeWatcher.readers != nil {
+		t.Errorf("expected the watcher to stop without readers")
+	}
+}
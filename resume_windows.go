@@ -0,0 +1,74 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	deviceNotifyCallback  = 2
+	pbtAPMResumeSuspend   = 0x7
+	pbtAPMResumeAutomatic = 0x12
+)
+
+var (
+	modpowrprof                                  = windows.NewLazySystemDLL("powrprof.dll")
+	procPowerRegisterSuspendResumeNotification   = modpowrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotification = modpowrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+)
+
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+// powerCallback is created once since callbacks can't be freed.
+var powerCallback = syscall.NewCallback(func(context, typ, setting uintptr) uintptr {
+	if typ == pbtAPMResumeSuspend || typ == pbtAPMResumeAutomatic {
+		notifyResumed(HealthResumed)
+	}
+
+	return 0
+})
+
+// powerParams is kept alive while the callback is registered.
+var powerParams = &deviceNotifySubscribeParameters{}
+
+// platformResumeEvents notifies the readers on the power events of a resume
+// from sleep and on reconnects of the session.
+func platformResumeEvents() func() {
+	stopPower := watchPower()
+	stopSessions := watchSessions()
+
+	return func() {
+		stopSessions()
+		stopPower()
+	}
+}
+
+// watchPower reports the power events of a resume from sleep. It does nothing
+// before Windows 8.
+func watchPower() func() {
+	if procPowerRegisterSuspendResumeNotification.Find() != nil {
+		return func() {}
+	}
+
+	powerParams.callback = powerCallback
+
+	var handle uintptr
+
+	r, _, _ := syscall.Syscall(procPowerRegisterSuspendResumeNotification.Addr(), 3,
+		deviceNotifyCallback, uintptr(unsafe.Pointer(powerParams)), uintptr(unsafe.Pointer(&handle)))
+	if r != 0 {
+		return func() {}
+	}
+
+	return func() {
+		_, _, _ = syscall.Syscall(procPowerUnregisterSuspendResumeNotification.Addr(), 1, handle, 0, 0)
+	}
+}
@@ -0,0 +1,41 @@
+package cancelreader
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// ReadRune implements io.RuneReader. A UTF-8 sequence split across reads of
+// the backend is reassembled; if ReadRune is canceled or times out in the
+// middle of one, the bytes read so far are kept for the next call. Invalid
+// input, including an incomplete sequence at the end of the input, is
+// returned as utf8.RuneError of size 1, as by bufio.Reader.
+func (r *infoReader) ReadRune() (rune, int, error) {
+	buf := make([]byte, 0, utf8.UTFMax)
+
+	for !utf8.FullRune(buf) {
+		b, err := r.ReadByte()
+		if err != nil && (len(buf) == 0 || interrupted(err)) {
+			r.unreadBytes(buf)
+			return 0, 0, err
+		}
+		if err != nil {
+			r.unreadBytes(buf[1:])
+			return utf8.RuneError, 1, nil
+		}
+
+		buf = append(buf, b)
+	}
+
+	c, size := utf8.DecodeRune(buf)
+	r.unreadBytes(buf[size:])
+
+	return c, size, nil
+}
+
+// interrupted reports whether err ended a Read before the input did, so that
+// it may be retried.
+func interrupted(err error) bool {
+	return errors.Is(err, ErrCanceled) || errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrWouldBlock) || contextDone(err) || isTimeout(err)
+}
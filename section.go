@@ -0,0 +1,135 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// sectionChunk is how much a SectionReader reads at once, so that Cancel and
+// deadlines take effect within large reads.
+const sectionChunk = 64 << 10
+
+var errNegativeOffset = errors.New("section reader: negative offset")
+
+// SectionReader is the CancelReader equivalent of io.SectionReader: it reads
+// the n bytes at offset off of a seekable input like a large file redirected
+// to stdin, without reading what comes before. Reads of regular files don't
+// block, so Cancel and the context of ReadContext interrupt a Read between
+// chunks of 64 KiB.
+type SectionReader struct {
+	ra    io.ReaderAt
+	base  int64
+	off   int64
+	limit int64
+	cancelMixin
+}
+
+// NewSectionReader returns a SectionReader reading from ra, e.g. os.Stdin,
+// starting at offset off and stopping with io.EOF after n bytes.
+func NewSectionReader(ra io.ReaderAt, off, n int64) *SectionReader {
+	return &SectionReader{ra: ra, base: off, off: off, limit: off + n}
+}
+
+func (s *SectionReader) Read(data []byte) (int, error) {
+	return s.ReadContext(context.Background(), data)
+}
+
+// ReadContext implements ContextReader.
+func (s *SectionReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n, err := s.readAt(ctx, data, s.off)
+	s.off += int64(n)
+
+	return n, err
+}
+
+// ReadAt reads like io.SectionReader.ReadAt, with off relative to the start
+// of the section. A read cut short by the end of the section returns io.EOF.
+func (s *SectionReader) ReadAt(data []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	n, err := s.readAt(context.Background(), data, s.base+off)
+	if err == nil && n < len(data) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// readAt reads data at the absolute offset off in chunks, checking for
+// cancelation before each one.
+func (s *SectionReader) readAt(ctx context.Context, data []byte, off int64) (int, error) {
+	if off >= s.limit {
+		return 0, io.EOF
+	}
+
+	if max := s.limit - off; int64(len(data)) > max {
+		data = data[:max]
+	}
+
+	n := 0
+	for n < len(data) {
+		if s.isCanceled() {
+			return n, ErrCanceled
+		}
+
+		err := contextErr(ctx)
+		if err != nil {
+			return n, err
+		}
+
+		end := n + sectionChunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		m, err := s.ra.ReadAt(data[n:end], off+int64(n))
+		n += m
+
+		if err != nil {
+			return n, err // nolint: wrapcheck
+		}
+	}
+
+	return n, nil
+}
+
+// Seek implements io.Seeker within the section.
+func (s *SectionReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		offset += s.base
+	case io.SeekCurrent:
+		offset += s.off
+	case io.SeekEnd:
+		offset += s.limit
+	default:
+		return 0, errors.New("section reader: invalid whence")
+	}
+
+	if offset < s.base {
+		return 0, errNegativeOffset
+	}
+	s.off = offset
+
+	return offset - s.base, nil
+}
+
+// Size returns the size of the section in bytes.
+func (s *SectionReader) Size() int64 {
+	return s.limit - s.base
+}
+
+// Cancel cancels the pending Read at the next chunk and all future Reads. It
+// always succeeds.
+func (s *SectionReader) Cancel() bool {
+	s.setCanceled()
+	return true
+}
+
+// Close does nothing, as the input is owned by the caller.
+func (s *SectionReader) Close() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSectionReader(t *testing.T) {
+	s := NewSectionReader(strings.NewReader("0123456789"), 2, 5)
+
+	data, err := ioutil.ReadAll(s)
+	if err != nil || string(data) != "23456" {
+		t.Errorf("expected %q, but got %q, %v", "23456", data, err)
+	}
+
+	if off, err := s.Seek(-2, io.SeekEnd); err != nil || off != 3 {
+		t.Errorf("expected offset 3, but got %d, %v", off, err)
+	}
+
+	p := make([]byte, 4)
+	n, err := s.ReadAt(p, 1)
+	if err != nil || string(p[:n]) != "3456" {
+		t.Errorf("expected %q, but got %q, %v", "3456", p[:n], err)
+	}
+
+	// across the end of the section
+	n, err = s.ReadAt(p, 3)
+	if err != io.EOF || string(p[:n]) != "56" {
+		t.Errorf("expected %q and EOF, but got %q, %v", "56", p[:n], err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.ReadContext(ctx, p); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, but got %v", err)
+	}
+
+	if !s.Cancel() {
+		t.Errorf("expected Cancel to succeed")
+	}
+	if _, err := s.Read(p); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
+
+// cancelingReaderAt cancels a SectionReader during its first ReadAt.
+type cancelingReaderAt struct {
+	s     *SectionReader
+	calls int
+}
+
+func (r *cancelingReaderAt) ReadAt(p []byte, _ int64) (int, error) {
+	r.calls++
+	r.s.Cancel()
+
+	return len(p), nil
+}
+
+func TestSectionReaderCancelBetweenChunks(t *testing.T) {
+	ra := &cancelingReaderAt{}
+	ra.s = NewSectionReader(ra, 0, 4*sectionChunk)
+
+	n, err := ra.s.Read(make([]byte, 4*sectionChunk))
+	if !errors.Is(err, ErrCanceled) || n != sectionChunk || ra.calls != 1 {
+		t.Errorf("expected the Read to stop after the first chunk, but got %d bytes in %d calls, %v", n, ra.calls, err)
+	}
+}
@@ -0,0 +1,56 @@
+package cancelreader
+
+import (
+	"errors"
+	"time"
+)
+
+// selectInterval is how often Select checks readers it can't wait for with
+// a single system call.
+const selectInterval = 10 * time.Millisecond
+
+// Select waits until one of readers has input or is canceled and returns its
+// index, along with ErrCanceled if it was canceled. It waits forever if
+// timeout is negative and returns ErrTimeout once timeout passed.
+//
+// Readers returned by NewReader for Files on unix are waited for with a
+// single poll system call. Other readers that can poll, see Poll, are checked
+// every 10ms. Readers that can't poll, like the fallback backend, fail.
+func Select(readers []CancelReader, timeout time.Duration) (int, error) {
+	if len(readers) == 0 {
+		return -1, errors.New("select: no readers")
+	}
+
+	var deadline time.Time
+	if timeout >= 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		for i, r := range readers {
+			ready, err := Poll(r, 0)
+			if ready || err != nil {
+				return i, err
+			}
+		}
+
+		wait := selectInterval
+		if timeout >= 0 {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return -1, ErrTimeout
+			}
+
+			if left < wait {
+				wait = left
+			}
+		}
+
+		i, ok, err := selectFiles(readers, timeout, deadline)
+		if ok {
+			return i, err
+		}
+
+		time.Sleep(wait)
+	}
+}
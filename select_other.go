@@ -0,0 +1,12 @@
+//go:build !linux && !solaris && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!solaris,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package cancelreader
+
+import "time"
+
+// selectFiles returns false as readers are only polled one by one on this
+// platform.
+func selectFiles([]CancelReader, time.Duration, time.Time) (int, bool, error) {
+	return 0, false, nil
+}
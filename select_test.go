@@ -0,0 +1,87 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSelect(t *testing.T) {
+	readers := make([]CancelReader, 2)
+	writers := make([]*os.File, 2)
+	for i := range readers {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer pw.Close()
+
+		r, err := NewReader(pr)
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		defer r.Close()
+
+		readers[i], writers[i] = r, pw
+	}
+
+	if _, err := Select(readers, 20*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, but got %v", err)
+	}
+
+	if _, err := writers[1].Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if i, err := Select(readers, -1); i != 1 || err != nil {
+		t.Errorf("expected reader 1, but got %d, %v", i, err)
+	}
+	if _, err := readers[1].Read(make([]byte, 1)); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		readers[0].Cancel()
+	}()
+	if i, err := Select(readers, time.Second); i != 0 || !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected reader 0 to be canceled, but got %d, %v", i, err)
+	}
+}
+
+func TestSelectReadableWithoutInput(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pw.Close()
+
+	// the input is readable, but the reader may not read it
+	r, err := NewReader(pr, WithReadCredit(0))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer r.Close()
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Select([]CancelReader{r}, 300*time.Millisecond)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("expected ErrTimeout, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Select to time out")
+	}
+}
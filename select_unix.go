@@ -0,0 +1,93 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// selectFiles waits for the inputs and cancel signals of readers with a
+// single poll system call. It returns false if a reader can't be waited for
+// this way, e.g. because another goroutine reads ahead from its input.
+func selectFiles(readers []CancelReader, timeout time.Duration, deadline time.Time) (int, bool, error) {
+	fds := make([]unix.PollFd, 0, 2*len(readers))
+	inputs := make([]uintptr, 0, len(readers))
+
+	for _, r := range readers {
+		info, ok := infoOf(r)
+		if !ok || info.input == nil || info.readAhead != nil {
+			return 0, false, nil
+		}
+
+		s, ok := info.base.(interface{ cancelSignalSource() File })
+		if !ok {
+			return 0, false, nil
+		}
+
+		inputs = append(inputs, info.input.Fd())
+		fds = append(fds,
+			unix.PollFd{Fd: int32(info.input.Fd()), Events: unix.POLLIN},
+			unix.PollFd{Fd: int32(s.cancelSignalSource().Fd()), Events: unix.POLLIN})
+	}
+
+	// inputs that are readable while their reader has no input, e.g. one
+	// out of read credit, are left out of the next poll and checked again
+	// after selectInterval instead of spinning
+	skipped := false
+
+	for {
+		ms := -1
+		if timeout >= 0 {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return -1, true, ErrTimeout
+			}
+
+			// round up so that short timeouts don't spin
+			ms = int((left + time.Millisecond - 1) / time.Millisecond)
+		}
+
+		if skipped && (ms < 0 || ms > int(selectInterval/time.Millisecond)) {
+			ms = int(selectInterval / time.Millisecond)
+		}
+
+		n, err := unix.Poll(fds, ms)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return -1, true, fmt.Errorf("select: poll: %w", err)
+		}
+
+		if n == 0 && !skipped {
+			return -1, true, ErrTimeout
+		}
+
+		// let the reader consume its cancel signal, which may be stale
+		skipped = false
+		for i, r := range readers {
+			input := fds[2*i].Revents != 0
+			fds[2*i].Fd = int32(inputs[i])
+
+			if !input && fds[2*i+1].Revents == 0 && n > 0 {
+				continue
+			}
+
+			ready, err := Poll(r, 0)
+			if ready || err != nil {
+				return i, true, err
+			}
+
+			if input {
+				fds[2*i].Fd = -1
+				skipped = true
+			}
+		}
+	}
+}
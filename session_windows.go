@@ -0,0 +1,169 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	wmClose              = 0x0010
+	wmDestroy            = 0x0002
+	wmWTSSessionChange   = 0x02b1
+	wtsConsoleConnect    = 0x1
+	wtsRemoteConnect     = 0x3
+	wtsSessionUnlock     = 0x8
+	notifyForThisSession = 0
+	hwndMessage          = ^uintptr(2) // (HWND)-3
+)
+
+var (
+	moduser32                            = windows.NewLazySystemDLL("user32.dll")
+	procRegisterClassExW                 = moduser32.NewProc("RegisterClassExW")
+	procCreateWindowExW                  = moduser32.NewProc("CreateWindowExW")
+	procDestroyWindow                    = moduser32.NewProc("DestroyWindow")
+	procDefWindowProcW                   = moduser32.NewProc("DefWindowProcW")
+	procGetMessageW                      = moduser32.NewProc("GetMessageW")
+	procDispatchMessageW                 = moduser32.NewProc("DispatchMessageW")
+	procPostMessageW                     = moduser32.NewProc("PostMessageW")
+	procPostQuitMessage                  = moduser32.NewProc("PostQuitMessage")
+	modwtsapi32                          = windows.NewLazySystemDLL("wtsapi32.dll")
+	procWTSRegisterSessionNotification   = modwtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSessionNotification = modwtsapi32.NewProc("WTSUnRegisterSessionNotification")
+)
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   windows.Handle
+	icon       windows.Handle
+	cursor     windows.Handle
+	background windows.Handle
+	menuName   *uint16
+	className  *uint16
+	iconSm     windows.Handle
+}
+
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	x, y    int32
+	private uint32
+}
+
+var (
+	sessionClass     *uint16
+	sessionClassOnce sync.Once
+	sessionClassErr  error
+)
+
+// sessionWndProc handles the messages of the hidden session window. It is
+// created once since callbacks can't be freed.
+var sessionWndProc = syscall.NewCallback(func(hwnd, msg, wParam, lParam uintptr) uintptr {
+	switch msg {
+	case wmWTSSessionChange:
+		switch wParam {
+		case wtsConsoleConnect, wtsRemoteConnect, wtsSessionUnlock:
+			reapplyConsoleSessions()
+			notifyResumed(HealthReconnected)
+		}
+
+		return 0
+	case wmDestroy:
+		_, _, _ = syscall.Syscall(procPostQuitMessage.Addr(), 1, 0, 0, 0)
+		return 0
+	}
+
+	r, _, _ := syscall.Syscall6(procDefWindowProcW.Addr(), 4, hwnd, msg, wParam, lParam, 0, 0)
+
+	return r
+})
+
+func registerSessionClass() error {
+	sessionClassOnce.Do(func() {
+		name, _ := windows.UTF16PtrFromString("cancelreaderSessionWatcher")
+		wc := wndClassEx{wndProc: sessionWndProc, className: name}
+		wc.size = uint32(unsafe.Sizeof(wc))
+
+		r, _, e := syscall.Syscall(procRegisterClassExW.Addr(), 1, uintptr(unsafe.Pointer(&wc)), 0, 0)
+		if r == 0 {
+			sessionClassErr = error(e)
+			return
+		}
+
+		sessionClass = name
+	})
+
+	return sessionClassErr
+}
+
+// watchSessions reports reconnects of the remote desktop or console session
+// through a hidden message-only window registered for session change
+// notifications. It returns a function to stop it and does nothing if the
+// window can't be created, e.g. in a service without a window station.
+func watchSessions() func() {
+	if procWTSRegisterSessionNotification.Find() != nil || registerSessionClass() != nil {
+		return func() {}
+	}
+
+	created := make(chan uintptr)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// windows belong to the thread that created them
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		hwnd, _, _ := syscall.Syscall12(procCreateWindowExW.Addr(), 12,
+			0, uintptr(unsafe.Pointer(sessionClass)), 0, 0, 0, 0, 0, 0, hwndMessage, 0, 0, 0)
+		if hwnd == 0 {
+			created <- 0
+			return
+		}
+		defer syscall.Syscall(procDestroyWindow.Addr(), 1, hwnd, 0, 0) // nolint: errcheck
+
+		r, _, _ := syscall.Syscall(procWTSRegisterSessionNotification.Addr(), 2, hwnd, notifyForThisSession, 0)
+		if r == 0 {
+			created <- 0
+			return
+		}
+		defer syscall.Syscall(procWTSUnRegisterSessionNotification.Addr(), 1, hwnd, 0, 0) // nolint: errcheck
+
+		created <- hwnd
+
+		var m winMsg
+		for {
+			r, _, _ := syscall.Syscall6(procGetMessageW.Addr(), 4, uintptr(unsafe.Pointer(&m)), 0, 0, 0, 0, 0)
+			if int32(r) <= 0 {
+				return
+			}
+
+			_, _, _ = syscall.Syscall(procDispatchMessageW.Addr(), 1, uintptr(unsafe.Pointer(&m)), 0, 0)
+		}
+	}()
+
+	hwnd := <-created
+	if hwnd == 0 {
+		<-done
+		return func() {}
+	}
+
+	return func() {
+		_, _, _ = syscall.Syscall6(procPostMessageW.Addr(), 4, hwnd, wmClose, 0, 0, 0, 0)
+		<-done
+	}
+}
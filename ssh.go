@@ -0,0 +1,190 @@
+package cancelreader
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// NewChannelReader returns a CancelReader for the input of an SSH session
+// channel on the server side, e.g. an ssh.Channel of golang.org/x/crypto/ssh
+// or an ssh.Session of github.com/gliderlabs/ssh. Window size changes sent on
+// resize are inserted into the stream as xterm text area size reports, which
+// a Decoder returns as ResizeEvent, so the same code handles local terminals
+// and SSH sessions. Closing the channel results in io.EOF.
+//
+// The channel is read by a background goroutine, so Cancel always succeeds.
+// Close closes the channel if it implements io.Closer.
+func NewChannelReader(ch io.Reader, resize <-chan ResizeEvent) CancelReader {
+	r := &channelReader{
+		ch:       ch,
+		resize:   resize,
+		chunks:   make(chan channelChunk),
+		canceled: make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+
+	go r.pump()
+
+	return r
+}
+
+type channelChunk struct {
+	data []byte
+	err  error
+}
+
+type channelReader struct {
+	ch     io.Reader
+	resize <-chan ResizeEvent
+	chunks chan channelChunk
+	cancelMixin
+
+	pending []byte
+	err     error
+
+	canceled   chan struct{}
+	cancelOnce sync.Once
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// pump reads the channel until it fails or the reader is closed.
+func (r *channelReader) pump() {
+	for {
+		buf := make([]byte, 4096)
+		n, err := r.ch.Read(buf)
+
+		select {
+		case r.chunks <- channelChunk{data: buf[:n], err: err}:
+		case <-r.closed:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *channelReader) Read(data []byte) (int, error) {
+	return r.readContext(context.Background(), data)
+}
+
+func (r *channelReader) readContext(ctx context.Context, data []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		err := r.next(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(data, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+func (r *channelReader) poll(timeout time.Duration) (bool, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for len(r.pending) == 0 && r.err == nil {
+		err := r.next(context.Background(), timer.C)
+		if errors.Is(err, ErrTimeout) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// next waits for the next chunk or size change and stores it as pending. It
+// returns ErrTimeout if timeout fires first and ctx.Err() if ctx is done.
+func (r *channelReader) next(ctx context.Context, timeout <-chan time.Time) error {
+	if r.isCanceled() {
+		return ErrCanceled
+	}
+
+	select {
+	case c := <-r.chunks:
+		r.pending, r.err = c.data, c.err
+	case size, ok := <-r.resize:
+		if !ok {
+			r.resize = nil
+			return nil
+		}
+
+		r.pending = []byte(fmt.Sprintf("\x1b[8;%d;%dt", size.Rows, size.Cols))
+	case <-r.canceled:
+		return ErrCanceled
+	case <-timeout:
+		return ErrTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (r *channelReader) Cancel() bool {
+	r.setCanceled()
+	r.cancelOnce.Do(func() { close(r.canceled) })
+
+	return true
+}
+
+func (r *channelReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+
+	if c, ok := r.ch.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// ParseWindowChange returns the size in the payload of an SSH "window-change"
+// request.
+func ParseWindowChange(payload []byte) (ResizeEvent, error) {
+	if len(payload) < 8 {
+		return ResizeEvent{}, fmt.Errorf("window-change payload too short: %d bytes", len(payload))
+	}
+
+	return ResizeEvent{
+		Cols: int(binary.BigEndian.Uint32(payload)),
+		Rows: int(binary.BigEndian.Uint32(payload[4:])),
+	}, nil
+}
+
+// ParsePtyRequest returns the terminal type and the initial size in the
+// payload of an SSH "pty-req" request.
+func ParsePtyRequest(payload []byte) (string, ResizeEvent, error) {
+	if len(payload) < 4 {
+		return "", ResizeEvent{}, fmt.Errorf("pty-req payload too short: %d bytes", len(payload))
+	}
+
+	n := binary.BigEndian.Uint32(payload)
+	if uint64(len(payload)) < 4+uint64(n) {
+		return "", ResizeEvent{}, fmt.Errorf("pty-req payload too short: %d bytes", len(payload))
+	}
+
+	size, err := ParseWindowChange(payload[4+n:])
+	if err != nil {
+		return "", ResizeEvent{}, fmt.Errorf("pty-req: %w", err)
+	}
+
+	return string(payload[4 : 4+n]), size, nil
+}
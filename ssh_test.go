@@ -0,0 +1,83 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestChannelReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	resize := make(chan ResizeEvent, 1)
+
+	cr := NewChannelReader(pr, resize)
+	defer cr.Close()
+
+	go func() {
+		_, _ = pw.Write([]byte("a"))
+	}()
+
+	d := NewDecoder(cr)
+	for _, expected := range []Event{KeyEvent{Key: KeyRune, Rune: 'a'}, ResizeEvent{Rows: 24, Cols: 80}} {
+		ev, err := d.ReadEvent()
+		if err != nil {
+			t.Fatalf("expected no error, but got %s", err)
+		}
+		if ev != expected {
+			t.Errorf("expected %v, got %v", expected, ev)
+		}
+
+		if _, ok := ev.(KeyEvent); ok {
+			resize <- ResizeEvent{Rows: 24, Cols: 80}
+		}
+	}
+
+	pw.Close()
+	if _, err := d.ReadEvent(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, but got %v", err)
+	}
+}
+
+func TestChannelReaderCancel(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	cr := NewChannelReader(pr, nil)
+	defer cr.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cr.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	if !cr.Cancel() {
+		t.Errorf("expected cancellation to be success")
+	}
+
+	select {
+	case err := <-done:
+		if err != ErrCanceled {
+			t.Errorf("expected cancel error but got %s", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("expected cancellation to unblock reader")
+	}
+}
+
+func TestParsePtyRequest(t *testing.T) {
+	payload := []byte("\x00\x00\x00\x05xterm\x00\x00\x00\x50\x00\x00\x00\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	term, size, err := ParsePtyRequest(payload)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if term != "xterm" || size != (ResizeEvent{Rows: 24, Cols: 80}) {
+		t.Errorf("expected xterm 80x24, but got %s %v", term, size)
+	}
+
+	if _, _, err = ParsePtyRequest(payload[:7]); err == nil {
+		t.Errorf("expected an error for a truncated payload")
+	}
+}
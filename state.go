@@ -0,0 +1,176 @@
+package cancelreader
+
+import (
+	"errors"
+	"time"
+)
+
+// State is the activity state of a reader, see WithStateEvents.
+type State int
+
+const (
+	// StateActive means that input arrived within the idle timeout.
+	StateActive State = iota
+	// StateIdle means that no input arrived for the idle timeout.
+	StateIdle
+)
+
+func (s State) String() string {
+	switch s {
+	case StateActive:
+		return "active"
+	case StateIdle:
+		return "idle"
+	default:
+		return "unknown"
+	}
+}
+
+// StateEvent reports a transition of the activity state of a reader.
+type StateEvent struct {
+	State State
+
+	// LastInput is when the last input arrived or the reader was created.
+	LastInput time.Time
+}
+
+// startStateEvents starts the idle detection if events is set. A new reader
+// is active.
+func (r *infoReader) startStateEvents(timeout time.Duration, events chan<- StateEvent) {
+	if events == nil || timeout <= 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.idleTimeout = timeout
+	r.stateEvents = events
+	r.lastInput = r.created
+	r.idleTimer = r.clock.AfterFunc(timeout, r.checkIdle)
+}
+
+// markActive records input and reports the transition to StateActive.
+func (r *infoReader) markActive() {
+	if r.stateEvents == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.idleTimer == nil {
+		return
+	}
+
+	r.lastInput = r.clock.Now()
+	if r.state == StateIdle {
+		r.state = StateActive
+		r.sendState()
+	}
+	r.idleTimer.Reset(r.idleTimeout)
+}
+
+// checkIdle reports the transition to StateIdle once no input arrived for
+// the idle timeout.
+func (r *infoReader) checkIdle() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.state == StateIdle || r.idleTimer == nil {
+		return
+	}
+
+	if left := r.idleTimeout - r.clock.Now().Sub(r.lastInput); left > 0 {
+		// input arrived while the timer fired
+		r.idleTimer.Reset(left)
+		return
+	}
+
+	r.state = StateIdle
+	r.sendState()
+}
+
+// sendState sends the current state without blocking. It must be called with
+// the lock held.
+func (r *infoReader) sendState() {
+	select {
+	case r.stateEvents <- StateEvent{State: r.state, LastInput: r.lastInput}:
+	default:
+	}
+}
+
+// stopStateEvents stops the idle detection.
+func (r *infoReader) stopStateEvents() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.idleTimer != nil {
+		r.idleTimer.Stop()
+		r.idleTimer = nil
+	}
+}
+
+func (r *infoReader) Close() error {
+	if r.cancelOnClose && r.Cancel() {
+		r.waitReads()
+	}
+
+	r.stopStateEvents()
+	if r.stopResume != nil {
+		r.stopResume()
+	}
+
+	r.lock.Lock()
+	handles := r.handles
+	r.handles = 0
+	r.closed = true
+	r.markDone()
+	r.lock.Unlock()
+	releaseHandles(handles)
+
+	err := r.CancelReader.Close()
+	if r.dup != nil {
+		err = errors.Join(err, r.dup.Close())
+	}
+	if r.owned != nil {
+		err = errors.Join(err, r.owned.Close())
+	}
+
+	return err
+}
+
+// beginRead and finishRead track the pending Reads for waitReads.
+func (r *infoReader) beginRead() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reading++
+}
+
+func (r *infoReader) finishRead() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reading--
+	if r.reading == 0 && r.idle != nil {
+		close(r.idle)
+		r.idle = nil
+	}
+}
+
+// waitReads waits until no Read is pending.
+func (r *infoReader) waitReads() {
+	r.lock.Lock()
+	if r.reading == 0 {
+		r.lock.Unlock()
+		return
+	}
+	if r.idle == nil {
+		r.idle = make(chan struct{})
+	}
+	idle := r.idle
+	r.lock.Unlock()
+
+	<-idle
+}
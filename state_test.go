@@ -0,0 +1,37 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStateEvents(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	events := make(chan StateEvent, 4)
+	cr, err := NewReader(pr, WithStateEvents(20*time.Millisecond, events))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	for _, expected := range []State{StateIdle, StateActive, StateIdle} {
+		if expected == StateActive {
+			go func() { _, _ = pw.Write([]byte("a")) }()
+			if _, err := cr.Read(make([]byte, 1)); err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+		}
+
+		select {
+		case ev := <-events:
+			if ev.State != expected {
+				t.Errorf("expected %s, got %s", expected, ev.State)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s event", expected)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cancelreader
+
+// ReadStats describes the data returned by a reader.
+type ReadStats struct {
+	// Reads is the number of Reads that returned data.
+	Reads int64
+
+	// Bytes is the number of bytes returned.
+	Bytes int64
+
+	// Hash is the 64-bit FNV-1a hash of all bytes returned if WithTraceHash
+	// is used and 0 otherwise.
+	Hash uint64
+
+	// Handles is the number of file descriptors or handles the reader keeps
+	// open, see SetHandleLimit.
+	Handles int
+}
+
+// Stats returns the statistics of a CancelReader returned by NewReader and
+// false for other readers.
+func Stats(r CancelReader) (ReadStats, bool) {
+	info, ok := infoOf(r)
+	if !ok {
+		return ReadStats{}, false
+	}
+
+	info.lock.Lock()
+	defer info.lock.Unlock()
+
+	return info.stats, true
+}
+
+// count adds data returned by a Read to the statistics.
+func (r *infoReader) count(data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stats.Reads++
+	r.stats.Bytes += int64(len(data))
+
+	if r.hash != nil {
+		_, _ = r.hash.Write(data)
+		r.stats.Hash = r.hash.Sum64()
+	}
+}
@@ -0,0 +1,37 @@
+package cancelreader
+
+import (
+	"hash/fnv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	msg := "hello\r\nworld"
+
+	cr, err := NewReader(strings.NewReader(msg), WithTraceHash())
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	data, err := io.ReadAll(cr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+
+	stats, ok := Stats(cr)
+	if !ok {
+		t.Fatalf("expected stats")
+	}
+	if stats.Bytes != int64(len(msg)) || stats.Reads == 0 {
+		t.Errorf("expected %d bytes in some reads, got %+v", len(msg), stats)
+	}
+	if stats.Hash != h.Sum64() {
+		t.Errorf("expected hash %x, got %x", h.Sum64(), stats.Hash)
+	}
+}
@@ -0,0 +1,382 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Stdin returns a reference to the process-wide shared reader of os.Stdin.
+// It is a cancelable drop-in replacement for os.Stdin that can be assigned to
+// the Stdin fields of prompt libraries like survey, promptui or liner.
+//
+// All references share one CancelReader, so libraries don't construct
+// competing readers over stdin, which on Windows would each flush the console
+// input. Close releases the reference and the shared CancelReader is closed
+// with the last one.
+func Stdin() *StdinRef {
+	return acquireStdin()
+}
+
+// Borrow returns a reference with exclusive access to the shared reader of
+// os.Stdin, e.g. to hand stdin to an external $EDITOR or a password prompt.
+// The pending Reads of all other references are interrupted and their Reads
+// block until the borrow ends, without returning ErrCanceled. The borrow ends
+// when the reference is closed or ctx is done, which also cancels its
+// pending Read. Borrow waits for an earlier borrow to end.
+func Borrow(ctx context.Context) (*StdinRef, error) {
+	ref := acquireStdin()
+
+	err := ref.r.borrow(ctx, ref)
+	if err != nil {
+		_ = ref.Close()
+		return nil, err
+	}
+
+	ref.done = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ref.Close()
+		case <-ref.done:
+		}
+	}()
+
+	return ref, nil
+}
+
+// CancelStdin cancels the pending Read of the shared reader of os.Stdin and
+// returns true if it succeeded.
+func CancelStdin() bool {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	if shared.reader == nil {
+		return false
+	}
+
+	return shared.reader.Cancel()
+}
+
+var shared struct {
+	lock   sync.Mutex
+	reader *StdinReader
+	refs   int
+}
+
+func acquireStdin() *StdinRef {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	if shared.reader == nil {
+		shared.reader = NewStdinReader()
+	}
+	shared.refs++
+
+	return &StdinRef{r: shared.reader}
+}
+
+// StdinRef is a reference to the shared reader of os.Stdin returned by
+// Stdin and Borrow.
+type StdinRef struct {
+	r *StdinReader
+
+	lock     sync.Mutex
+	released bool
+	done     chan struct{}
+}
+
+func (s *StdinRef) Read(data []byte) (int, error) {
+	if s.isReleased() {
+		return 0, os.ErrClosed
+	}
+
+	return s.r.read(context.Background(), s, data)
+}
+
+// ReadContext implements ContextReader.
+func (s *StdinRef) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if s.isReleased() {
+		return 0, os.ErrClosed
+	}
+
+	return s.r.read(ctx, s, data)
+}
+
+// Cancel cancels the pending Read of all references.
+func (s *StdinRef) Cancel() bool {
+	if s.isReleased() {
+		return false
+	}
+
+	return s.r.Cancel()
+}
+
+// Close releases the reference and ends its borrow.
+func (s *StdinRef) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.released {
+		return nil
+	}
+	s.released = true
+
+	if s.done != nil {
+		close(s.done)
+	}
+	s.r.giveBack(s)
+
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+
+	err := shared.reader.Close()
+	shared.reader = nil
+
+	return err
+}
+
+// Fd returns the file descriptor of os.Stdin.
+func (s *StdinRef) Fd() uintptr {
+	return s.r.Fd()
+}
+
+func (s *StdinRef) isReleased() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.released
+}
+
+// StdinReader reads from os.Stdin through a CancelReader. Unlike a plain
+// CancelReader, it stays usable after a cancelation: the canceled Read returns
+// ErrCanceled and the next Read uses a new CancelReader. Fd returns the file
+// descriptor of os.Stdin, so libraries can still put the terminal into raw
+// mode.
+type StdinReader struct {
+	file File
+	opts []Option
+
+	lock sync.Mutex
+	cur  *stdinSlot
+
+	// owner has exclusive access until returned is closed.
+	owner    *StdinRef
+	returned chan struct{}
+
+	shut     bool // see shutdown
+	canceled bool // cancel the next Read, see Cancel
+}
+
+// stdinSlot is a CancelReader with the number of Reads using it. A canceled
+// slot is closed by its last Read.
+type stdinSlot struct {
+	cr      CancelReader
+	reading int
+}
+
+// NewStdinReader returns a StdinReader creating its readers with opts.
+func NewStdinReader(opts ...Option) *StdinReader {
+	return &StdinReader{file: os.Stdin, opts: opts}
+}
+
+func (s *StdinReader) Read(data []byte) (int, error) {
+	return s.read(context.Background(), nil, data)
+}
+
+// ReadContext implements ContextReader. ctx also ends the wait for a borrow.
+func (s *StdinReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return s.read(ctx, nil, data)
+}
+
+// read reads on behalf of caller, waiting while another reference borrowed
+// the reader and retrying Reads interrupted by a borrow.
+func (s *StdinReader) read(ctx context.Context, caller *StdinRef, data []byte) (int, error) {
+	for {
+		s.lock.Lock()
+		for s.owner != nil && s.owner != caller {
+			returned := s.returned
+			s.lock.Unlock()
+
+			select {
+			case <-returned:
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+
+			s.lock.Lock()
+		}
+
+		if s.canceled && !s.shut {
+			s.canceled = false
+			s.lock.Unlock()
+
+			return 0, ErrCanceled
+		}
+
+		slot, err := s.slot()
+		if err != nil {
+			s.lock.Unlock()
+			return 0, err
+		}
+		slot.reading++
+		s.lock.Unlock()
+
+		n, err := readContext(ctx, slot.cr, data)
+
+		s.lock.Lock()
+		slot.reading--
+		if errors.Is(err, ErrCanceled) {
+			s.discard(slot)
+
+			if s.owner != nil && s.owner != caller {
+				// interrupted by a borrow
+				s.lock.Unlock()
+				continue
+			}
+		}
+		s.lock.Unlock()
+
+		return n, err // nolint: wrapcheck
+	}
+}
+
+// slot returns the current slot and creates it if necessary. It must be
+// called with the lock held.
+func (s *StdinReader) slot() (*stdinSlot, error) {
+	if s.shut {
+		return nil, os.ErrClosed
+	}
+
+	if s.cur == nil {
+		cr, err := NewReader(s.file, s.opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		s.cur = &stdinSlot{cr: cr}
+	}
+
+	return s.cur, nil
+}
+
+// discard detaches a canceled slot and closes it if no Read uses it. It must
+// be called with the lock held.
+func (s *StdinReader) discard(slot *stdinSlot) {
+	if s.cur == slot {
+		s.cur = nil
+	}
+
+	if slot.reading == 0 {
+		_ = slot.cr.Close()
+	}
+}
+
+// interrupt cancels and detaches the current slot if a Read is pending. It
+// must be called with the lock held.
+func (s *StdinReader) interrupt() {
+	if s.cur != nil && s.cur.reading > 0 {
+		s.cur.cr.Cancel()
+		s.cur = nil
+	}
+}
+
+// borrow gives owner exclusive access once an earlier borrow ended.
+func (s *StdinReader) borrow(ctx context.Context, owner *StdinRef) error {
+	s.lock.Lock()
+	for s.owner != nil {
+		returned := s.returned
+		s.lock.Unlock()
+
+		select {
+		case <-returned:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		s.lock.Lock()
+	}
+	defer s.lock.Unlock()
+
+	s.owner = owner
+	s.returned = make(chan struct{})
+	s.interrupt()
+
+	return nil
+}
+
+// giveBack ends the borrow of owner and cancels its pending Read.
+func (s *StdinReader) giveBack(owner *StdinRef) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.owner != owner {
+		return
+	}
+
+	s.interrupt()
+	s.owner = nil
+	close(s.returned)
+}
+
+// Cancel cancels the pending Read and returns true if it succeeded. If no
+// Read is pending, the next one is canceled.
+func (s *StdinReader) Cancel() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case s.shut:
+		return false
+	case s.cur == nil:
+		// no reader is created just to cancel it
+		s.canceled = true
+		return true
+	}
+
+	return s.cur.cr.Cancel()
+}
+
+// Close releases the current CancelReader. It does not close os.Stdin and
+// the StdinReader stays usable.
+func (s *StdinReader) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.canceled = false
+
+	slot := s.cur
+	if slot == nil {
+		return nil
+	}
+	s.cur = nil
+
+	if slot.reading > 0 {
+		// closed by the last Read
+		slot.cr.Cancel()
+		return nil
+	}
+
+	return slot.cr.Close() // nolint: wrapcheck
+}
+
+// shutdown closes the current CancelReader like Close, but the StdinReader
+// doesn't create new ones and its Reads fail with os.ErrClosed.
+func (s *StdinReader) shutdown() error {
+	s.lock.Lock()
+	s.shut = true
+	s.lock.Unlock()
+
+	return s.Close()
+}
+
+// Fd returns the file descriptor of os.Stdin.
+func (s *StdinReader) Fd() uintptr {
+	return s.file.Fd()
+}
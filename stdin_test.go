@@ -0,0 +1,71 @@
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestStdinRefCount(t *testing.T) {
+	a, b := Stdin(), Stdin()
+	if a.r != b.r {
+		t.Errorf("expected references to share one reader")
+	}
+
+	if err := a.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if shared.reader == nil || shared.refs != 1 {
+		t.Errorf("expected the reader to be kept for the remaining reference, but got %d references", shared.refs)
+	}
+
+	if _, err := a.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected a released reference to fail, but got %v", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if shared.reader != nil || shared.refs != 0 {
+		t.Errorf("expected the reader to be closed with the last reference")
+	}
+
+	if CancelStdin() {
+		t.Errorf("expected CancelStdin to fail without references")
+	}
+}
+
+func TestStdinReaderCancelNext(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	s := NewStdinReader()
+	s.file = pr
+	defer s.Close()
+
+	if !s.Cancel() {
+		t.Errorf("expected Cancel to succeed")
+	}
+	if s.cur != nil {
+		t.Errorf("expected no reader to be created by Cancel")
+	}
+
+	data := make([]byte, 1)
+	if _, err := s.Read(data); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	if _, err := pw.Write([]byte("a")); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	if _, err := s.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+}
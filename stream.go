@@ -0,0 +1,63 @@
+package cancelreader
+
+import (
+	"context"
+)
+
+// NewStreamReader returns a CancelReader for a stream of messages received
+// by recv, such as the Recv method of a gRPC stream or ReadMessage of a
+// WebSocket connection, so that web terminal backends get the same
+// semantics as local terminals. Read returns the data of the messages in
+// order.
+//
+// cancel is the CancelFunc of the context the stream was created with.
+// Cancel calls it to abort the pending receive and always succeeds, while
+// the error recv returns for it is reported as ErrCanceled. As with any
+// cancelation, the stream is finished afterwards. Close calls cancel as well.
+func NewStreamReader(recv func() ([]byte, error), cancel context.CancelFunc) CancelReader {
+	return &streamReader{recv: recv, cancel: cancel}
+}
+
+type streamReader struct {
+	recv   func() ([]byte, error)
+	cancel context.CancelFunc
+	cancelMixin
+
+	pending []byte
+	err     error
+}
+
+func (r *streamReader) Read(data []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.isCanceled() {
+			return 0, ErrCanceled
+		}
+
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.pending, r.err = r.recv()
+		if r.isCanceled() {
+			return 0, ErrCanceled
+		}
+	}
+
+	n := copy(data, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
+
+func (r *streamReader) Cancel() bool {
+	r.setCanceled()
+	r.cancel()
+
+	return true
+}
+
+func (r *streamReader) Close() error {
+	r.cancel()
+
+	return nil
+}
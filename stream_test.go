@@ -0,0 +1,44 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStreamReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan string, 2)
+	msgs <- "hello "
+	msgs <- "world"
+
+	recv := func() ([]byte, error) {
+		select {
+		case msg := <-msgs:
+			return []byte(msg), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	r := NewStreamReader(recv, cancel)
+
+	data := make([]byte, 11)
+	if _, err := io.ReadFull(r, data); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("expected %q, but got %q", "hello world", data)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		r.Cancel()
+	}()
+
+	if _, err := r.Read(data); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+}
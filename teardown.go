@@ -0,0 +1,96 @@
+package cancelreader
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Stage orders the steps of a Teardown.
+type Stage int
+
+const (
+	// StageReaders cancels and closes readers and their wrappers.
+	StageReaders Stage = iota
+	// StageConsole restores terminal and console modes.
+	StageConsole
+	// StageResources releases pollers, files and everything else.
+	StageResources
+
+	stages
+)
+
+// Teardown closes what an application set up in a defined order: readers
+// first, so that no Read is pending while the console is restored, then the
+// console, then the remaining resources. Within a stage, steps run in
+// reverse order of adding, like deferred calls.
+type Teardown struct {
+	lock  sync.Mutex
+	steps [stages][]func() error
+	done  chan struct{} // closed when Close finished
+	err   error
+}
+
+// NewTeardown returns an empty Teardown.
+func NewTeardown() *Teardown {
+	return &Teardown{}
+}
+
+// Add adds fn to stage. If Close was already called, fn runs right away.
+func (t *Teardown) Add(stage Stage, fn func() error) {
+	t.lock.Lock()
+	if t.done == nil {
+		t.steps[stage] = append(t.steps[stage], fn)
+		t.lock.Unlock()
+
+		return
+	}
+	t.lock.Unlock()
+
+	_ = fn()
+}
+
+// AddCloser adds the Close method of c to stage.
+func (t *Teardown) AddCloser(stage Stage, c io.Closer) {
+	t.Add(stage, c.Close)
+}
+
+// AddReader adds r to StageReaders, so that Close cancels its pending Read
+// and closes it.
+func (t *Teardown) AddReader(r CancelReader) {
+	t.Add(StageReaders, func() error {
+		r.Cancel()
+		return r.Close()
+	})
+}
+
+// Close runs the steps once and returns their errors joined. It can be
+// called from any goroutine at any time; later calls wait until the first
+// one finished and return the same errors.
+func (t *Teardown) Close() error {
+	t.lock.Lock()
+	if t.done != nil {
+		done := t.done
+		t.lock.Unlock()
+		<-done
+
+		return t.err
+	}
+
+	t.done = make(chan struct{})
+	steps := t.steps
+	t.steps = [stages][]func() error{}
+	t.lock.Unlock()
+
+	var errs []error
+	for _, stage := range steps {
+		for i := len(stage) - 1; i >= 0; i-- {
+			errs = append(errs, stage[i]())
+		}
+	}
+
+	t.err = errors.Join(errs...)
+	close(t.done)
+
+	return t.err
+}
@@ -0,0 +1,63 @@
+package cancelreader
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTeardown(t *testing.T) {
+	var order []string
+
+	step := func(name string, err error) func() error {
+		return func() error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	errConsole := errors.New("console")
+
+	td := NewTeardown()
+	td.Add(StageResources, step("poller", nil))
+	td.Add(StageConsole, step("console", errConsole))
+	td.Add(StageReaders, step("reader", nil))
+	td.Add(StageReaders, step("wrapper", nil))
+
+	r, err := NewReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	td.AddReader(r)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = td.Close()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if !errors.Is(err, errConsole) {
+			t.Errorf("expected the console error, but got %v", err)
+		}
+	}
+
+	if expected := "wrapper reader console poller"; strings.Join(order, " ") != expected {
+		t.Errorf("expected %q, but got %q", expected, strings.Join(order, " "))
+	}
+
+	if _, err := r.Read(make([]byte, 1)); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	td.Add(StageResources, step("late", nil))
+	if order[len(order)-1] != "late" {
+		t.Errorf("expected a step added after Close to run right away")
+	}
+}
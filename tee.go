@@ -0,0 +1,171 @@
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Policy decides what Tee does with data for a sink that can't keep up.
+type Policy int
+
+const (
+	// PolicyBlock makes Read wait until the sink accepted the data.
+	PolicyBlock Policy = iota
+	// PolicyDrop drops data while the sink is busy writing.
+	PolicyDrop
+	// PolicyBuffer queues up to Sink.Buffer chunks while the sink is busy
+	// and drops data once the queue is full.
+	PolicyBuffer
+)
+
+// Sink is a writer that Tee mirrors input to.
+type Sink struct {
+	W      io.Writer
+	Policy Policy
+	Buffer int // queued chunks for PolicyBuffer
+}
+
+// Tee returns a CancelReader that writes everything read from r to each
+// sink according to its policy, e.g. to record a session with PolicyBlock
+// while a live supervisor shadows it with PolicyDrop without stalling the
+// typing. A sink is no longer written to after it failed. Close closes r,
+// waits until the queued data was written and returns the errors of the
+// sinks.
+func Tee(r CancelReader, sinks ...Sink) CancelReader {
+	t := &teeReader{CancelReader: r}
+
+	for _, s := range sinks {
+		ts := &teeSink{w: s.W, policy: s.Policy}
+
+		if s.Policy != PolicyBlock {
+			size := 0
+			if s.Policy == PolicyBuffer {
+				size = s.Buffer
+			}
+
+			ts.queue = make(chan []byte, size)
+			t.wg.Add(1)
+			go ts.run(&t.wg)
+		}
+
+		t.sinks = append(t.sinks, ts)
+	}
+
+	return t
+}
+
+type teeReader struct {
+	CancelReader
+	sinks []*teeSink
+	wg    sync.WaitGroup
+
+	// lock protects the queues from being closed while Read sends
+	lock   sync.RWMutex
+	closed bool
+}
+
+type teeSink struct {
+	w      io.Writer
+	policy Policy
+	queue  chan []byte
+
+	lock sync.Mutex
+	err  error
+}
+
+func (r *teeReader) Read(data []byte) (int, error) {
+	return r.ReadContext(context.Background(), data)
+}
+
+// ReadContext implements ContextReader.
+func (r *teeReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n, err := readContext(ctx, r.CancelReader, data)
+	if n > 0 {
+		r.lock.RLock()
+		defer r.lock.RUnlock()
+
+		var chunk []byte
+
+		for _, s := range r.sinks {
+			if r.closed {
+				break
+			}
+
+			if s.queue == nil {
+				s.write(data[:n])
+				continue
+			}
+
+			if chunk == nil {
+				// data is reused by the caller
+				chunk = append([]byte(nil), data[:n]...)
+			}
+
+			select {
+			case s.queue <- chunk:
+			default:
+				// dropped
+			}
+		}
+	}
+
+	return n, err // nolint: wrapcheck
+}
+
+func (r *teeReader) poll(timeout time.Duration) (bool, error) {
+	return pollReader(r.CancelReader, timeout)
+}
+
+func (r *teeReader) unwrap() CancelReader {
+	return r.CancelReader
+}
+
+// run writes the queued chunks until the queue is closed.
+func (s *teeSink) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for chunk := range s.queue {
+		s.write(chunk)
+	}
+}
+
+func (s *teeSink) write(data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err == nil {
+		_, s.err = s.w.Write(data)
+	}
+}
+
+func (r *teeReader) Close() error {
+	err := r.CancelReader.Close()
+
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+
+		for _, s := range r.sinks {
+			if s.queue != nil {
+				close(s.queue)
+			}
+		}
+	}
+	r.lock.Unlock()
+	r.wg.Wait()
+
+	errs := []error{err}
+	for i, s := range r.sinks {
+		s.lock.Lock()
+		if s.err != nil {
+			errs = append(errs, fmt.Errorf("writing sink %d: %w", i, s.err))
+		}
+		s.lock.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package cancelreader
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// blockedWriter blocks writes until unblock is closed.
+type blockedWriter struct {
+	unblock chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return w.buf.Write(p)
+}
+
+func TestTee(t *testing.T) {
+	cr, err := NewReader(&chunkReader{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	var record bytes.Buffer
+	shadow := &blockedWriter{unblock: make(chan struct{})}
+	buffered := &blockedWriter{unblock: make(chan struct{})}
+
+	r := Tee(cr,
+		Sink{W: &record},
+		Sink{W: shadow, Policy: PolicyDrop},
+		Sink{W: buffered, Policy: PolicyBuffer, Buffer: 3},
+	)
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	close(shadow.unblock)
+	close(buffered.unblock)
+	if err := r.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	if string(data) != "abc" || record.String() != "abc" || buffered.buf.String() != "abc" {
+		t.Errorf("expected %q everywhere, got %q, %q and %q", "abc", data, record.String(), buffered.buf.String())
+	}
+
+	// the shadow was busy with the first chunk it received
+	if shadow.buf.Len() > 1 {
+		t.Errorf("expected the shadow to drop data, got %q", shadow.buf.String())
+	}
+}
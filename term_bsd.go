@@ -0,0 +1,14 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+
+	// ioctlInputQueue is FIONREAD, which x/sys/unix does not define here.
+	ioctlInputQueue = 0x4004667f
+)
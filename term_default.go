@@ -0,0 +1,44 @@
+//go:build !darwin && !windows && !linux && !solaris && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !darwin,!windows,!linux,!solaris,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func makeRaw(uintptr) (func() error, error) {
+	return nil, fmt.Errorf("raw mode is not supported on this platform")
+}
+
+func rawModeChanges(uintptr) ([]string, error) {
+	return nil, fmt.Errorf("raw mode is not supported on this platform")
+}
+
+func echoEnabled(uintptr) (bool, error) {
+	return false, fmt.Errorf("terminal attributes are not supported on this platform")
+}
+
+func inputPending(uintptr) (int, error) {
+	return 0, fmt.Errorf("input queue size is not supported on this platform")
+}
+
+func notifyResize(chan<- os.Signal) {}
+
+func openTTY() (*os.File, error) {
+	return nil, fmt.Errorf("terminals are not supported on this platform")
+}
+
+func dupFile(File) (*os.File, error) {
+	return nil, nil
+}
+
+func dupFd(uintptr) (uintptr, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func closeFd(uintptr) error {
+	return nil
+}
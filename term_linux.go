@@ -0,0 +1,8 @@
+//go:build linux
+// +build linux
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+const ioctlInputQueue = unix.TIOCINQ
@@ -0,0 +1,7 @@
+//go:build solaris
+// +build solaris
+
+package cancelreader
+
+// ioctlInputQueue is FIONREAD, which x/sys/unix does not define here.
+const ioctlInputQueue = 0x4004667f
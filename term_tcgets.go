@@ -0,0 +1,11 @@
+//go:build linux || solaris
+// +build linux solaris
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
@@ -0,0 +1,145 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeRaw puts the terminal behind fd into raw mode and returns a function
+// restoring the previous mode. For screen readers, Ctrl+C keeps sending
+// SIGINT, see ScreenReaderActive.
+func makeRaw(fd uintptr) (func() error, error) {
+	old, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, fmt.Errorf("get terminal attributes: %w", err)
+	}
+
+	raw := rawMode(old)
+
+	err = unix.IoctlSetTermios(int(fd), ioctlSetTermios, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("set terminal attributes: %w", err)
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(int(fd), ioctlSetTermios, old)
+	}, nil
+}
+
+// rawMode returns the raw mode version of old.
+func rawMode(old *unix.Termios) unix.Termios {
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	if ScreenReaderActive() {
+		raw.Lflag |= old.Lflag & unix.ISIG
+	}
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	return raw
+}
+
+// lflagNames and iflagNames name the flags cleared by rawMode, which are
+// the ones that change the behavior of the input noticeably.
+var (
+	lflagNames = []struct {
+		flag uint32
+		name string
+	}{{unix.ECHO, "ECHO"}, {unix.ECHONL, "ECHONL"}, {unix.ICANON, "ICANON"}, {unix.ISIG, "ISIG"}, {unix.IEXTEN, "IEXTEN"}}
+	iflagNames = []struct {
+		flag uint32
+		name string
+	}{{unix.ICRNL, "ICRNL"}, {unix.IXON, "IXON"}, {unix.ISTRIP, "ISTRIP"}}
+)
+
+// rawModeChanges returns the flags of the terminal behind fd that raw mode
+// would clear, prefixed with a minus.
+func rawModeChanges(fd uintptr) ([]string, error) {
+	old, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, fmt.Errorf("get terminal attributes: %w", err)
+	}
+
+	raw := rawMode(old)
+
+	var changes []string
+
+	for _, f := range lflagNames {
+		if uint32(old.Lflag)&f.flag != 0 && uint32(raw.Lflag)&f.flag == 0 {
+			changes = append(changes, "-"+f.name)
+		}
+	}
+
+	for _, f := range iflagNames {
+		if uint32(old.Iflag)&f.flag != 0 && uint32(raw.Iflag)&f.flag == 0 {
+			changes = append(changes, "-"+f.name)
+		}
+	}
+
+	return changes, nil
+}
+
+// echoEnabled reports whether the terminal behind fd echoes input.
+func echoEnabled(fd uintptr) (bool, error) {
+	t, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return false, fmt.Errorf("get terminal attributes: %w", err)
+	}
+
+	return t.Lflag&unix.ECHO != 0, nil
+}
+
+// inputPending returns the number of bytes that can be read from fd without
+// blocking.
+func inputPending(fd uintptr) (int, error) {
+	n, err := unix.IoctlGetInt(int(fd), ioctlInputQueue)
+	if err != nil {
+		return 0, fmt.Errorf("get input queue size: %w", err)
+	}
+
+	return n, nil
+}
+
+// notifyResize relays terminal window size changes to c.
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, unix.SIGWINCH)
+}
+
+// openTTY opens the controlling terminal.
+func openTTY() (*os.File, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// dupFd duplicates fd with close-on-exec set.
+func dupFd(fd uintptr) (uintptr, error) {
+	dup, err := unix.FcntlInt(fd, unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return 0, fmt.Errorf("dup: %w", err)
+	}
+
+	return uintptr(dup), nil
+}
+
+func closeFd(fd uintptr) error {
+	return unix.Close(int(fd)) // nolint: wrapcheck
+}
+
+// dupFile returns a duplicate of f.
+func dupFile(f File) (*os.File, error) {
+	fd, err := unix.FcntlInt(f.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("dup %s: %w", f.Name(), err)
+	}
+
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
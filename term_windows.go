@@ -0,0 +1,103 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// makeRaw puts the console behind os.Stdin into raw mode and returns a
+// function restoring the previous mode.
+func makeRaw(fd uintptr) (func() error, error) {
+	if fd != os.Stdin.Fd() {
+		return nil, fmt.Errorf("raw mode is only supported for os.Stdin")
+	}
+
+	s, err := PrepareConsole()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Restore, nil
+}
+
+// rawModeChanges returns the console input flags PrepareConsole would set
+// or clear, prefixed with a plus or minus.
+func rawModeChanges(fd uintptr) ([]string, error) {
+	if fd != os.Stdin.Fd() {
+		return nil, fmt.Errorf("raw mode is only supported for os.Stdin")
+	}
+
+	var orig uint32
+	if err := windows.GetConsoleMode(windows.Handle(fd), &orig); err != nil {
+		return nil, fmt.Errorf("get console mode: %w", err)
+	}
+
+	mode := ConsoleOptions{RawInput: true, VirtualTerminalInput: true, ScreenReader: ScreenReaderActive()}.inputMode(orig)
+
+	var changes []string
+
+	for _, f := range inputModeFlags {
+		switch {
+		case orig&f.flag == 0 && mode&f.flag != 0:
+			changes = append(changes, "+"+f.name)
+		case orig&f.flag != 0 && mode&f.flag == 0:
+			changes = append(changes, "-"+f.name)
+		}
+	}
+
+	return changes, nil
+}
+
+// echoEnabled reports whether the console behind fd echoes input.
+func echoEnabled(fd uintptr) (bool, error) {
+	var mode uint32
+	if err := windows.GetConsoleMode(windows.Handle(fd), &mode); err != nil {
+		return false, fmt.Errorf("get console mode: %w", err)
+	}
+
+	return mode&windows.ENABLE_ECHO_INPUT != 0, nil
+}
+
+// inputPending returns the number of input events in the buffer of the
+// console behind fd.
+func inputPending(fd uintptr) (int, error) {
+	var n uint32
+
+	ok, _, err := syscall.Syscall(procGetNumberOfConsoleInputEvents.Addr(), 2,
+		fd, uintptr(unsafe.Pointer(&n)), 0)
+	if ok == 0 {
+		return 0, fmt.Errorf("get number of console input events: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// notifyResize does nothing as consoles report size changes as input records.
+func notifyResize(chan<- os.Signal) {}
+
+// openTTY opens the console input buffer.
+func openTTY() (*os.File, error) {
+	return os.OpenFile("CONIN$", os.O_RDWR, 0)
+}
+
+// dupFile returns nil as the console backend opens CONIN$ on its own.
+func dupFile(File) (*os.File, error) {
+	return nil, nil
+}
+
+// dupFd is not supported, so connections use the deadline backend.
+func dupFd(uintptr) (uintptr, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func closeFd(uintptr) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package cancelreader
+
+import "errors"
+
+// CancelToken cancels a specific Read of a CancelReader. A token canceled
+// after its Read already returned does not cancel the next Read, e.g. when a
+// timeout fires just as the user presses a key.
+type CancelToken struct {
+	cr         CancelReader
+	info       *infoReader
+	generation uint64
+}
+
+// Token returns a CancelToken for the pending Read of r or for the next one
+// if no Read is pending. Only readers returned by NewReader keep track of
+// their Reads; for other readers the token cancels like r.Cancel.
+func Token(r CancelReader) CancelToken {
+	info, ok := infoOf(r)
+	if !ok {
+		return CancelToken{cr: r}
+	}
+
+	info.lock.Lock()
+	defer info.lock.Unlock()
+
+	return CancelToken{cr: r, info: info, generation: info.generation}
+}
+
+// Cancel cancels the Read of the token and future reads like
+// CancelReader.Cancel. It returns false without canceling anything if the
+// Read of the token already returned.
+func (t CancelToken) Cancel() bool {
+	if t.info == nil {
+		return t.cr != nil && t.cr.Cancel()
+	}
+
+	t.info.lock.Lock()
+	defer t.info.lock.Unlock()
+
+	if t.info.generation != t.generation {
+		return false
+	}
+	t.info.tokenCanceled = true
+
+	return t.info.CancelReader.Cancel()
+}
+
+// endRead starts the next Read generation. If a token canceled the Read just
+// after it received its data, the cancelation missed and is undone so that
+// it does not affect the next Read.
+func (r *infoReader) endRead(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.generation++
+
+	if r.tokenCanceled && !r.canceled && !errors.Is(err, ErrCanceled) {
+		_ = r.reset()
+	}
+	r.tokenCanceled = false
+}
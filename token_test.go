@@ -0,0 +1,49 @@
+package cancelreader
+
+import (
+	"io"
+	"testing"
+)
+
+func TestCancelToken(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	var token CancelToken
+	cr, err := NewReader(pr, WithFirstReadHook(func(FirstRead) {
+		// the timeout fires after the Read received its data
+		token.Cancel()
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	go func() {
+		_, _ = pw.Write([]byte("a"))
+		_, _ = pw.Write([]byte("b"))
+	}()
+
+	token = Token(cr)
+	p := make([]byte, 1)
+	if _, err := cr.Read(p); err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	if token.Cancel() {
+		t.Errorf("expected cancellation of a completed read to be failure")
+	}
+
+	n, err := cr.Read(p)
+	if err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if string(p[:n]) != "b" {
+		t.Errorf("expected to read %q but got %q", "b", string(p[:n]))
+	}
+
+	Token(cr).Cancel()
+	if _, err := cr.Read(p); err != ErrCanceled {
+		t.Errorf("expected cancel error but got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package cancelreader
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript is a writer turning raw input into a human-readable log of the
+// typed lines, for audit trails of bastion hosts and similar tools. Use it
+// as a Sink of Tee to log a session while it is read:
+//
+//	t := cancelreader.NewTranscript(log, cancelreader.WithRedaction(cancelreader.TerminalEcho(pty.Fd())))
+//	r = cancelreader.Tee(r, cancelreader.Sink{W: t})
+//
+// Every line starts with the seconds since NewTranscript at which its first
+// key was typed. Characters are logged as typed, other keys and events in
+// angle brackets, e.g. <ctrl+c> or <up>, and Enter ends the line.
+type Transcript struct {
+	w     io.Writer
+	echo  func() bool
+	start time.Time
+
+	lock sync.Mutex
+	buf  []byte // start of an incomplete sequence
+	line strings.Builder
+	at   time.Duration // when the first key of line was typed
+	err  error
+}
+
+// TranscriptOption configures a Transcript.
+type TranscriptOption func(*Transcript)
+
+// WithRedaction masks every key typed while echo returns false with an
+// asterisk, so that passwords don't end up in the log. Enter still ends the
+// line.
+func WithRedaction(echo func() bool) TranscriptOption {
+	return func(t *Transcript) {
+		t.echo = echo
+	}
+}
+
+// TerminalEcho returns a function reporting whether the terminal behind fd
+// echoes input, for WithRedaction. For a session run in a pseudo terminal,
+// pass the master side, as the terminal the input is read from is usually
+// in raw mode. If the mode can't be queried, e.g. because fd is not a
+// terminal, the function reports true.
+func TerminalEcho(fd uintptr) func() bool {
+	return func() bool {
+		echo, err := echoEnabled(fd)
+		return echo || err != nil
+	}
+}
+
+// NewTranscript returns a Transcript writing to w.
+func NewTranscript(w io.Writer, opts ...TranscriptOption) *Transcript {
+	t := &Transcript{w: w, start: time.Now()}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Write logs the input in p. Sequences split across writes are joined, so
+// a lone Escape is logged with the next write.
+// After writing the log failed, the error is returned for every Write.
+func (t *Transcript) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.buf = append(t.buf, p...)
+	t.decode(false)
+
+	return len(p), t.err
+}
+
+// Close logs the rest of the input, including an unfinished line. It does
+// not close the underlying writer.
+func (t *Transcript) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.decode(true)
+	if t.line.Len() > 0 {
+		t.flush()
+	}
+
+	return t.err
+}
+
+func (t *Transcript) decode(final bool) {
+	masked := t.echo != nil && !t.echo()
+
+	for len(t.buf) > 0 {
+		if !final && ambiguousEscape(t.buf) {
+			break
+		}
+
+		ev, n := decodeEvent(t.buf, final)
+		if n == 0 {
+			break
+		}
+
+		t.buf = t.buf[n:]
+		t.log(ev, masked)
+	}
+
+	if len(t.buf) == 0 {
+		t.buf = nil
+	}
+}
+
+func (t *Transcript) log(ev Event, masked bool) {
+	if t.line.Len() == 0 {
+		t.at = time.Since(t.start)
+	}
+
+	key, ok := ev.(KeyEvent)
+
+	switch {
+	case ok && key == KeyEvent{Key: KeyEnter}:
+		t.flush()
+	case masked:
+		t.line.WriteByte('*')
+	case ok && key.Key == KeyRune && key.Mod == 0:
+		t.line.WriteRune(key.Rune)
+	default:
+		t.line.WriteString("<" + ev.String() + ">")
+	}
+}
+
+func (t *Transcript) flush() {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, "%9.3f %s\n", t.at.Seconds(), t.line.String())
+	}
+
+	t.line.Reset()
+}
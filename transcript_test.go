@@ -0,0 +1,49 @@
+package cancelreader
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestTranscript(t *testing.T) {
+	var log bytes.Buffer
+
+	echo := true
+	tr := NewTranscript(&log, WithRedaction(func() bool { return echo }))
+
+	for _, chunk := range []string{"ls -l\r", "sudo x\x1b[", "D\r"} {
+		if _, err := tr.Write([]byte(chunk)); err != nil {
+			t.Errorf("expected no error, but got %s", err)
+		}
+	}
+
+	echo = false
+	if _, err := tr.Write([]byte("s3cr\xc3")); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if _, err := tr.Write([]byte("\xa9t\r")); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	echo = true
+	if _, err := tr.Write([]byte("exit\x03")); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+
+	expected := []string{"ls -l", "sudo x<left>", "******", "exit<ctrl+c>"}
+
+	lines := regexp.MustCompile(`(?m)^ +\d+\.\d{3} (.*)$`).FindAllStringSubmatch(log.String(), -1)
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, but got %q", len(expected), log.String())
+	}
+
+	for i, line := range lines {
+		if line[1] != expected[i] {
+			t.Errorf("expected line %d to be %q, but got %q", i, expected[i], line[1])
+		}
+	}
+}
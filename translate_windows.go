@@ -0,0 +1,394 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Event types of InputRecord.
+const (
+	KeyEventType              = 0x0001
+	MouseEventType            = 0x0002
+	WindowBufferSizeEventType = 0x0004
+	MenuEventType             = 0x0008
+	FocusEventType            = 0x0010
+)
+
+// button states and event flags of MOUSE_EVENT_RECORD
+const (
+	fromLeft1stButtonPressed = 0x0001
+	rightmostButtonPressed   = 0x0002
+	fromLeft2ndButtonPressed = 0x0004
+
+	mouseMoved    = 0x0001
+	mouseWheeled  = 0x0004
+	mouseHWheeled = 0x0008
+)
+
+// virtual key codes
+const (
+	vkTab    = 0x09
+	vkPrior  = 0x21
+	vkNext   = 0x22
+	vkEnd    = 0x23
+	vkHome   = 0x24
+	vkLeft   = 0x25
+	vkUp     = 0x26
+	vkRight  = 0x27
+	vkDown   = 0x28
+	vkInsert = 0x2d
+	vkDelete = 0x2e
+	vkF1     = 0x70
+	vkF2     = 0x71
+	vkF3     = 0x72
+	vkF4     = 0x73
+	vkF5     = 0x74
+	vkF6     = 0x75
+	vkF7     = 0x76
+	vkF8     = 0x77
+	vkF9     = 0x78
+	vkF10    = 0x79
+	vkF11    = 0x7a
+	vkF12    = 0x7b
+)
+
+// control key states of KEY_EVENT_RECORD and MOUSE_EVENT_RECORD
+const (
+	rightAltPressed  = 0x0001
+	leftAltPressed   = 0x0002
+	rightCtrlPressed = 0x0004
+	leftCtrlPressed  = 0x0008
+	shiftPressed     = 0x0010
+)
+
+// InputRecord is the INPUT_RECORD structure returned by ReadConsoleInput.
+// Use the accessor matching EventType to read the event.
+type InputRecord struct {
+	EventType uint16
+	_         uint16
+	event     [4]uint32
+}
+
+// KeyEventRecord is the KEY_EVENT_RECORD structure.
+type KeyEventRecord struct {
+	KeyDown         int32
+	RepeatCount     uint16
+	VirtualKeyCode  uint16
+	VirtualScanCode uint16
+	UnicodeChar     uint16
+	ControlKeyState uint32
+}
+
+// MouseEventRecord is the MOUSE_EVENT_RECORD structure.
+type MouseEventRecord struct {
+	MousePosition   windows.Coord
+	ButtonState     uint32
+	ControlKeyState uint32
+	EventFlags      uint32
+}
+
+// KeyEvent returns the event of a record of type KeyEventType.
+func (r *InputRecord) KeyEvent() *KeyEventRecord {
+	return (*KeyEventRecord)(unsafe.Pointer(&r.event))
+}
+
+// MouseEvent returns the event of a record of type MouseEventType.
+func (r *InputRecord) MouseEvent() *MouseEventRecord {
+	return (*MouseEventRecord)(unsafe.Pointer(&r.event))
+}
+
+// WindowBufferSizeEvent returns the new screen buffer size of a record of type
+// WindowBufferSizeEventType.
+func (r *InputRecord) WindowBufferSizeEvent() windows.Coord {
+	return *(*windows.Coord)(unsafe.Pointer(&r.event))
+}
+
+var procReadConsoleInputW = modkernel32.NewProc("ReadConsoleInputW")
+
+func readConsoleInput(console windows.Handle, records []InputRecord) (int, error) {
+	var n uint32
+
+	r, _, e := syscall.Syscall6(procReadConsoleInputW.Addr(), 4,
+		uintptr(console), uintptr(unsafe.Pointer(&records[0])), uintptr(len(records)),
+		uintptr(unsafe.Pointer(&n)), 0, 0)
+	if r == 0 {
+		return 0, fmt.Errorf("read console input: %w", error(e))
+	}
+
+	return int(n), nil
+}
+
+// csiKeys maps virtual key codes to the final byte of their xterm CSI
+// sequence, e.g. ESC [ A for the up arrow.
+var csiKeys = map[uint16]byte{
+	vkUp:    'A',
+	vkDown:  'B',
+	vkRight: 'C',
+	vkLeft:  'D',
+	vkHome:  'H',
+	vkEnd:   'F',
+}
+
+// ss3Keys maps virtual key codes to the final byte of their xterm SS3
+// sequence, e.g. ESC O P for F1.
+var ss3Keys = map[uint16]byte{
+	vkF1: 'P',
+	vkF2: 'Q',
+	vkF3: 'R',
+	vkF4: 'S',
+}
+
+// tildeKeys maps virtual key codes to the parameter of their xterm
+// ESC [ n ~ sequence.
+var tildeKeys = map[uint16]int{
+	vkInsert: 2,
+	vkDelete: 3,
+	vkPrior:  5,
+	vkNext:   6,
+	vkF5:     15,
+	vkF6:     17,
+	vkF7:     18,
+	vkF8:     19,
+	vkF9:     20,
+	vkF10:    21,
+	vkF11:    23,
+	vkF12:    24,
+}
+
+// inputTranslator translates console input records into the byte stream an
+// xterm would produce for the same input. Mouse and window size events are
+// only translated if events is set.
+type inputTranslator struct {
+	events bool
+
+	// window returns the visible window of the console screen buffer to
+	// translate buffer coordinates and sizes. It is optional.
+	window func() (windows.SmallRect, error)
+
+	highSurrogate uint16
+	buttons       uint32
+}
+
+func (t *inputTranslator) translate(buf []byte, record *InputRecord) []byte {
+	switch record.EventType {
+	case KeyEventType:
+		return t.translateKey(buf, record.KeyEvent())
+	case MouseEventType:
+		if t.events {
+			return t.translateMouse(buf, record.MouseEvent())
+		}
+	case WindowBufferSizeEventType:
+		if t.events {
+			return t.translateSize(buf, record.WindowBufferSizeEvent())
+		}
+	}
+
+	return buf
+}
+
+func (t *inputTranslator) translateKey(buf []byte, key *KeyEventRecord) []byte {
+	if key.KeyDown == 0 {
+		return buf
+	}
+
+	seq := t.keySequence(key)
+	for i := uint16(0); i < key.RepeatCount; i++ {
+		buf = append(buf, seq...)
+	}
+
+	return buf
+}
+
+func (t *inputTranslator) keySequence(key *KeyEventRecord) []byte {
+	state := key.ControlKeyState
+	alt := state&(leftAltPressed|rightAltPressed) != 0
+	ctrl := state&(leftCtrlPressed|rightCtrlPressed) != 0
+
+	if key.VirtualKeyCode == vkTab && state&shiftPressed != 0 {
+		return []byte("\x1b[Z")
+	}
+
+	if final, ok := csiKeys[key.VirtualKeyCode]; ok {
+		if m := modifierParam(state); m > 1 {
+			return []byte(fmt.Sprintf("\x1b[1;%d%c", m, final))
+		}
+
+		return []byte{'\x1b', '[', final}
+	}
+
+	if final, ok := ss3Keys[key.VirtualKeyCode]; ok {
+		if m := modifierParam(state); m > 1 {
+			return []byte(fmt.Sprintf("\x1b[1;%d%c", m, final))
+		}
+
+		return []byte{'\x1b', 'O', final}
+	}
+
+	if n, ok := tildeKeys[key.VirtualKeyCode]; ok {
+		if m := modifierParam(state); m > 1 {
+			return []byte(fmt.Sprintf("\x1b[%d;%d~", n, m))
+		}
+
+		return []byte(fmt.Sprintf("\x1b[%d~", n))
+	}
+
+	if key.UnicodeChar == 0 && alt && ctrl && 'A' <= key.VirtualKeyCode && key.VirtualKeyCode <= 'Z' {
+		// Ctrl+Alt+letter without an AltGr mapping, encoded like a terminal
+		// with meta-sends-escape does
+		return []byte{'\x1b', byte(key.VirtualKeyCode-'A') + 1}
+	}
+
+	if key.UnicodeChar == 0 {
+		// modifier keys and keys without a character
+		return nil
+	}
+
+	r := rune(key.UnicodeChar)
+
+	switch {
+	case utf16.IsSurrogate(r) && r < 0xdc00:
+		t.highSurrogate = key.UnicodeChar
+		return nil
+	case utf16.IsSurrogate(r):
+		r = utf16.DecodeRune(rune(t.highSurrogate), r)
+		t.highSurrogate = 0
+	}
+
+	seq := make([]byte, 0, 1+utf8.UTFMax)
+
+	// AltGr is reported as right Alt plus left Ctrl and produces plain
+	// characters, so Alt is only encoded as an ESC prefix without it.
+	altGr := state&rightAltPressed != 0 && state&leftCtrlPressed != 0
+	if alt && !altGr {
+		seq = append(seq, '\x1b')
+	}
+
+	return utf8.AppendRune(seq, r)
+}
+
+// modifierParam returns the xterm modifier parameter for the given control
+// key state, which is 1 if no modifier is pressed.
+func modifierParam(state uint32) int {
+	m := 1
+	if state&shiftPressed != 0 {
+		m++
+	}
+
+	if state&(leftAltPressed|rightAltPressed) != 0 {
+		m += 2
+	}
+
+	if state&(leftCtrlPressed|rightCtrlPressed) != 0 {
+		m += 4
+	}
+
+	return m
+}
+
+// translateMouse encodes mouse events as SGR mouse reports (ESC [ < b ; x ; y
+// M for presses and motion, m for releases) with 1-based window coordinates.
+func (t *inputTranslator) translateMouse(buf []byte, mouse *MouseEventRecord) []byte {
+	x, y := int(mouse.MousePosition.X)+1, int(mouse.MousePosition.Y)+1
+	if t.window != nil {
+		if window, err := t.window(); err == nil {
+			x -= int(window.Left)
+			y -= int(window.Top)
+		}
+	}
+
+	mods := 0
+	if mouse.ControlKeyState&shiftPressed != 0 {
+		mods += 4
+	}
+
+	if mouse.ControlKeyState&(leftAltPressed|rightAltPressed) != 0 {
+		mods += 8
+	}
+
+	if mouse.ControlKeyState&(leftCtrlPressed|rightCtrlPressed) != 0 {
+		mods += 16
+	}
+
+	report := func(buf []byte, button int, final byte) []byte {
+		return append(buf, fmt.Sprintf("\x1b[<%d;%d;%d%c", button+mods, x, y, final)...)
+	}
+
+	switch {
+	case mouse.EventFlags&mouseWheeled != 0:
+		// the high word of the button state is the signed wheel delta
+		if int16(mouse.ButtonState>>16) > 0 {
+			return report(buf, 64, 'M')
+		}
+
+		return report(buf, 65, 'M')
+	case mouse.EventFlags&mouseHWheeled != 0:
+		if int16(mouse.ButtonState>>16) > 0 {
+			return report(buf, 67, 'M')
+		}
+
+		return report(buf, 66, 'M')
+	}
+
+	buttons := []struct {
+		state uint32
+		code  int
+	}{
+		{fromLeft1stButtonPressed, 0},
+		{fromLeft2ndButtonPressed, 1},
+		{rightmostButtonPressed, 2},
+	}
+
+	pressed := mouse.ButtonState & 0xffff
+	changed := pressed ^ t.buttons
+	t.buttons = pressed
+
+	if changed == 0 {
+		if mouse.EventFlags&mouseMoved == 0 {
+			return buf
+		}
+
+		for _, b := range buttons {
+			if pressed&b.state != 0 {
+				return report(buf, b.code+32, 'M')
+			}
+		}
+
+		// motion without a pressed button
+		return report(buf, 35, 'M')
+	}
+
+	for _, b := range buttons {
+		switch {
+		case changed&b.state == 0:
+		case pressed&b.state != 0:
+			buf = report(buf, b.code, 'M')
+		default:
+			buf = report(buf, b.code, 'm')
+		}
+	}
+
+	return buf
+}
+
+// translateSize encodes a resize of the console as the xterm report of the
+// text area size (ESC [ 8 ; rows ; columns t), which takes the place of
+// SIGWINCH on unix terminals.
+func (t *inputTranslator) translateSize(buf []byte, size windows.Coord) []byte {
+	rows, cols := int(size.Y), int(size.X)
+	if t.window != nil {
+		if window, err := t.window(); err == nil {
+			rows = int(window.Bottom-window.Top) + 1
+			cols = int(window.Right-window.Left) + 1
+		}
+	}
+
+	return append(buf, fmt.Sprintf("\x1b[8;%d;%dt", rows, cols)...)
+}
@@ -0,0 +1,102 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func keyRecord(vk, char uint16, state uint32) *InputRecord {
+	record := &InputRecord{EventType: KeyEventType}
+	*(*KeyEventRecord)(unsafe.Pointer(&record.event)) = KeyEventRecord{
+		KeyDown:         1,
+		RepeatCount:     1,
+		VirtualKeyCode:  vk,
+		UnicodeChar:     char,
+		ControlKeyState: state,
+	}
+
+	return record
+}
+
+func TestKeyTranslation(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   *InputRecord
+		expected string
+	}{
+		{"char", keyRecord('A', 'a', 0), "a"},
+		{"up", keyRecord(vkUp, 0, 0), "\x1b[A"},
+		{"ctrl+right", keyRecord(vkRight, 0, leftCtrlPressed), "\x1b[1;5C"},
+		{"f1", keyRecord(vkF1, 0, 0), "\x1bOP"},
+		{"shift+f1", keyRecord(vkF1, 0, shiftPressed), "\x1b[1;2P"},
+		{"f5", keyRecord(vkF5, 0, 0), "\x1b[15~"},
+		{"alt+delete", keyRecord(vkDelete, 0, leftAltPressed), "\x1b[3;3~"},
+		{"alt+x", keyRecord('X', 'x', leftAltPressed), "\x1bx"},
+		{"altgr", keyRecord('Q', '@', rightAltPressed|leftCtrlPressed), "@"},
+		{"ctrl+alt+a", keyRecord('A', 0, leftAltPressed|leftCtrlPressed), "\x1b\x01"},
+		{"ctrl+alt+x", keyRecord('X', 0x18, leftAltPressed|leftCtrlPressed), "\x1b\x18"},
+		{"shift+tab", keyRecord(vkTab, '\t', shiftPressed), "\x1b[Z"},
+		{"shift", keyRecord(0x10, 0, shiftPressed), ""},
+	}
+
+	for _, test := range tests {
+		var tr inputTranslator
+
+		got := string(tr.translate(nil, test.record))
+		if got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestKeyTranslationSurrogates(t *testing.T) {
+	var tr inputTranslator
+
+	buf := tr.translate(nil, keyRecord(0, 0xd83d, 0))
+	buf = tr.translate(buf, keyRecord(0, 0xde00, 0))
+
+	if string(buf) != "\U0001F600" {
+		t.Errorf("expected %q, got %q", "\U0001F600", string(buf))
+	}
+}
+
+func mouseRecord(x, y int16, buttons, state, flags uint32) *InputRecord {
+	record := &InputRecord{EventType: MouseEventType}
+	*(*MouseEventRecord)(unsafe.Pointer(&record.event)) = MouseEventRecord{
+		MousePosition:   windows.Coord{X: x, Y: y},
+		ButtonState:     buttons,
+		ControlKeyState: state,
+		EventFlags:      flags,
+	}
+
+	return record
+}
+
+func TestEventTranslation(t *testing.T) {
+	tr := inputTranslator{events: true}
+
+	var buf []byte
+	buf = tr.translate(buf, mouseRecord(4, 9, fromLeft1stButtonPressed, 0, 0))
+	buf = tr.translate(buf, mouseRecord(5, 9, fromLeft1stButtonPressed, leftCtrlPressed, mouseMoved))
+	buf = tr.translate(buf, mouseRecord(5, 9, 0, 0, 0))
+	buf = tr.translate(buf, mouseRecord(0, 0, 0x00780000, 0, mouseWheeled))
+
+	size := &InputRecord{EventType: WindowBufferSizeEventType}
+	*(*windows.Coord)(unsafe.Pointer(&size.event)) = windows.Coord{X: 80, Y: 24}
+	buf = tr.translate(buf, size)
+
+	expected := "\x1b[<0;5;10M\x1b[<48;6;10M\x1b[<0;6;10m\x1b[<64;1;1M\x1b[8;24;80t"
+	if string(buf) != expected {
+		t.Errorf("expected %q, got %q", expected, string(buf))
+	}
+
+	var keysOnly inputTranslator
+	if got := keysOnly.translate(nil, mouseRecord(0, 0, fromLeft1stButtonPressed, 0, 0)); len(got) != 0 {
+		t.Errorf("expected mouse events to be ignored, got %q", string(got))
+	}
+}
@@ -0,0 +1,22 @@
+package cancelreader
+
+import "fmt"
+
+// NewTTYReader returns a CancelReader for the terminal of the process,
+// /dev/tty on unix and the console input buffer CONIN$ on Windows, even if
+// stdin is redirected. Tools reading data from a pipe on stdin can still ask
+// the user this way. Close also closes the terminal.
+func NewTTYReader(opts ...Option) (CancelReader, error) {
+	f, err := openTTY()
+	if err != nil {
+		return nil, fmt.Errorf("open terminal: %w", err)
+	}
+
+	r, err := NewReader(f, append(opts, WithOwnership(), func(c *config) { c.tty = true })...)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,21 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import "testing"
+
+func TestTTYReader(t *testing.T) {
+	r, err := NewTTYReader()
+	if err != nil {
+		t.Skip("no controlling terminal")
+	}
+
+	if InputKind(r) != KindTerminal {
+		t.Errorf("expected a terminal, but got %v", InputKind(r))
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+}
@@ -0,0 +1,28 @@
+package cancelreader
+
+// Unread pushes data back to r, so that the next Read returns it before any
+// new input, e.g. when a parser read past the end of an escape sequence. Data
+// pushed back later is returned first. It reports false if r was not
+// returned by NewReader.
+func Unread(r CancelReader, data []byte) bool {
+	info, ok := infoOf(r)
+	if !ok {
+		return false
+	}
+
+	info.unreadBytes(data)
+
+	return true
+}
+
+// unreadBytes makes data the next input returned by Read.
+func (r *infoReader) unreadBytes(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.unread = append(append([]byte(nil), data...), r.unread...)
+}
@@ -0,0 +1,90 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetConsoleProcessList = modkernel32.NewProc("GetConsoleProcessList")
+
+// IsWinpty reports whether the console of the process is the hidden console
+// of a winpty agent. Older Cygwin and MSYS2 terminals like mintty use winpty
+// to run native console programs. The agent translates the terminal input
+// into console input records, so the console backend and console modes work
+// as usual, but virtual terminal input is not available.
+func IsWinpty() bool {
+	pids := make([]uint32, 16)
+
+	for {
+		n, err := getConsoleProcessList(pids)
+		if err != nil {
+			return false
+		}
+
+		if n > len(pids) {
+			pids = make([]uint32, n)
+			continue
+		}
+
+		pids = pids[:n]
+		break
+	}
+
+	for _, pid := range pids {
+		if strings.EqualFold(processName(pid), "winpty-agent.exe") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// virtualTerminalInput reports whether the console behind os.Stdin reports
+// special keys as escape sequences.
+func virtualTerminalInput() bool {
+	var mode uint32
+
+	err := windows.GetConsoleMode(windows.Handle(os.Stdin.Fd()), &mode)
+
+	return err == nil && mode&windows.ENABLE_VIRTUAL_TERMINAL_INPUT != 0
+}
+
+// processName returns the file name of the executable of a process or an
+// empty string if it is not accessible.
+func processName(pid uint32) string {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h) // nolint: errcheck
+
+	buf := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(buf))
+
+	err = windows.QueryFullProcessImageName(h, 0, &buf[0], &size)
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Base(windows.UTF16ToString(buf[:size]))
+}
+
+// getConsoleProcessList returns the number of processes attached to the
+// console, which may exceed len(pids).
+func getConsoleProcessList(pids []uint32) (int, error) {
+	r, _, e := syscall.Syscall(procGetConsoleProcessList.Addr(), 2,
+		uintptr(unsafe.Pointer(&pids[0])), uintptr(len(pids)), 0)
+	if r == 0 {
+		return 0, error(e)
+	}
+
+	return int(r), nil
+}
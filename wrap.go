@@ -0,0 +1,46 @@
+package cancelreader
+
+// The wrappers of this package, Tee, Decoder, Filter, StdinReader and
+// Attachment, keep a stack of readers cancelable:
+//
+//   - Cancel of the outer wrapper cancels the innermost CancelReader.
+//   - ReadContext of the outer wrapper passes the context down, so its
+//     deadline is enforced by the backend and not only checked up front.
+//   - Backend, InputKind, Stats, Token and the other accessors taking a
+//     CancelReader see through Tee.
+
+// unwrapper is implemented by wrappers returning a CancelReader around the
+// one they read from.
+type unwrapper interface {
+	unwrap() CancelReader
+}
+
+// infoOf returns the reader created by NewReader below the wrappers of r.
+func infoOf(r CancelReader) (*infoReader, bool) {
+	for {
+		switch v := r.(type) {
+		case *infoReader:
+			return v, true
+		case unwrapper:
+			r = v.unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// canceler is the part of CancelReader that wrappers forward when they wrap
+// a plain io.Reader.
+type canceler interface {
+	Cancel() bool
+}
+
+// cancelInner cancels r if it is cancelable and returns true if it
+// succeeded.
+func cancelInner(r interface{}) bool {
+	if c, ok := r.(canceler); ok {
+		return c.Cancel()
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// wrapped is what every wrapper has to pass on to the backend.
+type wrapped interface {
+	ContextReader
+	Cancel() bool
+}
+
+// eventReader adapts a Decoder to wrapped.
+type eventReader struct {
+	d *Decoder
+}
+
+func (r eventReader) ReadContext(ctx context.Context, _ []byte) (int, error) {
+	_, err := r.d.ReadEventContext(ctx)
+	return 0, err
+}
+
+func (r eventReader) Cancel() bool {
+	return r.d.Cancel()
+}
+
+func TestWrapperPropagation(t *testing.T) {
+	noRaw := func(uintptr) (func() error, error) {
+		return func() error { return nil }, nil
+	}
+
+	wrappers := map[string]func(cr CancelReader, f File) wrapped{
+		"tee": func(cr CancelReader, _ File) wrapped {
+			return Tee(cr).(wrapped)
+		},
+		"decoder": func(cr CancelReader, _ File) wrapped {
+			return eventReader{NewDecoder(cr)}
+		},
+		"filter": func(cr CancelReader, _ File) wrapped {
+			return NewFilter(Tee(cr), KeyEvent{Key: KeyRune, Rune: '~'}, func(KeyEvent) {})
+		},
+		"stdin": func(_ CancelReader, f File) wrapped {
+			return &StdinReader{file: f}
+		},
+		"attachment": func(_ CancelReader, f File) wrapped {
+			return &Attachment{s: &StdinReader{file: f}, makeRaw: noRaw}
+		},
+	}
+
+	for name, wrap := range wrappers {
+		wrap := wrap
+		t.Run(name, func(t *testing.T) {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer pr.Close()
+			defer pw.Close()
+
+			cr, err := NewReader(pr)
+			if err != nil {
+				t.Fatalf("expected no error, but got %s", err)
+			}
+			defer cr.Close()
+
+			r := wrap(cr, pr)
+			p := make([]byte, 1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := r.ReadContext(ctx, p); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected the deadline to reach the backend, but got %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := r.ReadContext(context.Background(), p)
+				done <- err
+			}()
+
+			time.Sleep(20 * time.Millisecond)
+			if !r.Cancel() {
+				t.Errorf("expected Cancel to reach the backend")
+			}
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, ErrCanceled) {
+					t.Errorf("expected ErrCanceled, but got %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("expected the pending Read to be canceled")
+			}
+		})
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cr, err := NewReader(pr)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer cr.Close()
+
+	if Backend(Tee(cr)) != Backend(cr) {
+		t.Errorf("expected Backend to see through Tee")
+	}
+}
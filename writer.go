@@ -0,0 +1,57 @@
+package cancelreader
+
+import "io"
+
+// CancelWriter is a io.WriteCloser whose Writes can be canceled, e.g. when
+// the consumer of a pipe, pty or socket stopped reading and its buffer is
+// full.
+type CancelWriter interface {
+	io.WriteCloser
+
+	// Cancel cancels ongoing and future writes and returns true if it
+	// succeeded.
+	Cancel() bool
+}
+
+// writeChunk is how much a CancelWriter writes at once to a file other than a
+// socket after waiting for room in its buffer. POSIX guarantees this much
+// room in a writable pipe.
+const writeChunk = 512
+
+// NewWriter returns a CancelWriter for writer. If writer is a File, blocked
+// writes are interrupted: on unix by waiting for room in its buffer with
+// epoll, kqueue or poll, on Windows with CancelSynchronousIo. The file is left
+// in blocking mode, sockets are written with MSG_DONTWAIT.
+// Otherwise Cancel only affects future writes and returns false. Writes
+// interrupted by Cancel return the number of bytes written and ErrCanceled.
+func NewWriter(writer io.Writer) (CancelWriter, error) {
+	if f, ok := writer.(File); ok {
+		return newWriter(f)
+	}
+
+	return &fallbackCancelWriter{w: writer}, nil
+}
+
+// fallbackCancelWriter can't interrupt an ongoing Write, but fails future
+// ones once canceled.
+type fallbackCancelWriter struct {
+	w io.Writer
+	cancelMixin
+}
+
+func (w *fallbackCancelWriter) Write(data []byte) (int, error) {
+	if w.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	return w.w.Write(data) // nolint: wrapcheck
+}
+
+func (w *fallbackCancelWriter) Cancel() bool {
+	w.setCanceled()
+	return false
+}
+
+func (w *fallbackCancelWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// msgDontWait makes a single send to a socket non-blocking.
+const msgDontWait = unix.MSG_DONTWAIT
+
+// newWriteWaiter waits for room in the buffer of file with kqueue, like the
+// kqueue backend waits for input. /dev/tty is waited for with poll, as
+// kqueue returns instantly for it.
+func newWriteWaiter(file File, cancelFd int) (writeWaiter, error) {
+	fd := int(file.Fd())
+	if file.Name() == "/dev/tty" {
+		return newPollWaiter(fd, cancelFd), nil
+	}
+
+	kQueue, err := unix.Kqueue()
+	if err != nil {
+		return nil, syscallError(backendKqueue, "kqueue", file.Fd(), err)
+	}
+
+	w := &kqueueWaiter{kQueue: kQueue, cancelFd: cancelFd}
+	unix.SetKevent(&w.changes[0], fd, unix.EVFILT_WRITE, unix.EV_ADD)
+	unix.SetKevent(&w.changes[1], cancelFd, unix.EVFILT_READ, unix.EV_ADD)
+
+	return w, nil
+}
+
+type kqueueWaiter struct {
+	kQueue   int
+	cancelFd int
+	changes  [2]unix.Kevent_t
+}
+
+func (w *kqueueWaiter) wait() error {
+	events := make([]unix.Kevent_t, 2)
+
+	for {
+		n, err := unix.Kevent(w.kQueue, w.changes[:], events, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return syscallError(backendKqueue, "kevent", uintptr(w.kQueue), err)
+		}
+
+		for _, event := range events[:n] {
+			if int(event.Ident) == w.cancelFd {
+				return ErrCanceled
+			}
+		}
+
+		// errors and hangups are reported by the write
+		return nil
+	}
+}
+
+func (w *kqueueWaiter) close() error {
+	return unix.Close(w.kQueue) // nolint: wrapcheck
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package cancelreader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// msgDontWait makes a single send to a socket non-blocking.
+const msgDontWait = unix.MSG_DONTWAIT
+
+// newWriteWaiter waits for room in the buffer of file with epoll, like the
+// epoll backend waits for input. Regular files, which epoll does not support
+// and which never block anyway, are waited for with poll.
+func newWriteWaiter(file File, cancelFd int) (writeWaiter, error) {
+	fd := int(file.Fd())
+
+	epoll, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, syscallError(backendEpoll, "epoll_create1", file.Fd(), err)
+	}
+
+	for _, event := range []unix.EpollEvent{
+		{Events: unix.EPOLLOUT, Fd: int32(fd)},
+		{Events: unix.EPOLLIN, Fd: int32(cancelFd)},
+	} {
+		event := event
+
+		err = unix.EpollCtl(epoll, unix.EPOLL_CTL_ADD, int(event.Fd), &event)
+		if errors.Is(err, unix.EPERM) && int(event.Fd) == fd {
+			_ = unix.Close(epoll)
+			return newPollWaiter(fd, cancelFd), nil
+		}
+
+		if err != nil {
+			_ = unix.Close(epoll)
+			return nil, syscallError(backendEpoll, "epoll_ctl", uintptr(event.Fd), err)
+		}
+	}
+
+	return &epollWaiter{epoll: epoll, cancelFd: cancelFd}, nil
+}
+
+type epollWaiter struct {
+	epoll    int
+	cancelFd int
+}
+
+func (w *epollWaiter) wait() error {
+	events := make([]unix.EpollEvent, 2)
+
+	for {
+		n, err := unix.EpollWait(w.epoll, events, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return syscallError(backendEpoll, "epoll_wait", uintptr(w.epoll), err)
+		}
+
+		for _, event := range events[:n] {
+			if int(event.Fd) == w.cancelFd {
+				return ErrCanceled
+			}
+		}
+
+		// errors and hangups are reported by the write
+		return nil
+	}
+}
+
+func (w *epollWaiter) close() error {
+	return unix.Close(w.epoll) // nolint: wrapcheck
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !solaris && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !zos && !windows
+// +build !linux,!solaris,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!zos,!windows
+
+package cancelreader
+
+func newWriter(file File) (CancelWriter, error) {
+	return &fallbackCancelWriter{w: file}, nil
+}
@@ -0,0 +1,14 @@
+//go:build solaris
+// +build solaris
+
+package cancelreader
+
+import "golang.org/x/sys/unix"
+
+// msgDontWait makes a single send to a socket non-blocking.
+const msgDontWait = unix.MSG_DONTWAIT
+
+// newWriteWaiter waits for room in the buffer of file with poll.
+func newWriteWaiter(file File, cancelFd int) (writeWaiter, error) {
+	return newPollWaiter(int(file.Fd()), cancelFd), nil
+}
@@ -0,0 +1,142 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly
+
+package cancelreader
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWriter(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	w, err := NewWriter(pw)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+	data := make([]byte, 1)
+	if _, err := pr.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		w.Cancel()
+	}()
+
+	// nobody reads, so the pipe buffer fills up
+	n, err := w.Write(make([]byte, 1<<20))
+	if !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+	if n == 0 || n == 1<<20 {
+		t.Errorf("expected a partial write, but got %d bytes", n)
+	}
+}
+
+func TestWriterSlowConsumer(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "local")
+	remote := os.NewFile(uintptr(fds[1]), "remote")
+	defer local.Close()
+	defer remote.Close()
+
+	w, err := NewWriter(local)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+
+	// the remote end frees a few bytes at a time, less than a write needs
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		data := make([]byte, 7)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				_, _ = remote.Read(data)
+			}
+		}
+	}()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		w.Cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Write(make([]byte, 1<<22))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCanceled) {
+			t.Errorf("expected ErrCanceled, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Cancel to interrupt the write")
+	}
+
+	if flags, _ := unix.FcntlInt(local.Fd(), unix.F_GETFL, 0); flags&unix.O_NONBLOCK != 0 {
+		t.Errorf("expected the file to stay in blocking mode")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("expected no error, but got %s", err)
+	}
+}
+
+func TestReadWriter(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "local")
+	remote := os.NewFile(uintptr(fds[1]), "remote")
+	defer local.Close()
+	defer remote.Close()
+
+	rw, err := NewReadWriter(local)
+	if err != nil {
+		t.Fatalf("expected no error, but got %s", err)
+	}
+	defer rw.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		rw.CancelWrite()
+		_, _ = remote.Write([]byte("a"))
+	}()
+
+	// the remote end does not read, so the socket buffer fills up
+	if _, err := rw.Write(make([]byte, 1<<22)); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expected ErrCanceled, but got %v", err)
+	}
+
+	data := make([]byte, 1)
+	if _, err := rw.Read(data); err != nil || data[0] != 'a' {
+		t.Errorf("expected %q, but got %q, %v", "a", data, err)
+	}
+}
@@ -0,0 +1,161 @@
+//go:build linux || solaris || darwin || freebsd || netbsd || openbsd || dragonfly || zos
+// +build linux solaris darwin freebsd netbsd openbsd dragonfly zos
+
+package cancelreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// writeWaiter waits until a file has room in its buffer or the cancel signal
+// pipe is readable, with the mechanism of the backend of the platform.
+type writeWaiter interface {
+	// wait returns ErrCanceled if the cancel signal pipe is readable.
+	wait() error
+	close() error
+}
+
+func newWriter(file File) (CancelWriter, error) {
+	cancelSignalReader, cancelSignalWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating cancel signal pipe: %w", err)
+	}
+
+	waiter, err := newWriteWaiter(file, int(cancelSignalReader.Fd()))
+	if err != nil {
+		_ = cancelSignalReader.Close()
+		_ = cancelSignalWriter.Close()
+
+		return nil, err
+	}
+
+	return &unixCancelWriter{
+		file:               file,
+		fd:                 int(file.Fd()),
+		socket:             msgDontWait != 0 && fileKind(file) == KindSocket,
+		waiter:             waiter,
+		cancelSignalReader: cancelSignalReader,
+		cancelSignalWriter: cancelSignalWriter,
+	}, nil
+}
+
+// unixCancelWriter waits until the file has room or the cancel signal pipe is
+// readable. The file stays in blocking mode, as that is shared with everyone
+// using it, e.g. the shell owning a terminal. Sockets are written with
+// MSG_DONTWAIT instead, so no write to them blocks, whatever room is left.
+// Other files are written in chunks that fit once they have room.
+type unixCancelWriter struct {
+	file               File
+	fd                 int
+	socket             bool
+	waiter             writeWaiter
+	cancelSignalReader File
+	cancelSignalWriter File
+	cancelMixin
+}
+
+func (w *unixCancelWriter) Write(data []byte) (int, error) {
+	n := 0
+
+	for n < len(data) {
+		if w.isCanceled() {
+			return n, ErrCanceled
+		}
+
+		chunk := data[n:]
+		if !w.socket {
+			err := w.waiter.wait()
+			if err != nil {
+				return n, err
+			}
+
+			if len(chunk) > writeChunk {
+				chunk = chunk[:writeChunk]
+			}
+		}
+
+		m, err := w.write(chunk)
+		if m > 0 {
+			n += m
+		}
+
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue // try again if the syscall was interrupted
+		case errors.Is(err, unix.EAGAIN):
+			if w.socket {
+				err = w.waiter.wait()
+				if err != nil {
+					return n, err
+				}
+			}
+		case err != nil:
+			return n, &os.PathError{Op: "write", Path: w.file.Name(), Err: err}
+		}
+	}
+
+	return n, nil
+}
+
+func (w *unixCancelWriter) write(data []byte) (int, error) {
+	if w.socket {
+		return unix.SendmsgN(w.fd, data, nil, nil, msgDontWait) // nolint: wrapcheck
+	}
+
+	return unix.Write(w.fd, data) // nolint: wrapcheck
+}
+
+func (w *unixCancelWriter) Cancel() bool {
+	w.setCanceled()
+
+	_, err := w.cancelSignalWriter.Write([]byte{0})
+
+	return err == nil
+}
+
+// Close closes the cancel signal pipe but not the file.
+func (w *unixCancelWriter) Close() error {
+	return errors.Join(w.waiter.close(), w.cancelSignalWriter.Close(), w.cancelSignalReader.Close())
+}
+
+// pollWaiter waits with poll, e.g. for files epoll or kqueue don't support.
+type pollWaiter struct {
+	fds []unix.PollFd
+}
+
+func newPollWaiter(fd, cancelFd int) *pollWaiter {
+	return &pollWaiter{fds: []unix.PollFd{
+		{Fd: int32(fd), Events: unix.POLLOUT},
+		{Fd: int32(cancelFd), Events: unix.POLLIN},
+	}}
+}
+
+func (w *pollWaiter) wait() error {
+	for {
+		_, err := unix.Poll(w.fds, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue // try again if the syscall was interrupted
+		}
+
+		if err != nil {
+			return fmt.Errorf("poll: %w", err)
+		}
+
+		break
+	}
+
+	if w.fds[1].Revents != 0 {
+		return ErrCanceled
+	}
+
+	// errors and hangups are reported by the write
+	return nil
+}
+
+func (w *pollWaiter) close() error {
+	return nil
+}
@@ -0,0 +1,99 @@
+//go:build windows
+// +build windows
+
+package cancelreader
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var procCancelSynchronousIo = modkernel32.NewProc("CancelSynchronousIo")
+
+func newWriter(file File) (CancelWriter, error) {
+	return &winCancelWriter{handle: windows.Handle(file.Fd())}, nil
+}
+
+// winCancelWriter writes on a locked OS thread, so that Cancel can abort
+// the blocking WriteFile with CancelSynchronousIo.
+type winCancelWriter struct {
+	handle windows.Handle
+	cancelMixin
+
+	lock   sync.Mutex
+	thread windows.Handle // of the pending Write, 0 if none
+}
+
+func (w *winCancelWriter) Write(data []byte) (int, error) {
+	if w.isCanceled() {
+		return 0, ErrCanceled
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	thread, err := windows.OpenThread(windows.THREAD_TERMINATE, false, windows.GetCurrentThreadId())
+	if err != nil {
+		return 0, fmt.Errorf("open thread: %w", err)
+	}
+	defer windows.CloseHandle(thread) // nolint: errcheck
+
+	w.lock.Lock()
+	w.thread = thread
+	w.lock.Unlock()
+
+	defer func() {
+		w.lock.Lock()
+		w.thread = 0
+		w.lock.Unlock()
+	}()
+
+	var n uint32
+	if !w.isCanceled() {
+		err = windows.WriteFile(w.handle, data, &n, nil)
+	}
+
+	switch {
+	case w.isCanceled():
+		return int(n), ErrCanceled
+	case err != nil:
+		return int(n), fmt.Errorf("write file: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// Cancel cancels the pending Write. It returns false if the Write did not
+// return within the default cancel timeout.
+func (w *winCancelWriter) Cancel() bool {
+	w.setCanceled()
+
+	deadline := time.Now().Add(defaultCancelTimeout)
+	for {
+		w.lock.Lock()
+		thread := w.thread
+		if thread != 0 {
+			// fails with ERROR_NOT_FOUND until WriteFile started
+			_, _, _ = procCancelSynchronousIo.Call(uintptr(thread))
+		}
+		w.lock.Unlock()
+
+		if thread == 0 {
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (w *winCancelWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build zos
+// +build zos
+
+package cancelreader
+
+// msgDontWait is not supported, so sockets are written in chunks like pipes.
+const msgDontWait = 0
+
+// newWriteWaiter waits for room in the buffer of file with poll.
+func newWriteWaiter(file File, cancelFd int) (writeWaiter, error) {
+	return newPollWaiter(int(file.Fd()), cancelFd), nil
+}